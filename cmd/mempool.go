@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/mempoolcli"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+)
+
+var mempoolCmd = &cobra.Command{
+	Use:   "mempool",
+	Short: "Inspects and repairs the mempool of a stopped instance",
+	Long: `The mempool commands open the data directory of an instance that is not running. The DB is opened
+read-only unless the --write flag is set, which is required by import and delete.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Flags are valid at this point, so errors from the DB should not print the usage.
+		cmd.SilenceUsage = true
+	},
+}
+
+var mempoolListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the UserOperations that match the filter flags",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withMempoolStore(func(db storage.Store) error {
+			return mempoolcli.List(db, mempoolFilter, cmd.OutOrStdout())
+		})
+	},
+}
+
+var mempoolExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the UserOperations that match the filter flags as NDJSON",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withMempoolStore(func(db storage.Store) error {
+			w := cmd.OutOrStdout()
+			if mempoolOut != "" {
+				f, err := os.Create(mempoolOut)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			n, err := mempoolcli.Export(db, mempoolFilter, w)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "exported %d userOps\n", n)
+			return nil
+		})
+	},
+}
+
+var mempoolImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Imports UserOperations from an NDJSON file or stdin",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !mempoolWrite {
+			return fmt.Errorf("mempool: import requires --write")
+		}
+		return withMempoolStore(func(db storage.Store) error {
+			var r io.Reader = cmd.InOrStdin()
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+
+			n, err := mempoolcli.Import(db, r)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "imported %d userOps\n", n)
+			return nil
+		})
+	},
+}
+
+var mempoolDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Deletes the UserOperations that match the filter flags",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !mempoolWrite {
+			return fmt.Errorf("mempool: delete requires --write")
+		}
+		return withMempoolStore(func(db storage.Store) error {
+			return mempoolcli.Delete(db, mempoolFilter, cmd.OutOrStdout())
+		})
+	},
+}
+
+var mempoolWrite bool
+var mempoolOut string
+var mempoolFilter mempoolcli.FilterFlags
+
+func withMempoolStore(fn func(db storage.Store) error) error {
+	db, err := mempoolcli.Open(viper.GetString("erc4337_bundler_data_directory"), mempoolWrite)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return fn(db)
+}
+
+func init() {
+	rootCmd.AddCommand(mempoolCmd)
+	mempoolCmd.AddCommand(mempoolListCmd, mempoolExportCmd, mempoolImportCmd, mempoolDeleteCmd)
+
+	mempoolCmd.PersistentFlags().String(
+		"data-dir",
+		"/tmp/stackup_bundler",
+		"Optional. Data directory of the instance. Defaults to ERC4337_BUNDLER_DATA_DIRECTORY if set.",
+	)
+	if err := viper.BindPFlag(
+		"erc4337_bundler_data_directory",
+		mempoolCmd.PersistentFlags().Lookup("data-dir"),
+	); err != nil {
+		panic(err)
+	}
+	if err := viper.BindEnv("erc4337_bundler_data_directory"); err != nil {
+		panic(err)
+	}
+	mempoolCmd.PersistentFlags().BoolVar(
+		&mempoolWrite,
+		"write",
+		false,
+		"Optional. Opens the DB read-write. Required by import and delete.",
+	)
+
+	for _, c := range []*cobra.Command{mempoolListCmd, mempoolExportCmd, mempoolDeleteCmd} {
+		c.Flags().StringVar(&mempoolFilter.EntryPoint, "entrypoint", "", "Optional. Only match this EntryPoint.")
+		c.Flags().StringVar(&mempoolFilter.Sender, "sender", "", "Optional. Only match this sender.")
+		c.Flags().StringVar(&mempoolFilter.UserOpHash, "hash", "", "Optional. Only match this userOpHash.")
+		c.Flags().Int64Var(
+			&mempoolFilter.ChainID,
+			"chain-id",
+			0,
+			"Optional. Chain ID used to compute userOpHashes. Required by --hash.",
+		)
+	}
+	mempoolExportCmd.Flags().StringVarP(&mempoolOut, "out", "o", "", "Optional. File to write instead of stdout.")
+}
@@ -27,6 +27,7 @@ var startCmd = &cobra.Command{
 }
 
 var mode string
+var fakeSolverScript string
 
 func init() {
 	rootCmd.AddCommand(startCmd)
@@ -37,4 +38,14 @@ func init() {
 	if err := viper.BindPFlag("mode", startCmd.Flags().Lookup("mode")); err != nil {
 		panic(err)
 	}
+
+	startCmd.Flags().StringVar(
+		&fakeSolverScript,
+		"fake-solver",
+		"",
+		"Optional. Path to a JSON script for running a fake Solver instead of the configured solver URLs.",
+	)
+	if err := viper.BindPFlag("solver_fake_script", startCmd.Flags().Lookup("fake-solver")); err != nil {
+		panic(err)
+	}
 }
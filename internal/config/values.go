@@ -16,16 +16,52 @@ import (
 type Values struct {
 	// Documented variables.
 	PrivateKey              string
+	PrivateKeys             []string
+	KeystoreFiles           []string
+	KeystorePasswordFile    string
+	RemoteSignerUrl         string
+	RemoteSignerAddresses   []string
 	EthClientUrl            string
 	Port                    int
 	DataDirectory           string
+	InMemoryStorage         bool
 	SupportedEntryPoints    []common.Address
 	MaxVerificationGas      *big.Int
 	MaxBatchGasLimit        *big.Int
 	MaxOpTTL                time.Duration
+	IntentMaxTTL            time.Duration
 	MaxOpsForUnstakedSender int
+	ReplacementPriceBump    int64
+	MempoolMaxOps           int
+	MempoolMaxOpsPerEP      int
+	MempoolMaxOpsPerEntity  int
+	RebroadcastInterval     time.Duration
+	ReconcileConfirmations  uint64
+	WSAllowedOrigins        []string
+	WSWriteTimeout          time.Duration
+	WSMaxSubscriptions      int
+	MinProfitMargin         float64
 	Beneficiary             string
+	BeneficiaryPrivateKey   string
+	BeneficiaryKeystoreFile string
+	BeneficiaryRemote       bool
+	EOALowBalance           *big.Int
+	EOAHighBalance          *big.Int
 	SolverUrl               string
+	SolverUrls              []string
+	SolverTimeout           time.Duration
+	SolverMaxAttempts       int
+	SolverRetryBackoff      time.Duration
+	SolverFailureThreshold  int
+	SolverBreakerCooldown   time.Duration
+	SolverHealthInterval    time.Duration
+	SolverHMACSecret        string
+	SolverBearerToken       string
+	SolverPublicKeys        []string
+	SolverFakeScript        string
+	SolverFakeAddr          string
+	SolverFakePrivateKey    string
+	SolverScore             string
 
 	// Searcher mode variables.
 	EthBuilderUrls    []string
@@ -85,16 +121,37 @@ func GetValues() *Values {
 	// Default variables
 	viper.SetDefault("erc4337_bundler_port", 4337)
 	viper.SetDefault("erc4337_bundler_data_directory", "/tmp/stackup_bundler")
+	viper.SetDefault("erc4337_bundler_in_memory_storage", false)
 	viper.SetDefault("erc4337_bundler_supported_entry_points", "0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
 	viper.SetDefault("erc4337_bundler_max_verification_gas", 3000000)
 	viper.SetDefault("erc4337_bundler_max_batch_gas_limit", 25000000)
 	viper.SetDefault("erc4337_bundler_max_op_ttl_seconds", 180)
 	viper.SetDefault("erc4337_bundler_max_ops_for_unstaked_sender", 4)
+	viper.SetDefault("erc4337_bundler_replacement_price_bump", 10)
+	viper.SetDefault("erc4337_bundler_mempool_max_ops", 10000)
+	viper.SetDefault("erc4337_bundler_mempool_max_ops_per_entrypoint", 10000)
+	viper.SetDefault("erc4337_bundler_mempool_max_ops_per_entity", 1000)
 	viper.SetDefault("erc4337_bundler_blocks_in_the_future", 6)
+	viper.SetDefault("erc4337_bundler_rebroadcast_interval_seconds", 12)
+	viper.SetDefault("erc4337_bundler_reconcile_confirmations", 12)
+	viper.SetDefault("erc4337_bundler_ws_write_timeout_seconds", 10)
+	viper.SetDefault("erc4337_bundler_ws_max_subscriptions", 100)
+	viper.SetDefault("erc4337_bundler_min_profit_margin", 0)
+	viper.SetDefault("erc4337_bundler_eoa_low_balance_wei", "0")
+	viper.SetDefault("erc4337_bundler_eoa_high_balance_wei", "0")
 	viper.SetDefault("erc4337_bundler_otel_insecure_mode", false)
 	viper.SetDefault("erc4337_bundler_debug_mode", false)
 	viper.SetDefault("erc4337_bundler_gin_mode", gin.ReleaseMode)
+	viper.SetDefault("intent_max_ttl_seconds", 600)
 	viper.SetDefault("solver_url", "http://localhost:7322/solve")
+	viper.SetDefault("solver_timeout_seconds", 100)
+	viper.SetDefault("solver_max_attempts", 5)
+	viper.SetDefault("solver_retry_backoff_seconds", 2)
+	viper.SetDefault("solver_failure_threshold", 3)
+	viper.SetDefault("solver_breaker_cooldown_seconds", 30)
+	viper.SetDefault("solver_health_interval_seconds", 10)
+	viper.SetDefault("solver_fake_addr", "127.0.0.1:0")
+	viper.SetDefault("solver_score", "gas_cost")
 
 	// Read in from .env file if available
 	viper.SetConfigName(".env")
@@ -112,16 +169,37 @@ func GetValues() *Values {
 	// Read in from environment variables
 	_ = viper.BindEnv("erc4337_bundler_eth_client_url")
 	_ = viper.BindEnv("erc4337_bundler_private_key")
+	_ = viper.BindEnv("erc4337_bundler_private_keys")
+	_ = viper.BindEnv("erc4337_bundler_keystore_files")
+	_ = viper.BindEnv("erc4337_bundler_keystore_password_file")
+	_ = viper.BindEnv("erc4337_bundler_remote_signer_url")
+	_ = viper.BindEnv("erc4337_bundler_remote_signer_addresses")
 	_ = viper.BindEnv("erc4337_bundler_port")
 	_ = viper.BindEnv("erc4337_bundler_data_directory")
+	_ = viper.BindEnv("erc4337_bundler_in_memory_storage")
 	_ = viper.BindEnv("erc4337_bundler_supported_entry_points")
 	_ = viper.BindEnv("erc4337_bundler_beneficiary")
+	_ = viper.BindEnv("erc4337_bundler_beneficiary_private_key")
+	_ = viper.BindEnv("erc4337_bundler_beneficiary_keystore_file")
+	_ = viper.BindEnv("erc4337_bundler_beneficiary_remote_signer")
+	_ = viper.BindEnv("erc4337_bundler_eoa_low_balance_wei")
+	_ = viper.BindEnv("erc4337_bundler_eoa_high_balance_wei")
 	_ = viper.BindEnv("erc4337_bundler_max_verification_gas")
 	_ = viper.BindEnv("erc4337_bundler_max_batch_gas_limit")
 	_ = viper.BindEnv("erc4337_bundler_max_op_ttl_seconds")
 	_ = viper.BindEnv("erc4337_bundler_max_ops_for_unstaked_sender")
+	_ = viper.BindEnv("erc4337_bundler_replacement_price_bump")
+	_ = viper.BindEnv("erc4337_bundler_mempool_max_ops")
+	_ = viper.BindEnv("erc4337_bundler_mempool_max_ops_per_entrypoint")
+	_ = viper.BindEnv("erc4337_bundler_mempool_max_ops_per_entity")
 	_ = viper.BindEnv("erc4337_bundler_eth_builder_urls")
 	_ = viper.BindEnv("erc4337_bundler_blocks_in_the_future")
+	_ = viper.BindEnv("erc4337_bundler_rebroadcast_interval_seconds")
+	_ = viper.BindEnv("erc4337_bundler_reconcile_confirmations")
+	_ = viper.BindEnv("erc4337_bundler_ws_allowed_origins")
+	_ = viper.BindEnv("erc4337_bundler_ws_write_timeout_seconds")
+	_ = viper.BindEnv("erc4337_bundler_ws_max_subscriptions")
+	_ = viper.BindEnv("erc4337_bundler_min_profit_margin")
 	_ = viper.BindEnv("erc4337_bundler_otel_service_name")
 	_ = viper.BindEnv("erc4337_bundler_otel_collector_headers")
 	_ = viper.BindEnv("erc4337_bundler_otel_collector_url")
@@ -130,25 +208,121 @@ func GetValues() *Values {
 	_ = viper.BindEnv("erc4337_bundler_alt_mempool_ids")
 	_ = viper.BindEnv("erc4337_bundler_debug_mode")
 	_ = viper.BindEnv("erc4337_bundler_gin_mode")
+	_ = viper.BindEnv("intent_max_ttl_seconds")
 	_ = viper.BindEnv("solver_url")
+	_ = viper.BindEnv("solver_urls")
+	_ = viper.BindEnv("solver_timeout_seconds")
+	_ = viper.BindEnv("solver_max_attempts")
+	_ = viper.BindEnv("solver_retry_backoff_seconds")
+	_ = viper.BindEnv("solver_failure_threshold")
+	_ = viper.BindEnv("solver_breaker_cooldown_seconds")
+	_ = viper.BindEnv("solver_health_interval_seconds")
+	_ = viper.BindEnv("solver_hmac_secret")
+	_ = viper.BindEnv("solver_bearer_token")
+	_ = viper.BindEnv("solver_public_keys")
+	_ = viper.BindEnv("solver_fake_script")
+	_ = viper.BindEnv("solver_fake_addr")
+	_ = viper.BindEnv("solver_fake_private_key")
+	_ = viper.BindEnv("solver_score")
 
 	// Validate required variables
 	if variableNotSetOrIsNil("erc4337_bundler_eth_client_url") {
 		panic("Fatal config error: erc4337_bundler_eth_client_url not set")
 	}
 
-	if variableNotSetOrIsNil("erc4337_bundler_private_key") {
-		panic("Fatal config error: erc4337_bundler_private_key not set")
+	if variableNotSetOrIsNil("erc4337_bundler_private_key") &&
+		variableNotSetOrIsNil("erc4337_bundler_private_keys") &&
+		variableNotSetOrIsNil("erc4337_bundler_keystore_files") &&
+		variableNotSetOrIsNil("erc4337_bundler_remote_signer_url") {
+		panic(
+			"Fatal config error: one of erc4337_bundler_private_key, erc4337_bundler_private_keys, erc4337_bundler_keystore_files, or erc4337_bundler_remote_signer_url must be set",
+		)
 	}
 
-	if !viper.IsSet("erc4337_bundler_beneficiary") {
-		s, err := signer.New(viper.GetString("erc4337_bundler_private_key"))
+	if !variableNotSetOrIsNil("erc4337_bundler_keystore_files") &&
+		variableNotSetOrIsNil("erc4337_bundler_keystore_password_file") {
+		panic("Fatal config error: erc4337_bundler_keystore_password_file not set")
+	}
+
+	if !variableNotSetOrIsNil("erc4337_bundler_remote_signer_url") &&
+		variableNotSetOrIsNil("erc4337_bundler_remote_signer_addresses") {
+		panic("Fatal config error: erc4337_bundler_remote_signer_addresses not set")
+	}
+
+	// The beneficiary signer for top-ups can be loaded in the same ways as the bundler EOAs, but only one of them.
+	beneficiarySigners := 0
+	for _, env := range []string{
+		"erc4337_bundler_beneficiary_private_key",
+		"erc4337_bundler_beneficiary_keystore_file",
+	} {
+		if !variableNotSetOrIsNil(env) {
+			beneficiarySigners++
+		}
+	}
+	if viper.GetBool("erc4337_bundler_beneficiary_remote_signer") {
+		beneficiarySigners++
+	}
+	if beneficiarySigners > 1 {
+		panic(
+			"Fatal config error: only one of erc4337_bundler_beneficiary_private_key, erc4337_bundler_beneficiary_keystore_file, or erc4337_bundler_beneficiary_remote_signer can be set",
+		)
+	}
+
+	if !variableNotSetOrIsNil("erc4337_bundler_beneficiary_keystore_file") &&
+		variableNotSetOrIsNil("erc4337_bundler_keystore_password_file") {
+		panic("Fatal config error: erc4337_bundler_keystore_password_file not set")
+	}
+
+	if viper.GetBool("erc4337_bundler_beneficiary_remote_signer") {
+		if variableNotSetOrIsNil("erc4337_bundler_remote_signer_url") {
+			panic("Fatal config error: erc4337_bundler_remote_signer_url not set")
+		}
+		if variableNotSetOrIsNil("erc4337_bundler_beneficiary") {
+			panic("Fatal config error: erc4337_bundler_beneficiary not set")
+		}
+	}
+
+	// A beneficiary key takes precedence over the bundler EOA as the default beneficiary. The address of a
+	// beneficiary keystore file is only known once it is decrypted by the start command.
+	if !viper.IsSet("erc4337_bundler_beneficiary") &&
+		!variableNotSetOrIsNil("erc4337_bundler_beneficiary_private_key") {
+		s, err := signer.New(viper.GetString("erc4337_bundler_beneficiary_private_key"))
 		if err != nil {
 			panic(err)
 		}
 		viper.SetDefault("erc4337_bundler_beneficiary", s.Address.String())
 	}
 
+	// Without a beneficiary key, the beneficiary defaults to the first private key if one is set. Otherwise it
+	// defaults to the first signer once it has been loaded.
+	if !viper.IsSet("erc4337_bundler_beneficiary") &&
+		variableNotSetOrIsNil("erc4337_bundler_beneficiary_keystore_file") {
+		key := viper.GetString("erc4337_bundler_private_key")
+		if keys := envArrayToStringSlice(viper.GetString("erc4337_bundler_private_keys")); key == "" && len(keys) > 0 {
+			key = keys[0]
+		}
+		if key != "" {
+			s, err := signer.New(key)
+			if err != nil {
+				panic(err)
+			}
+			viper.SetDefault("erc4337_bundler_beneficiary", s.Address.String())
+		}
+	}
+
+	// Validate EOA balance variables
+	eoaLowBalance, ok := big.NewInt(0).SetString(viper.GetString("erc4337_bundler_eoa_low_balance_wei"), 10)
+	if !ok {
+		panic("Fatal config error: erc4337_bundler_eoa_low_balance_wei is not a valid integer")
+	}
+	eoaHighBalance, ok := big.NewInt(0).SetString(viper.GetString("erc4337_bundler_eoa_high_balance_wei"), 10)
+	if !ok {
+		panic("Fatal config error: erc4337_bundler_eoa_high_balance_wei is not a valid integer")
+	}
+	if eoaHighBalance.Cmp(eoaLowBalance) < 0 {
+		panic("Fatal config error: erc4337_bundler_eoa_high_balance_wei is below erc4337_bundler_eoa_low_balance_wei")
+	}
+
 	switch viper.GetString("mode") {
 	case "searcher":
 		if variableNotSetOrIsNil("erc4337_bundler_eth_builder_urls") {
@@ -172,19 +346,49 @@ func GetValues() *Values {
 		panic("Fatal config error: solver_url not set")
 	}
 
+	if viper.IsSet("solver_public_keys") {
+		keys := envArrayToStringSlice(viper.GetString("solver_public_keys"))
+		urls := envArrayToStringSlice(viper.GetString("solver_urls"))
+		if len(keys) > 1 && len(keys) != len(urls) {
+			panic("Fatal config error: solver_public_keys must have one key or one key per solver_urls entry")
+		}
+	}
+
 	// Return Values
 	privateKey := viper.GetString("erc4337_bundler_private_key")
+	privateKeys := envArrayToStringSlice(viper.GetString("erc4337_bundler_private_keys"))
+	if privateKey != "" {
+		privateKeys = append([]string{privateKey}, privateKeys...)
+	}
+	keystoreFiles := envArrayToStringSlice(viper.GetString("erc4337_bundler_keystore_files"))
+	keystorePasswordFile := viper.GetString("erc4337_bundler_keystore_password_file")
+	remoteSignerUrl := viper.GetString("erc4337_bundler_remote_signer_url")
+	remoteSignerAddresses := envArrayToStringSlice(viper.GetString("erc4337_bundler_remote_signer_addresses"))
 	ethClientUrl := viper.GetString("erc4337_bundler_eth_client_url")
 	port := viper.GetInt("erc4337_bundler_port")
 	dataDirectory := viper.GetString("erc4337_bundler_data_directory")
+	inMemoryStorage := viper.GetBool("erc4337_bundler_in_memory_storage")
 	supportedEntryPoints := envArrayToAddressSlice(viper.GetString("erc4337_bundler_supported_entry_points"))
 	beneficiary := viper.GetString("erc4337_bundler_beneficiary")
+	beneficiaryPrivateKey := viper.GetString("erc4337_bundler_beneficiary_private_key")
+	beneficiaryKeystoreFile := viper.GetString("erc4337_bundler_beneficiary_keystore_file")
+	beneficiaryRemote := viper.GetBool("erc4337_bundler_beneficiary_remote_signer")
 	maxVerificationGas := big.NewInt(int64(viper.GetInt("erc4337_bundler_max_verification_gas")))
 	maxBatchGasLimit := big.NewInt(int64(viper.GetInt("erc4337_bundler_max_batch_gas_limit")))
-	maxOpTTL := time.Second * viper.GetDuration("erc4337_bundler_max_op_ttl_seconds")
+	maxOpTTL := time.Duration(viper.GetInt("erc4337_bundler_max_op_ttl_seconds")) * time.Second
 	maxOpsForUnstakedSender := viper.GetInt("erc4337_bundler_max_ops_for_unstaked_sender")
+	replacementPriceBump := viper.GetInt64("erc4337_bundler_replacement_price_bump")
+	mempoolMaxOps := viper.GetInt("erc4337_bundler_mempool_max_ops")
+	mempoolMaxOpsPerEP := viper.GetInt("erc4337_bundler_mempool_max_ops_per_entrypoint")
+	mempoolMaxOpsPerEntity := viper.GetInt("erc4337_bundler_mempool_max_ops_per_entity")
 	ethBuilderUrls := envArrayToStringSlice(viper.GetString("erc4337_bundler_eth_builder_urls"))
 	blocksInTheFuture := viper.GetInt("erc4337_bundler_blocks_in_the_future")
+	rebroadcastInterval := time.Duration(viper.GetInt("erc4337_bundler_rebroadcast_interval_seconds")) * time.Second
+	reconcileConfirmations := viper.GetUint64("erc4337_bundler_reconcile_confirmations")
+	wsAllowedOrigins := envArrayToStringSlice(viper.GetString("erc4337_bundler_ws_allowed_origins"))
+	wsWriteTimeout := time.Duration(viper.GetInt("erc4337_bundler_ws_write_timeout_seconds")) * time.Second
+	wsMaxSubscriptions := viper.GetInt("erc4337_bundler_ws_max_subscriptions")
+	minProfitMargin := viper.GetFloat64("erc4337_bundler_min_profit_margin")
 	otelServiceName := viper.GetString("erc4337_bundler_otel_service_name")
 	otelCollectorHeader := envKeyValStringToMap(viper.GetString("erc4337_bundler_otel_collector_headers"))
 	otelCollectorUrl := viper.GetString("erc4337_bundler_otel_collector_url")
@@ -193,18 +397,58 @@ func GetValues() *Values {
 	altMempoolIds := envArrayToStringSlice(viper.GetString("erc4337_bundler_alt_mempool_ids"))
 	debugMode := viper.GetBool("erc4337_bundler_debug_mode")
 	ginMode := viper.GetString("erc4337_bundler_gin_mode")
+	intentMaxTTL := time.Duration(viper.GetInt("intent_max_ttl_seconds")) * time.Second
 	solverUrl := viper.GetString("solver_url")
+	solverUrls := envArrayToStringSlice(viper.GetString("solver_urls"))
+	if len(solverUrls) == 0 {
+		solverUrls = []string{solverUrl}
+	}
+	solverTimeout := time.Duration(viper.GetInt("solver_timeout_seconds")) * time.Second
+	solverMaxAttempts := viper.GetInt("solver_max_attempts")
+	solverRetryBackoff := time.Duration(viper.GetInt("solver_retry_backoff_seconds")) * time.Second
+	solverFailureThreshold := viper.GetInt("solver_failure_threshold")
+	solverBreakerCooldown := time.Duration(viper.GetInt("solver_breaker_cooldown_seconds")) * time.Second
+	solverHealthInterval := time.Duration(viper.GetInt("solver_health_interval_seconds")) * time.Second
+	solverHMACSecret := viper.GetString("solver_hmac_secret")
+	solverBearerToken := viper.GetString("solver_bearer_token")
+	solverPublicKeys := envArrayToStringSlice(viper.GetString("solver_public_keys"))
+	solverFakeScript := viper.GetString("solver_fake_script")
+	solverFakeAddr := viper.GetString("solver_fake_addr")
+	solverFakePrivateKey := viper.GetString("solver_fake_private_key")
+	solverScore := viper.GetString("solver_score")
 	return &Values{
 		PrivateKey:              privateKey,
+		PrivateKeys:             privateKeys,
+		KeystoreFiles:           keystoreFiles,
+		KeystorePasswordFile:    keystorePasswordFile,
+		RemoteSignerUrl:         remoteSignerUrl,
+		RemoteSignerAddresses:   remoteSignerAddresses,
 		EthClientUrl:            ethClientUrl,
 		Port:                    port,
 		DataDirectory:           dataDirectory,
+		InMemoryStorage:         inMemoryStorage,
 		SupportedEntryPoints:    supportedEntryPoints,
 		Beneficiary:             beneficiary,
+		BeneficiaryPrivateKey:   beneficiaryPrivateKey,
+		BeneficiaryKeystoreFile: beneficiaryKeystoreFile,
+		BeneficiaryRemote:       beneficiaryRemote,
+		EOALowBalance:           eoaLowBalance,
+		EOAHighBalance:          eoaHighBalance,
 		MaxVerificationGas:      maxVerificationGas,
 		MaxBatchGasLimit:        maxBatchGasLimit,
 		MaxOpTTL:                maxOpTTL,
+		IntentMaxTTL:            intentMaxTTL,
 		MaxOpsForUnstakedSender: maxOpsForUnstakedSender,
+		ReplacementPriceBump:    replacementPriceBump,
+		MempoolMaxOps:           mempoolMaxOps,
+		MempoolMaxOpsPerEP:      mempoolMaxOpsPerEP,
+		MempoolMaxOpsPerEntity:  mempoolMaxOpsPerEntity,
+		RebroadcastInterval:     rebroadcastInterval,
+		ReconcileConfirmations:  reconcileConfirmations,
+		WSAllowedOrigins:        wsAllowedOrigins,
+		WSWriteTimeout:          wsWriteTimeout,
+		WSMaxSubscriptions:      wsMaxSubscriptions,
+		MinProfitMargin:         minProfitMargin,
 		EthBuilderUrls:          ethBuilderUrls,
 		BlocksInTheFuture:       blocksInTheFuture,
 		OTELServiceName:         otelServiceName,
@@ -216,5 +460,19 @@ func GetValues() *Values {
 		DebugMode:               debugMode,
 		GinMode:                 ginMode,
 		SolverUrl:               solverUrl,
+		SolverUrls:              solverUrls,
+		SolverTimeout:           solverTimeout,
+		SolverMaxAttempts:       solverMaxAttempts,
+		SolverRetryBackoff:      solverRetryBackoff,
+		SolverFailureThreshold:  solverFailureThreshold,
+		SolverBreakerCooldown:   solverBreakerCooldown,
+		SolverHealthInterval:    solverHealthInterval,
+		SolverHMACSecret:        solverHMACSecret,
+		SolverBearerToken:       solverBearerToken,
+		SolverPublicKeys:        solverPublicKeys,
+		SolverFakeScript:        solverFakeScript,
+		SolverFakeAddr:          solverFakeAddr,
+		SolverFakePrivateKey:    solverFakePrivateKey,
+		SolverScore:             solverScore,
 	}
 }
@@ -0,0 +1,175 @@
+// Package fakesolver provides a Solver that returns scripted results for each Intent userOp. It can be served
+// from a test with httptest or started alongside the bundler to run without a live Solver.
+package fakesolver
+
+import (
+	"crypto/ecdsa"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/blndgs/model"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/goccy/go-json"
+)
+
+const (
+	// nonceHeader and signatureHeader match the headers used by the solution package to bind a signed response
+	// to the request it answers.
+	nonceHeader     = "X-Bundler-Nonce"
+	signatureHeader = "X-Solver-Signature"
+)
+
+// Step is the scripted result for a single request containing an Intent.
+type Step struct {
+	Status   model.ProcessingStatus `json:"status"`
+	CallData hexutil.Bytes          `json:"callData,omitempty"`
+}
+
+// Script defines the results returned by the fake Solver. Each Intent hash maps to a sequence of Steps that
+// are returned one per request and the last Step is repeated once the sequence is exhausted. Intents without
+// any Steps get the Default Step. An empty Default returns every Intent as Solved with empty callData.
+type Script struct {
+	Default Step              `json:"default"`
+	Intents map[string][]Step `json:"intents"`
+}
+
+// LoadScript reads a Script from a JSON file.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, err
+	}
+	return &script, nil
+}
+
+// Solver is an http.Handler that implements the /health and solve endpoints of a Solver with scripted
+// results.
+type Solver struct {
+	mu     sync.Mutex
+	script *Script
+	calls  map[string]int
+	key    *ecdsa.PrivateKey
+}
+
+// New returns a fake Solver for the given Script.
+func New(script *Script) *Solver {
+	if script.Intents == nil {
+		script.Intents = make(map[string][]Step)
+	}
+	if script.Default.Status == "" {
+		script.Default.Status = model.Solved
+	}
+
+	return &Solver{script: script, calls: make(map[string]int)}
+}
+
+// SetSigningKey defines the key used to sign every response. The signature covers the nonce of the request and
+// the response body so that the Solver's public key can be verified by the bundler. Responses are not signed
+// by default.
+func (s *Solver) SetSigningKey(key *ecdsa.PrivateKey) {
+	s.key = key
+}
+
+// Calls returns the number of requests received that contained the Intent with the given hash.
+func (s *Solver) Calls(hash string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[hash]
+}
+
+func (s *Solver) nextStep(hash string) Step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.calls[hash]
+	s.calls[hash]++
+	steps, ok := s.script.Intents[hash]
+	if !ok || len(steps) == 0 {
+		return s.script.Default
+	}
+	if n >= len(steps) {
+		return steps[len(steps)-1]
+	}
+	return steps[n]
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Solver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var body model.BodyOfUserOps
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for i, opExt := range body.UserOpsExt {
+		if i >= len(body.UserOps) {
+			break
+		}
+
+		step := s.nextStep(opExt.OriginalHashValue)
+		body.UserOpsExt[i].ProcessingStatus = step.Status
+		if step.Status == model.Solved {
+			solve(body.UserOps[i], step)
+		}
+	}
+
+	res, err := json.Marshal(body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if s.key != nil {
+		sig, err := crypto.Sign(crypto.Keccak256([]byte(r.Header.Get(nonceHeader)+"."), res), s.key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(signatureHeader, hexutil.Encode(sig))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(res)
+}
+
+// solve replaces the Intent JSON in the callData with the scripted callData and moves the Intent JSON to
+// the end of the signature.
+func solve(op *model.UserOperation, step Step) {
+	intentJSON, err := op.GetIntentJSON()
+	if err != nil {
+		return
+	}
+
+	op.CallData = append([]byte{}, step.CallData...)
+	if len(op.Signature) <= model.SignatureLength {
+		op.Signature = append(append([]byte{}, op.Signature...), []byte(intentJSON)...)
+	}
+}
+
+// Start serves the fake Solver on the given address in the background and returns the URL of its solve
+// endpoint.
+func (s *Solver) Start(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		_ = http.Serve(ln, s)
+	}()
+	return "http://" + ln.Addr().String() + "/solve", nil
+}
@@ -0,0 +1,187 @@
+// Package mempoolcli implements the mempool commands for inspecting and repairing the mempool of a bundler
+// that is not running.
+package mempoolcli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"text/tabwriter"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+)
+
+// Open returns a Store for the badger DB in the data directory. The DB is opened read-only unless write is
+// set. Opening fails while a bundler holds the DB.
+func Open(dataDirectory string, write bool) (storage.Store, error) {
+	db, err := badger.Open(
+		badger.DefaultOptions(dataDirectory).WithReadOnly(!write).WithLoggingLevel(badger.WARNING),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("mempool: cannot open %s: %w", dataDirectory, err)
+	}
+
+	return storage.NewBadgerStore(db), nil
+}
+
+// FilterFlags holds the raw values of the filter flags shared by the mempool commands.
+type FilterFlags struct {
+	EntryPoint string
+	Sender     string
+	UserOpHash string
+	ChainID    int64
+}
+
+// IsEmpty returns true if no filter flag is set.
+func (f FilterFlags) IsEmpty() bool {
+	return f.EntryPoint == "" && f.Sender == "" && f.UserOpHash == ""
+}
+
+// ToFilter parses the flags into a mempool.Filter.
+func (f FilterFlags) ToFilter() (mempool.Filter, error) {
+	filter := mempool.Filter{}
+	if f.ChainID != 0 {
+		filter.ChainID = big.NewInt(f.ChainID)
+	}
+	if f.EntryPoint != "" {
+		if !common.IsHexAddress(f.EntryPoint) {
+			return filter, fmt.Errorf("mempool: invalid entrypoint %s", f.EntryPoint)
+		}
+		ep := common.HexToAddress(f.EntryPoint)
+		filter.EntryPoint = &ep
+	}
+	if f.Sender != "" {
+		if !common.IsHexAddress(f.Sender) {
+			return filter, fmt.Errorf("mempool: invalid sender %s", f.Sender)
+		}
+		sender := common.HexToAddress(f.Sender)
+		filter.Sender = &sender
+	}
+	if f.UserOpHash != "" {
+		if filter.ChainID == nil {
+			return filter, errors.New("mempool: chain id is required to filter by hash")
+		}
+		hash := common.HexToHash(f.UserOpHash)
+		filter.UserOpHash = &hash
+	}
+
+	return filter, nil
+}
+
+func writeTable(w io.Writer, entries []*mempool.Entry, chainID *big.Int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if chainID != nil {
+		fmt.Fprintln(tw, "ENTRYPOINT\tSENDER\tNONCE\tADMITTED\tUSEROPHASH")
+	} else {
+		fmt.Fprintln(tw, "ENTRYPOINT\tSENDER\tNONCE\tADMITTED")
+	}
+	for _, e := range entries {
+		admitted := "-"
+		if !e.Info.AdmittedAt.IsZero() {
+			admitted = e.Info.AdmittedAt.UTC().Format(time.RFC3339)
+		}
+
+		if chainID != nil {
+			fmt.Fprintf(
+				tw,
+				"%s\t%s\t%s\t%s\t%s\n",
+				e.EntryPoint,
+				e.UserOp.Sender,
+				e.UserOp.Nonce,
+				admitted,
+				e.UserOp.GetUserOpHash(e.EntryPoint, chainID),
+			)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.EntryPoint, e.UserOp.Sender, e.UserOp.Nonce, admitted)
+		}
+	}
+	return tw.Flush()
+}
+
+// List writes a table of every UserOperation in the Store that matches the filter.
+func List(db storage.Store, flags FilterFlags, w io.Writer) error {
+	filter, err := flags.ToFilter()
+	if err != nil {
+		return err
+	}
+	entries, err := mempool.ReadEntries(db, filter)
+	if err != nil {
+		return err
+	}
+
+	return writeTable(w, entries, filter.ChainID)
+}
+
+// Export writes every UserOperation in the Store that matches the filter as NDJSON, one mempool.Entry per
+// line.
+func Export(db storage.Store, flags FilterFlags, w io.Writer) (int, error) {
+	filter, err := flags.ToFilter()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := mempool.ReadEntries(db, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// Import writes every mempool.Entry read as NDJSON into the Store. No entries are written if any line is
+// invalid.
+func Import(db storage.Store, r io.Reader) (int, error) {
+	entries := []*mempool.Entry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		e := &mempool.Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return 0, fmt.Errorf("mempool: line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := mempool.WriteEntries(db, entries...); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Delete removes every UserOperation in the Store that matches the filter and writes a table of them. At
+// least one filter must be set so that the mempool is not cleared by mistake.
+func Delete(db storage.Store, flags FilterFlags, w io.Writer) error {
+	if flags.IsEmpty() {
+		return errors.New("mempool: at least one of entrypoint, sender, or hash is required to delete")
+	}
+	filter, err := flags.ToFilter()
+	if err != nil {
+		return err
+	}
+	deleted, err := mempool.DeleteEntries(db, filter)
+	if err != nil {
+		return err
+	}
+
+	return writeTable(w, deleted, filter.ChainID)
+}
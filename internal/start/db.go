@@ -4,8 +4,27 @@ import (
 	"time"
 
 	badger "github.com/dgraph-io/badger/v3"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/config"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
 )
 
+// openStorage returns the Store used by the mempool and all modules. If InMemoryStorage is set, nothing is
+// written to disk and the Store is a storage.MemoryStore. Otherwise, the badger DB in the data directory is
+// opened and its value log is garbage collected in the background.
+func openStorage(conf *config.Values) (storage.Store, error) {
+	if conf.InMemoryStorage {
+		return storage.NewMemoryStore(), nil
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(conf.DataDirectory))
+	if err != nil {
+		return nil, err
+	}
+	runDBGarbageCollection(db)
+	return storage.NewBadgerStore(db), nil
+}
+
 func runDBGarbageCollection(db *badger.DB) {
 	go func(db *badger.DB) {
 		ticker := time.NewTicker(5 * time.Minute)
@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
-	"github.com/dgraph-io/badger/v3"
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gin-contrib/cors"
@@ -16,6 +17,7 @@ import (
 	"go.opentelemetry.io/otel"
 
 	"github.com/stackup-wallet/stackup-bundler/internal/config"
+	"github.com/stackup-wallet/stackup-bundler/internal/fakesolver"
 	"github.com/stackup-wallet/stackup-bundler/internal/logger"
 	"github.com/stackup-wallet/stackup-bundler/internal/o11y"
 	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
@@ -24,14 +26,17 @@ import (
 	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
 	"github.com/stackup-wallet/stackup-bundler/pkg/jsonrpc"
 	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/balance"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/batch"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/entities"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/expire"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/gasprice"
-	"github.com/stackup-wallet/stackup-bundler/pkg/modules/paymaster"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/profit"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/reconcile"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/relay"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/solution"
-	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
 )
 
 func PrivateMode() {
@@ -42,25 +47,29 @@ func PrivateMode() {
 		WithValues("bundler_mode", "private").
 		V(1)
 
-	eoa, err := signer.New(conf.PrivateKey)
+	store, err := openStorage(conf)
 	if err != nil {
 		log.Fatal(err)
 	}
-	beneficiary := common.HexToAddress(conf.Beneficiary)
+	defer store.Close()
 
-	db, err := badger.Open(badger.DefaultOptions(conf.DataDirectory))
+	rpc, err := rpc.Dial(conf.EthClientUrl)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
-	runDBGarbageCollection(db)
 
-	rpc, err := rpc.Dial(conf.EthClientUrl)
+	eth := ethclient.NewClient(rpc)
+
+	eoas, err := newSignerPool(conf, eth)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	eth := ethclient.NewClient(rpc)
+	eoa := eoas.EOAs()[0]
+	topUp, err := newBeneficiarySigner(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	beneficiary := getBeneficiary(conf, eoas, topUp)
 
 	chain, err := eth.ChainID(context.Background())
 	if err != nil {
@@ -75,7 +84,7 @@ func PrivateMode() {
 			InsecureMode:    conf.OTELInsecureMode,
 
 			ChainID: chain,
-			Address: eoa.Address,
+			Address: eoa.Account().Address,
 		}
 
 		tracerCleanup := o11y.InitTracer(o11yOpts)
@@ -86,6 +95,8 @@ func PrivateMode() {
 	}
 
 	ov := gas.NewDefaultOverhead()
+	pg := profit.New(conf.MinProfitMargin)
+	pg.SetEstimateGasFunc(profit.EstimateGasWithEthClient(eth, eoa.Account().Address, beneficiary))
 	if chain.Cmp(config.ArbitrumOneChainID) == 0 ||
 		chain.Cmp(config.ArbitrumGoerliChainID) == 0 ||
 		chain.Cmp(config.ArbitrumSepoliaChainID) == 0 {
@@ -104,12 +115,23 @@ func PrivateMode() {
 			gas.CalcOptimismPVGWithEthClient(rpc, chain, conf.SupportedEntryPoints[0]),
 		)
 		ov.SetPreVerificationGasBufferFactor(1)
+		pg.SetGetL1FeeFunc(profit.GetL1FeeWithOptimismOracle(rpc, chain))
 	}
 
-	mem, err := mempool.New(db)
+	mem, err := mempool.New(store)
 	if err != nil {
 		log.Fatal(err)
 	}
+	mem.SetCapacity(mempool.Capacity{
+		Global:        conf.MempoolMaxOps,
+		PerEntryPoint: conf.MempoolMaxOpsPerEP,
+		PerEntity:     conf.MempoolMaxOpsPerEntity,
+	})
+	mem.SetGetBaseFeeFunc(gasprice.GetBaseFeeWithEthClient(eth))
+	mem.UseLogger(logr)
+	if err := mem.UserMeter(otel.GetMeterProvider().Meter("mempool")); err != nil {
+		log.Fatal(err)
+	}
 
 	alt, err := altmempools.NewFromIPFS(chain, conf.AltMempoolIPFSGateway, conf.AltMempoolIds)
 	if err != nil {
@@ -117,7 +139,7 @@ func PrivateMode() {
 	}
 
 	check := checks.New(
-		db,
+		store,
 		rpc,
 		ov,
 		alt,
@@ -125,18 +147,82 @@ func PrivateMode() {
 		conf.MaxBatchGasLimit,
 		conf.MaxOpsForUnstakedSender,
 	)
+	check.SetReplacementPriceBump(conf.ReplacementPriceBump)
+
+	exp := expire.New(mem, conf.MaxOpTTL)
+
+	if conf.SolverFakeScript != "" {
+		script, err := fakesolver.LoadScript(conf.SolverFakeScript)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fs := fakesolver.New(script)
+		if conf.SolverFakePrivateKey != "" {
+			key, err := crypto.HexToECDSA(strings.TrimPrefix(conf.SolverFakePrivateKey, "0x"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			fs.SetSigningKey(key)
+			conf.SolverPublicKeys = []string{hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey))}
+		}
+		url, err := fs.Start(conf.SolverFakeAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logr.Info("running fake solver", "solver_url", url)
+		conf.SolverUrls = []string{url}
+	}
+
+	solver := solution.New(store, conf.SolverUrls)
+	solver.SetSolverTimeout(conf.SolverTimeout)
+	solver.SetMaxAttempts(conf.SolverMaxAttempts)
+	solver.SetRetryBackoff(conf.SolverRetryBackoff)
+	solver.SetFailureThreshold(conf.SolverFailureThreshold)
+	solver.SetBreakerCooldown(conf.SolverBreakerCooldown)
+	solver.SetHMACSecret([]byte(conf.SolverHMACSecret))
+	solver.SetBearerToken(conf.SolverBearerToken)
+	solver.UseLogger(logr)
+	score, err := solution.GetScoreFunc(conf.SolverScore)
+	if err != nil {
+		log.Fatal(err)
+	}
+	solver.SetScoreFunc(score)
+	solver.SetValidateFunc(check.ValidateSolvedOp())
+	for i, solverUrl := range conf.SolverUrls {
+		if len(conf.SolverPublicKeys) == 0 {
+			break
+		}
 
-	exp := expire.New(conf.MaxOpTTL)
+		key := conf.SolverPublicKeys[0]
+		if len(conf.SolverPublicKeys) > 1 {
+			key = conf.SolverPublicKeys[i]
+		}
+		if err := solver.SetSolverPublicKey(solverUrl, key); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := solver.UserMeter(otel.GetMeterProvider().Meter("solver")); err != nil {
+		log.Fatal(err)
+	}
 
-	println("solver URL:", conf.SolverUrl)
-	solver := solution.New(conf.SolverUrl)
-	if err := solution.ReportSolverHealth(conf.SolverUrl); err != nil {
+	if err := eoas.UserMeter(otel.GetMeterProvider().Meter("signer")); err != nil {
 		log.Fatal(err)
 	}
 
-	relayer := relay.New(eoa, eth, chain, beneficiary, logr)
+	relayer := relay.New(eoas, eth, chain, beneficiary, logr)
+	relayer.SetRebroadcastInterval(conf.RebroadcastInterval)
+
+	bm, err := newBalanceManager(conf, eoas, eth, chain, beneficiary, topUp, relayer.GetNonceManager)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bm.UseLogger(logr)
 
-	paymaster := paymaster.New(db)
+	rep := entities.New(store, mem)
+	rep.SetGetAggregatorFunc(check.GetAggregator)
+	history := intentstatus.New(store)
+	exp.SetIntentMaxTTL(conf.IntentMaxTTL)
+	exp.SetStatusHistory(history)
 
 	// Init Client
 	c := client.New(mem, ov, chain, conf.SupportedEntryPoints)
@@ -146,13 +232,33 @@ func PrivateMode() {
 		client.GetGasEstimateWithEthClient(rpc, ov, chain, conf.MaxBatchGasLimit),
 	)
 	c.SetGetUserOpByHashFunc(client.GetUserOpByHashWithEthClient(eth))
+	c.SetGetIntentStatusFunc(history.GetStatus)
+	c.SetResolveUserOpHashFunc(history.ResolveHash)
 	c.UseLogger(logr)
 	c.UseModules(
 		check.ValidateOpValues(),
-		paymaster.CheckStatus(),
 		check.SimulateOp(),
-		paymaster.IncOpsSeen(),
+		rep.CheckStatus(),
+		history.RecordReceived(),
 	)
+	c.UseAddedModules(
+		rep.IncOpsSeen(),
+		check.CleanReplacedOp(),
+	)
+	c.UseReAddModules(
+		check.ValidateOpValues(),
+		check.SimulateOp(),
+		rep.CheckStatus(),
+	)
+
+	// Init bundle reconciliation
+	rec := reconcile.New(store, eth, chain)
+	rec.SetConfirmations(conf.ReconcileConfirmations)
+	rec.SetReAddFunc(c.ReAddUserOperation)
+	rec.SetGetOpInfoFunc(mem.GetOpInfo)
+	rec.SetConfirmedFunc(history.RecordOnChain, mem.NotifyIncluded)
+	rec.UseLogger(logr)
+	relayer.SetReplacedFunc(rec.TrackReplacement)
 
 	// Init Bundler
 	b := bundler.New(mem, chain, conf.SupportedEntryPoints, conf.SolverUrl)
@@ -163,23 +269,45 @@ func PrivateMode() {
 	if err := b.UserMeter(otel.GetMeterProvider().Meter("bundler")); err != nil {
 		log.Fatal(err)
 	}
+	if err := bm.UserMeter(otel.GetMeterProvider().Meter("bundler")); err != nil {
+		log.Fatal(err)
+	}
 	b.UseModules(
 		exp.DropExpired(),
+		exp.DropExpiredIntents(),
+		rep.FilterByStatus(),
+		bm.CheckBalance(),
 		gasprice.SortByGasPrice(),
 		gasprice.FilterUnderpriced(),
 		batch.SortByNonce(),
 		batch.MaintainGasLimit(conf.MaxBatchGasLimit),
 		check.CodeHashes(),
 		check.PaymasterDeposit(),
-		solver.SolveIntents(),
+		check.SimulateSolvedIntents(),
+		check.AggregateSignatures(),
+		pg.CheckMargin(),
 		relayer.SendUserOperation(),
-		paymaster.IncOpsIncluded(),
+		rec.TrackBatch(),
+		history.RecordBatch(),
+		rep.IncOpsIncluded(),
 		check.Clean(),
 	)
 	if err := b.Run(); err != nil {
 		log.Fatal(err)
 	}
 
+	// Init background solving stage
+	st := solution.NewStage(solver, mem, chain, conf.SupportedEntryPoints)
+	st.SetHealthInterval(conf.SolverHealthInterval)
+	st.UseModules(exp.DropExpiredIntents())
+	st.UseLogger(logr)
+	if err := st.Run(); err != nil {
+		log.Fatal(err)
+	}
+	if err := rec.Run(); err != nil {
+		log.Fatal(err)
+	}
+
 	// init Debug
 	var d *client.Debug
 	if conf.DebugMode {
@@ -205,12 +333,25 @@ func PrivateMode() {
 	r.GET("/ping", func(g *gin.Context) {
 		g.Status(http.StatusOK)
 	})
+	r.GET("/health", func(g *gin.Context) {
+		status := bm.Status()
+		if status != balance.StatusOK {
+			g.JSON(http.StatusServiceUnavailable, gin.H{"status": status})
+			return
+		}
+		g.JSON(http.StatusOK, gin.H{"status": status})
+	})
 	handlers := []gin.HandlerFunc{
 		jsonrpc.Controller(client.NewRpcAdapter(c, d), rpc, eth),
 		jsonrpc.WithOTELTracerAttributes(),
 	}
 	r.POST("/", handlers...)
 	r.POST("/rpc", handlers...)
+	r.GET("/ws", jsonrpc.WebSocketController(c.Subscribe, &jsonrpc.WebSocketOpts{
+		AllowedOrigins:   conf.WSAllowedOrigins,
+		WriteTimeout:     conf.WSWriteTimeout,
+		MaxSubscriptions: conf.WSMaxSubscriptions,
+	}))
 
 	if err := r.Run(fmt.Sprintf(":%d", conf.Port)); err != nil {
 		log.Fatal(err)
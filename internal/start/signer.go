@@ -0,0 +1,110 @@
+package start
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/config"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/balance"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
+)
+
+// newSignerPool returns a pool of all signers configured from private keys, keystore files, and a remote
+// signer.
+func newSignerPool(conf *config.Values, eth *ethclient.Client) (*signer.Pool, error) {
+	signers := []signer.Signer{}
+	for _, pk := range conf.PrivateKeys {
+		s, err := signer.New(pk)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, s)
+	}
+	for _, file := range conf.KeystoreFiles {
+		s, err := signer.NewFromKeystore(file, conf.KeystorePasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, s)
+	}
+	for _, addr := range conf.RemoteSignerAddresses {
+		s, err := signer.NewRemote(conf.RemoteSignerUrl, common.HexToAddress(addr))
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, s)
+	}
+
+	return signer.NewPool(eth, signers...)
+}
+
+// newBeneficiarySigner returns the signer of the beneficiary used for top-ups. Like the bundler EOAs, it is
+// loaded from a private key, a keystore file, or the remote signer. A nil Signer is returned if none is set.
+func newBeneficiarySigner(conf *config.Values) (signer.Signer, error) {
+	switch {
+	case conf.BeneficiaryPrivateKey != "":
+		return signer.New(conf.BeneficiaryPrivateKey)
+	case conf.BeneficiaryKeystoreFile != "":
+		return signer.NewFromKeystore(conf.BeneficiaryKeystoreFile, conf.KeystorePasswordFile)
+	case conf.BeneficiaryRemote:
+		return signer.NewRemote(conf.RemoteSignerUrl, common.HexToAddress(conf.Beneficiary))
+	default:
+		return nil, nil
+	}
+}
+
+// getBeneficiary returns the configured beneficiary address. It defaults to the address of the beneficiary
+// signer if one is set and otherwise to the first EOA in the pool.
+func getBeneficiary(conf *config.Values, eoas *signer.Pool, topUp signer.Signer) common.Address {
+	if conf.Beneficiary != "" {
+		return common.HexToAddress(conf.Beneficiary)
+	} else if topUp != nil {
+		return topUp.Account().Address
+	}
+	return eoas.EOAs()[0].Account().Address
+}
+
+// newBalanceManager returns a balance manager for the pool with the configured water marks. EOAs below the
+// low water mark are no longer leased. Top-ups are enabled if the beneficiary signer is not nil. If the
+// beneficiary is also an EOA in the pool, top-ups are sent with the NonceManager returned by nonces and an error
+// is returned if there is none.
+func newBalanceManager(
+	conf *config.Values,
+	eoas *signer.Pool,
+	eth *ethclient.Client,
+	chainID *big.Int,
+	beneficiary common.Address,
+	topUp signer.Signer,
+	nonces func(eoa common.Address) *transaction.NonceManager,
+) (*balance.Manager, error) {
+	eoas.SetMinBalance(conf.EOALowBalance)
+	bm := balance.New(eoas, eth, chainID)
+	bm.SetLowWaterMark(conf.EOALowBalance)
+	bm.SetHighWaterMark(conf.EOAHighBalance)
+	if topUp == nil {
+		return bm, nil
+	}
+
+	if addr := topUp.Account().Address; addr != beneficiary {
+		return nil, fmt.Errorf("beneficiary signer is for %s, want %s", addr, beneficiary)
+	}
+	bm.SetTopUpSigner(topUp)
+	for _, eoa := range eoas.EOAs() {
+		if eoa.Account().Address != beneficiary {
+			continue
+		}
+		var nm *transaction.NonceManager
+		if nonces != nil {
+			nm = nonces(beneficiary)
+		}
+		if nm == nil {
+			return nil, fmt.Errorf("beneficiary %s is a pool EOA without a nonce manager for top-ups", beneficiary)
+		}
+		bm.SetTopUpNonceManager(nm)
+	}
+	return bm, nil
+}
@@ -4,6 +4,8 @@ import (
 	"log"
 
 	badger "github.com/dgraph-io/badger/v3"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
 )
 
 func DBMock() *badger.DB {
@@ -14,3 +16,8 @@ func DBMock() *badger.DB {
 
 	return db
 }
+
+// StoreMock returns an empty in-memory storage.Store.
+func StoreMock() storage.Store {
+	return storage.NewMemoryStore()
+}
@@ -22,7 +22,8 @@ var (
 		"preVerificationGas":   "0xc539",
 		"signature":            "0xa925dcc5e5131636e244d4405334c25f034ebdd85c0cb12e8cdb13c15249c2d466d0bade18e2cafd3513497f7f968dcbb63e519acd9b76dcae7acd61f11aa8421b",
 	}
-	MockByteCode = common.Hex2Bytes("6080604052")
+	MockByteCode   = common.Hex2Bytes("6080604052")
+	MockIntentJSON = `{"sender":"0xa13D69573f994bf662C2714560c44dd7266FC547","from":{"type":"TOKEN","address":"0x0000000000000000000000000000000000000000","amount":"1","chainId":"1"},"to":{"type":"TOKEN","address":"0xdAC17F958D2ee523a2206206994597C13D831ec7","amount":"1","chainId":"1"}}`
 )
 
 // Returns a valid initial userOperation for an EIP-4337 account.
@@ -46,3 +47,11 @@ func GetOpsDiff(op1 *userop.UserOperation, op2 *userop.UserOperation) string {
 		cmp.Comparer(func(a *big.Int, b *big.Int) bool { return a.Cmp(b) == 0 }),
 	)
 }
+
+// Returns a valid unsolved Intent userOperation with the Intent JSON set in the callData field.
+func MockValidIntentUserOp() *userop.UserOperation {
+	op := MockValidInitUserOp()
+	op.InitCode = []byte{}
+	op.CallData = []byte(MockIntentJSON)
+	return op
+}
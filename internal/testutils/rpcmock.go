@@ -11,6 +11,7 @@ type mockReq struct {
 	JsonRpc string  `json:"jsonrpc"`
 	ID      float64 `json:"id"`
 	Method  string  `json:"method"`
+	Params  []any   `json:"params"`
 }
 
 type mockRes struct {
@@ -21,6 +22,9 @@ type mockRes struct {
 
 type MethodMocks map[string]any
 
+// MethodMockFunc can be used as a value in MethodMocks to return a result that depends on the request params.
+type MethodMockFunc func(params []any) any
+
 func RpcMock(mocks MethodMocks) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var req mockReq
@@ -36,6 +40,9 @@ func RpcMock(mocks MethodMocks) *httptest.Server {
 			return
 		}
 
+		if fn, ok := mock.(MethodMockFunc); ok {
+			mock = fn(req.Params)
+		}
 		res := &mockRes{
 			JsonRpc: req.JsonRpc,
 			ID:      req.ID,
@@ -0,0 +1,15 @@
+package testutils
+
+import (
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
+)
+
+// DummyEOAPool returns a signer.Pool that only holds the DummyEOA.
+func DummyEOAPool(eth *ethclient.Client) *signer.Pool {
+	p, err := signer.NewPool(eth, DummyEOA)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
@@ -0,0 +1,14 @@
+package testutils
+
+import (
+	"net/http/httptest"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/fakesolver"
+)
+
+// FakeSolverMock returns a Solver that responds with the scripted results for each Intent hash. The returned
+// fakesolver.Solver can be used to inspect the number of requests received per Intent.
+func FakeSolverMock(script *fakesolver.Script) (*httptest.Server, *fakesolver.Solver) {
+	s := fakesolver.New(script)
+	return httptest.NewServer(s), s
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"math/big"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -131,6 +132,18 @@ func (i *Bundler) Process(ep common.Address) (*modules.BatchHandlerCtx, error) {
 		l.Error(err, "bundler run error")
 		return nil, err
 	}
+
+	// Unsolved intents are left in the mempool for the background solving stage. Only userOps that are ready
+	// to be sent to the EntryPoint are batched.
+	ready := make([]*userop.UserOperation, 0, len(batch))
+	for _, op := range batch {
+		if op.IsUnsolvedIntent() {
+			continue
+		}
+
+		ready = append(ready, op)
+	}
+	batch = ready
 	if len(batch) == 0 {
 		return nil, nil
 	}
@@ -179,8 +192,11 @@ func (i *Bundler) Process(ep common.Address) (*modules.BatchHandlerCtx, error) {
 
 		rmOps = append(rmOps, remainingOp)
 	}
-	rmOps = append(rmOps, ctx.PendingRemoval...)
-	if err := i.mempool.RemoveOps(ep, rmOps...); err != nil {
+	if err := i.mempool.RemoveOpsWithReason(ep, mempool.ReasonBundled, rmOps...); err != nil {
+		l.Error(err, "bundler run error")
+		return nil, err
+	}
+	if err := i.mempool.RemoveOpsWithReason(ep, mempool.ReasonDropped, ctx.PendingRemoval...); err != nil {
 		l.Error(err, "bundler run error")
 		return nil, err
 	}
@@ -219,13 +235,18 @@ func (i *Bundler) Run() error {
 			case <-i.done:
 				return
 			case <-ticker.C:
+				// Each EntryPoint is processed concurrently so that a batch pending with one EOA does not
+				// block the next batch from being sent with another EOA.
+				var wg sync.WaitGroup
 				for _, ep := range i.supportedEntryPoints {
-					_, err := i.Process(ep)
-					if err != nil {
-						// Already logged.
-						continue
-					}
+					wg.Add(1)
+					go func(ep common.Address) {
+						defer wg.Done()
+						// Errors are already logged.
+						_, _ = i.Process(ep)
+					}(ep)
 				}
+				wg.Wait()
 			}
 		}
 	}(i)
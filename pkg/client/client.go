@@ -14,6 +14,7 @@ import (
 	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
 	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/noop"
 	"github.com/stackup-wallet/stackup-bundler/pkg/state"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
@@ -27,11 +28,15 @@ type Client struct {
 	chainID              *big.Int
 	supportedEntryPoints []common.Address
 	userOpHandler        modules.UserOpHandlerFunc
+	addedHandler         modules.UserOpHandlerFunc
+	reAddHandler         modules.UserOpHandlerFunc
 	logger               logr.Logger
 	getUserOpReceipt     GetUserOpReceiptFunc
 	getGasPrices         GetGasPricesFunc
 	getGasEstimate       GetGasEstimateFunc
 	getUserOpByHash      GetUserOpByHashFunc
+	getIntentStatus      GetIntentStatusFunc
+	resolveUserOpHash    ResolveUserOpHashFunc
 }
 
 // New initializes a new ERC-4337 client which can be extended with modules for validating UserOperations
@@ -48,11 +53,15 @@ func New(
 		chainID:              chainID,
 		supportedEntryPoints: supportedEntryPoints,
 		userOpHandler:        noop.UserOpHandler,
+		addedHandler:         noop.UserOpHandler,
+		reAddHandler:         noop.UserOpHandler,
 		logger:               logger.NewZeroLogr().WithName("client"),
 		getUserOpReceipt:     getUserOpReceiptNoop(),
 		getGasPrices:         getGasPricesNoop(),
 		getGasEstimate:       getGasEstimateNoop(),
 		getUserOpByHash:      getUserOpByHashNoop(),
+		getIntentStatus:      getIntentStatusNoop(),
+		resolveUserOpHash:    resolveUserOpHashNoop(),
 	}
 }
 
@@ -76,6 +85,20 @@ func (i *Client) UseModules(handlers ...modules.UserOpHandlerFunc) {
 	i.userOpHandler = modules.ComposeUserOpHandlerFunc(handlers...)
 }
 
+// UseAddedModules defines the UserOpHandlers to process a userOp once it has been added to the mempool. These
+// are for side effects that must not happen if the userOp is rejected (e.g. cleaning up data of a replaced
+// userOp). Errors are logged but do not reject the userOp since it is already in the mempool.
+func (i *Client) UseAddedModules(handlers ...modules.UserOpHandlerFunc) {
+	i.addedHandler = modules.ComposeUserOpHandlerFunc(handlers...)
+}
+
+// UseReAddModules defines the UserOpHandlers to validate a userOp again before it is put back into the mempool
+// by ReAddUserOperation. These should only check the userOp since side effects of the original submission
+// (e.g. recording the Received status) have already been applied.
+func (i *Client) UseReAddModules(handlers ...modules.UserOpHandlerFunc) {
+	i.reAddHandler = modules.ComposeUserOpHandlerFunc(handlers...)
+}
+
 // SetGetUserOpReceiptFunc defines a general function for fetching a UserOpReceipt given a userOpHash and
 // EntryPoint address. This function is called in *Client.GetUserOperationReceipt.
 func (i *Client) SetGetUserOpReceiptFunc(fn GetUserOpReceiptFunc) {
@@ -102,6 +125,19 @@ func (i *Client) SetGetUserOpByHashFunc(fn GetUserOpByHashFunc) {
 	i.getUserOpByHash = fn
 }
 
+// SetGetIntentStatusFunc defines a general function for fetching the status transition history of an Intent
+// given its original userOpHash. This function is called in *Client.GetIntentStatus.
+func (i *Client) SetGetIntentStatusFunc(fn GetIntentStatusFunc) {
+	i.getIntentStatus = fn
+}
+
+// SetResolveUserOpHashFunc defines a general function for mapping the userOpHash of a solved Intent to the
+// userOpHash of the operation sent on-chain. This function is called in *Client.GetUserOperationReceipt and
+// *Client.GetUserOperationByHash.
+func (i *Client) SetResolveUserOpHashFunc(fn ResolveUserOpHashFunc) {
+	i.resolveUserOpHash = fn
+}
+
 // SendUserOperation implements the method call for eth_sendUserOperation.
 // It returns true if userOp was accepted otherwise returns an error.
 func (i *Client) SendUserOperation(op map[string]any, ep string) (string, error) {
@@ -141,15 +177,38 @@ func (i *Client) SendUserOperation(op map[string]any, ep string) (string, error)
 	}
 
 	// Add userOp to mempool.
-	if err := i.mempool.AddOp(epAddr, ctx.UserOp); err != nil {
+	if err := i.mempool.AddOpWithValidUntil(epAddr, ctx.UserOp, ctx.GetValidUntil()); err != nil {
 		l.Error(err, "eth_sendUserOperation error")
 		return "", err
 	}
+	if err := i.addedHandler(ctx); err != nil {
+		l.Error(err, "eth_sendUserOperation added modules error")
+	}
 
 	l.Info("eth_sendUserOperation ok")
 	return hash.String(), nil
 }
 
+// ReAddUserOperation puts a userOp that was previously accepted back into the mempool after validating it
+// again with the ReAdd modules (e.g. a userOp from a bundle transaction that was dropped, failed, or reorged).
+// The OpInfo the userOp had in the mempool is kept so that it does not get a new admission time. A zero
+// ValidUntil is replaced by the one returned from simulation.
+func (i *Client) ReAddUserOperation(ep common.Address, op *userop.UserOperation, info mempool.OpInfo) error {
+	penOps, err := i.mempool.GetOps(ep, op.Sender)
+	if err != nil {
+		return err
+	}
+
+	ctx := modules.NewUserOpHandlerContext(op, penOps, ep, i.chainID)
+	if err := i.reAddHandler(ctx); err != nil {
+		return err
+	}
+	if info.ValidUntil.IsZero() {
+		info.ValidUntil = ctx.GetValidUntil()
+	}
+	return i.mempool.AddOpWithInfo(ep, ctx.UserOp, info)
+}
+
 // EstimateUserOperationGas returns estimates for PreVerificationGas, VerificationGasLimit, and CallGasLimit
 // given a UserOperation, EntryPoint address, and state OverrideSet. The signature field and current gas
 // values will not be validated although there should be dummy values in place for the most reliable results
@@ -252,7 +311,13 @@ func (i *Client) GetUserOperationReceipt(
 		return &r, nil
 	}
 
-	ev, err := i.getUserOpReceipt(hash, i.supportedEntryPoints[0])
+	resolved, err := i.resolveUserOpHash(hash)
+	if err != nil {
+		l.Error(err, "eth_getUserOperationReceipt error")
+		return nil, err
+	}
+
+	ev, err := i.getUserOpReceipt(resolved, i.supportedEntryPoints[0])
 	if err != nil {
 		l.Error(err, "eth_getUserOperationReceipt error")
 		return nil, err
@@ -268,12 +333,33 @@ func (i *Client) GetUserOperationByHash(hash string) (*filter.HashLookupResult,
 	// Init logger
 	l := i.logger.WithName("eth_getUserOperationByHash").WithValues("userop_hash", hash)
 
-	res, err := i.getUserOpByHash(hash, i.supportedEntryPoints[0], i.chainID)
+	resolved, err := i.resolveUserOpHash(hash)
 	if err != nil {
 		l.Error(err, "eth_getUserOperationByHash error")
 		return nil, err
 	}
 
+	res, err := i.getUserOpByHash(resolved, i.supportedEntryPoints[0], i.chainID)
+	if err != nil {
+		l.Error(err, "eth_getUserOperationByHash error")
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetIntentStatus returns every ProcessingStatus transition of an Intent based on the userOpHash returned by
+// *Client.SendUserOperation. A nil result is returned if the Intent is unknown.
+func (i *Client) GetIntentStatus(hash string) (*intentstatus.Status, error) {
+	// Init logger
+	l := i.logger.WithName("eth_getIntentStatus").WithValues("userop_hash", hash)
+
+	res, err := i.getIntentStatus(hash)
+	if err != nil {
+		l.Error(err, "eth_getIntentStatus error")
+		return nil, err
+	}
+
 	return res, nil
 }
 
@@ -1,7 +1,6 @@
 package client
 
 import (
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,7 +16,7 @@ import (
 
 // Debug exposes methods used for testing the bundler. These should not be made available in production.
 type Debug struct {
-	eoa         *signer.EOA
+	eoa         signer.Signer
 	eth         *ethclient.Client
 	mempool     *mempool.Mempool
 	bundler     *bundler.Bundler
@@ -27,7 +26,7 @@ type Debug struct {
 }
 
 func NewDebug(
-	eoa *signer.EOA,
+	eoa signer.Signer,
 	eth *ethclient.Client,
 	mempool *mempool.Mempool,
 	bundler *bundler.Bundler,
@@ -117,10 +116,7 @@ func (d *Debug) DumpDebugInfo() (map[string]any, error) {
 	info["mempool"] = mempool
 
 	// Dump EOA
-	eoaPrvKey := hex.EncodeToString(d.eoa.PrivateKey.D.Bytes())
-	eoaAddress := d.eoa.Address.String()
-	info["eoaPrivateKey"] = eoaPrvKey
-	info["eoaAddress"] = eoaAddress
+	info["eoaAddress"] = d.eoa.Account().Address.String()
 
 	// Dump chainID
 	info["chainID"] = d.chainID.String()
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/filter"
 	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
 )
 
 // Named UserOperation type for jsonrpc package.
@@ -52,6 +53,11 @@ func (r *RpcAdapter) Eth_getUserOperationByHash(
 	return r.client.GetUserOperationByHash(userOpHash)
 }
 
+// Eth_getIntentStatus routes method calls to *Client.GetIntentStatus.
+func (r *RpcAdapter) Eth_getIntentStatus(userOpHash string) (*intentstatus.Status, error) {
+	return r.client.GetIntentStatus(userOpHash)
+}
+
 // Eth_supportedEntryPoints routes method calls to *Client.SupportedEntryPoints.
 func (r *RpcAdapter) Eth_supportedEntryPoints() ([]string, error) {
 	return r.client.SupportedEntryPoints()
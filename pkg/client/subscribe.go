@@ -0,0 +1,162 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+const (
+	// SubscriptionNewPending streams every UserOperation added to the mempool, including replacements.
+	SubscriptionNewPending = "newPendingUserOperations"
+
+	// SubscriptionDropped streams every UserOperation removed from the mempool without being bundled along
+	// with the reason.
+	SubscriptionDropped = "droppedUserOperations"
+
+	// SubscriptionIncluded streams the receipt of every UserOperation confirmed on-chain.
+	SubscriptionIncluded = "includedUserOperations"
+)
+
+// PendingUserOpNotification is the result of a SubscriptionNewPending notification.
+type PendingUserOpNotification struct {
+	EntryPoint    string                `json:"entryPoint"`
+	UserOpHash    string                `json:"userOpHash"`
+	UserOperation *userop.UserOperation `json:"userOperation"`
+	Replaced      bool                  `json:"replaced"`
+}
+
+// DroppedUserOpNotification is the result of a SubscriptionDropped notification.
+type DroppedUserOpNotification struct {
+	EntryPoint string `json:"entryPoint"`
+	UserOpHash string `json:"userOpHash"`
+	Reason     string `json:"reason"`
+}
+
+// IncludedUserOpNotification is the result of a SubscriptionIncluded notification. Receipt is nil if it could
+// not be fetched.
+type IncludedUserOpNotification struct {
+	EntryPoint      string `json:"entryPoint"`
+	UserOpHash      string `json:"userOpHash"`
+	TransactionHash string `json:"transactionHash"`
+	Receipt         any    `json:"receipt"`
+}
+
+type subscriptionFilter struct {
+	entryPoint *common.Address
+	sender     *common.Address
+	paymaster  *common.Address
+}
+
+func parseSubscriptionFilter(filter map[string]any) (*subscriptionFilter, error) {
+	f := &subscriptionFilter{}
+	for key, dst := range map[string]**common.Address{
+		"entryPoint": &f.entryPoint,
+		"sender":     &f.sender,
+		"paymaster":  &f.paymaster,
+	} {
+		val, ok := filter[key]
+		if !ok {
+			continue
+		}
+
+		s, ok := val.(string)
+		if !ok || !common.IsHexAddress(s) {
+			msg := fmt.Sprintf("subscription: filter %s must be an address", key)
+			return nil, errors.NewRPCError(errors.INVALID_FIELDS, msg, msg)
+		}
+		addr := common.HexToAddress(s)
+		*dst = &addr
+	}
+	return f, nil
+}
+
+func (f *subscriptionFilter) match(ev *mempool.Event) bool {
+	return (f.entryPoint == nil || *f.entryPoint == ev.EntryPoint) &&
+		(f.sender == nil || *f.sender == ev.UserOp.Sender) &&
+		(f.paymaster == nil || *f.paymaster == ev.UserOp.GetPaymaster())
+}
+
+func (i *Client) toNotification(kind string, ev *mempool.Event) any {
+	hash := ev.UserOp.GetUserOpHash(ev.EntryPoint, i.chainID).String()
+	switch {
+	case kind == SubscriptionNewPending && (ev.Type == mempool.EventAdded || ev.Type == mempool.EventReplaced):
+		return &PendingUserOpNotification{
+			EntryPoint:    ev.EntryPoint.String(),
+			UserOpHash:    hash,
+			UserOperation: ev.UserOp,
+			Replaced:      ev.Type == mempool.EventReplaced,
+		}
+
+	case kind == SubscriptionDropped && ev.Type == mempool.EventRemoved && ev.Reason != mempool.ReasonBundled:
+		return &DroppedUserOpNotification{
+			EntryPoint: ev.EntryPoint.String(),
+			UserOpHash: hash,
+			Reason:     ev.Reason,
+		}
+
+	case kind == SubscriptionIncluded && ev.Type == mempool.EventIncluded:
+		n := &IncludedUserOpNotification{
+			EntryPoint:      ev.EntryPoint.String(),
+			UserOpHash:      hash,
+			TransactionHash: ev.TxHash.String(),
+		}
+		if receipt, err := i.getUserOpReceipt(hash, ev.EntryPoint); err != nil {
+			i.logger.Error(err, "subscription receipt error", "userop_hash", hash)
+		} else if receipt != nil {
+			n.Receipt = receipt
+		}
+		return n
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel of notifications for the given subscription kind and a function to
+// unsubscribe. Notifications can be filtered by the "entryPoint", "sender", and "paymaster" addresses in
+// filter. The channel is closed once unsubscribed.
+func (i *Client) Subscribe(kind string, filter map[string]any) (<-chan any, func(), error) {
+	if kind != SubscriptionNewPending && kind != SubscriptionDropped && kind != SubscriptionIncluded {
+		msg := fmt.Sprintf("subscription: unsupported kind %s", kind)
+		return nil, nil, errors.NewRPCError(errors.INVALID_FIELDS, msg, msg)
+	}
+	f, err := parseSubscriptionFilter(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events, unsubscribe := i.mempool.Subscribe()
+	out := make(chan any)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for ev := range events {
+			if !f.match(ev) {
+				continue
+			}
+			n := i.toNotification(kind, ev)
+			if n == nil {
+				continue
+			}
+
+			select {
+			case out <- n:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/filter"
 	"github.com/stackup-wallet/stackup-bundler/pkg/fees"
 	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
 	"github.com/stackup-wallet/stackup-bundler/pkg/state"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
@@ -115,3 +116,24 @@ func GetUserOpByHashWithEthClient(eth *ethclient.Client) GetUserOpByHashFunc {
 		return filter.GetUserOperationByHash(eth, hash, ep, chain)
 	}
 }
+
+// GetIntentStatusFunc is a general interface for fetching the status transition history of an Intent given
+// its original userOpHash.
+type GetIntentStatusFunc = func(hash string) (*intentstatus.Status, error)
+
+func getIntentStatusNoop() GetIntentStatusFunc {
+	return func(hash string) (*intentstatus.Status, error) {
+		return nil, nil
+	}
+}
+
+// ResolveUserOpHashFunc is a general interface for mapping the userOpHash returned by
+// *Client.SendUserOperation to the userOpHash of the operation that is sent on-chain. The two differ for
+// solved Intents.
+type ResolveUserOpHashFunc = func(hash string) (string, error)
+
+func resolveUserOpHashNoop() ResolveUserOpHashFunc {
+	return func(hash string) (string, error) {
+		return hash, nil
+	}
+}
@@ -0,0 +1,65 @@
+// Package aggregator provides calls to signature aggregator contracts for UserOperations that are validated
+// with an aggregated signature.
+package aggregator
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/methods"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+func toAbiType(ops []*userop.UserOperation) []entrypoint.UserOperation {
+	arr := []entrypoint.UserOperation{}
+	for _, op := range ops {
+		arr = append(arr, entrypoint.UserOperation(*op))
+	}
+	return arr
+}
+
+// AggregateSignatures calls aggregateSignatures on the aggregator and returns a single signature for all the
+// given UserOperations.
+func AggregateSignatures(
+	eth *ethclient.Client,
+	aggregator common.Address,
+	ops []*userop.UserOperation,
+) ([]byte, error) {
+	args, err := methods.AggregateSignaturesMethod.Inputs.Pack(toAbiType(ops))
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := eth.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &aggregator,
+		Data: append(methods.AggregateSignaturesMethod.ID, args...),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return methods.DecodeAggregateSignaturesOutput(out)
+}
+
+// ValidateSignatures calls validateSignatures on the aggregator. A nil error means the aggregated signature
+// is valid for all the given UserOperations.
+func ValidateSignatures(
+	eth *ethclient.Client,
+	aggregator common.Address,
+	ops []*userop.UserOperation,
+	signature []byte,
+) error {
+	args, err := methods.ValidateSignaturesMethod.Inputs.Pack(toAbiType(ops), signature)
+	if err != nil {
+		return err
+	}
+
+	_, err = eth.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &aggregator,
+		Data: append(methods.ValidateSignaturesMethod.ID, args...),
+	}, nil)
+	return err
+}
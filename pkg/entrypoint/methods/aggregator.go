@@ -0,0 +1,59 @@
+package methods
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+var (
+	AggregateSignaturesMethod = abi.NewMethod(
+		"aggregateSignatures",
+		"aggregateSignatures",
+		abi.Function,
+		"view",
+		false,
+		false,
+		abi.Arguments{
+			{Name: "userOps", Type: userop.UserOpArr},
+		},
+		abi.Arguments{
+			{Name: "aggregatedSignature", Type: bytes},
+		},
+	)
+	AggregateSignaturesSelector = hexutil.Encode(AggregateSignaturesMethod.ID)
+
+	ValidateSignaturesMethod = abi.NewMethod(
+		"validateSignatures",
+		"validateSignatures",
+		abi.Function,
+		"view",
+		false,
+		false,
+		abi.Arguments{
+			{Name: "userOps", Type: userop.UserOpArr},
+			{Name: "signature", Type: bytes},
+		},
+		nil,
+	)
+	ValidateSignaturesSelector = hexutil.Encode(ValidateSignaturesMethod.ID)
+)
+
+func DecodeAggregateSignaturesOutput(data []byte) ([]byte, error) {
+	args, err := AggregateSignaturesMethod.Outputs.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("aggregateSignatures: %s", err)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("aggregateSignatures: invalid args length: expected 1, got %d", len(args))
+	}
+
+	sig, ok := args[0].([]byte)
+	if !ok {
+		return nil, errors.New("aggregateSignatures: cannot assert type: signature is not of type []byte")
+	}
+	return sig, nil
+}
@@ -25,11 +25,20 @@ type StakeInfo struct {
 	UnstakeDelaySec *big.Int `json:"unstakeDelaySec"`
 }
 
+type AggregatorStakeInfo struct {
+	Aggregator common.Address `json:"aggregator"`
+	StakeInfo  *StakeInfo     `json:"stakeInfo"`
+}
+
 type ValidationResultRevert struct {
 	ReturnInfo    *ReturnInfo
 	SenderInfo    *StakeInfo
 	FactoryInfo   *StakeInfo
 	PaymasterInfo *StakeInfo
+
+	// AggregatorInfo is only set if the account uses a signature aggregator (i.e. simulateValidation reverted
+	// with ValidationResultWithAggregation).
+	AggregatorInfo *AggregatorStakeInfo
 }
 
 var (
@@ -45,6 +54,10 @@ var (
 		{Name: "stake", Type: "uint256"},
 		{Name: "unstakeDelaySec", Type: "uint256"},
 	}
+	aggregatorStakeInfoType = []abi.ArgumentMarshaling{
+		{Name: "aggregator", Type: "address"},
+		{Name: "stakeInfo", Type: "tuple", Components: stakeInfoType},
+	}
 )
 
 func validationResult() abi.Error {
@@ -61,6 +74,36 @@ func validationResult() abi.Error {
 	})
 }
 
+func validationResultWithAggregation() abi.Error {
+	returnInfo, _ := abi.NewType("tuple", "ReturnInfo", returnInfoType)
+	senderInfo, _ := abi.NewType("tuple", "SenderInfo", stakeInfoType)
+	factoryInfo, _ := abi.NewType("tuple", "FactoryInfo", stakeInfoType)
+	paymasterInfo, _ := abi.NewType("tuple", "PaymasterInfo", stakeInfoType)
+	aggregatorInfo, _ := abi.NewType("tuple", "AggregatorInfo", aggregatorStakeInfoType)
+
+	return abi.NewError("ValidationResultWithAggregation", abi.Arguments{
+		{Name: "returnInfo", Type: returnInfo},
+		{Name: "senderInfo", Type: senderInfo},
+		{Name: "factoryInfo", Type: factoryInfo},
+		{Name: "paymasterInfo", Type: paymasterInfo},
+		{Name: "aggregatorInfo", Type: aggregatorInfo},
+	})
+}
+
+// unmarshalArg converts an unpacked ABI tuple to the given struct.
+func unmarshalArg(arg any, v any) error {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return fmt.Errorf("validationResult: %s", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("validationResult: %s", err)
+	}
+	return nil
+}
+
+// NewValidationResult decodes the revert of simulateValidation into a ValidationResultRevert. Both the
+// ValidationResult and ValidationResultWithAggregation errors are supported.
 func NewValidationResult(err error) (*ValidationResultRevert, error) {
 	rpcErr, ok := err.(rpc.DataError)
 	if !ok {
@@ -72,60 +115,51 @@ func NewValidationResult(err error) (*ValidationResultRevert, error) {
 		return nil, errors.New("validationResult: cannot assert type: data is not of type string")
 	}
 
-	sim := validationResult()
-	revert, err := sim.Unpack(common.Hex2Bytes(data[2:]))
+	raw := common.Hex2Bytes(data[2:])
+	expected := 4
+	sim, agg := validationResult(), validationResultWithAggregation()
+	revert, err := sim.Unpack(raw)
 	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+		var aggErr error
+		revert, aggErr = agg.Unpack(raw)
+		if aggErr != nil {
+			return nil, fmt.Errorf("validationResult: %s", err)
+		}
+		expected = 5
 	}
 
 	args, ok := revert.([]any)
 	if !ok {
 		return nil, errors.New("validationResult: cannot assert type: args is not of type []any")
 	}
-	if len(args) != 4 {
-		return nil, fmt.Errorf("validationResult: invalid args length: expected 4, got %d", len(args))
+	if len(args) != expected {
+		return nil, fmt.Errorf("validationResult: invalid args length: expected %d, got %d", expected, len(args))
 	}
 
-	returnInfo := &ReturnInfo{}
-	ri, err := json.Marshal(args[0])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	res := &ValidationResultRevert{
+		ReturnInfo:    &ReturnInfo{},
+		SenderInfo:    &StakeInfo{},
+		FactoryInfo:   &StakeInfo{},
+		PaymasterInfo: &StakeInfo{},
 	}
-	if err := json.Unmarshal(ri, returnInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[0], res.ReturnInfo); err != nil {
+		return nil, err
 	}
-
-	senderInfo := &StakeInfo{}
-	si, err := json.Marshal(args[1])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
-	}
-	if err := json.Unmarshal(si, senderInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[1], res.SenderInfo); err != nil {
+		return nil, err
 	}
-
-	factoryInfo := &StakeInfo{}
-	fi, err := json.Marshal(args[2])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[2], res.FactoryInfo); err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(fi, factoryInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
-	}
-
-	paymasterInfo := &StakeInfo{}
-	pmi, err := json.Marshal(args[3])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[3], res.PaymasterInfo); err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(pmi, paymasterInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if expected == 5 {
+		res.AggregatorInfo = &AggregatorStakeInfo{StakeInfo: &StakeInfo{}}
+		if err := unmarshalArg(args[4], res.AggregatorInfo); err != nil {
+			return nil, err
+		}
 	}
 
-	return &ValidationResultRevert{
-		ReturnInfo:    returnInfo,
-		SenderInfo:    senderInfo,
-		FactoryInfo:   factoryInfo,
-		PaymasterInfo: paymasterInfo,
-	}, nil
+	return res, nil
 }
@@ -0,0 +1,94 @@
+package transaction
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/aggregator"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// GroupByAggregator returns the batch ordered by aggregator and the aggregator of each group. UserOps without
+// an aggregator come first, followed by each aggregator in the order it first appears. The order within a
+// group is unchanged. Since the EntryPoint reports the index of a failed userOp across all groups, a batch in
+// this order can be matched directly to FailedOp reverts from handleAggregatedOps.
+func GroupByAggregator(
+	batch []*userop.UserOperation,
+	aggregators map[common.Hash]common.Address,
+	ep common.Address,
+	chainID *big.Int,
+) (ordered []*userop.UserOperation, groups []common.Address, groupOps [][]*userop.UserOperation) {
+	idx := map[common.Address]int{{}: 0}
+	groups = []common.Address{{}}
+	groupOps = [][]*userop.UserOperation{{}}
+	for _, op := range batch {
+		agg := aggregators[op.GetUserOpHash(ep, chainID)]
+		i, ok := idx[agg]
+		if !ok {
+			i = len(groups)
+			idx[agg] = i
+			groups = append(groups, agg)
+			groupOps = append(groupOps, []*userop.UserOperation{})
+		}
+		groupOps[i] = append(groupOps[i], op)
+	}
+
+	if len(groupOps[0]) == 0 {
+		groups, groupOps = groups[1:], groupOps[1:]
+	}
+	for _, ops := range groupOps {
+		ordered = append(ordered, ops...)
+	}
+	return ordered, groups, groupOps
+}
+
+// AggregatedSignature is the signature returned by an aggregator for a group of userOps in a batch.
+type AggregatedSignature struct {
+	UserOpHashes []common.Hash
+	Signature    []byte
+}
+
+// isFor returns true if the signature was aggregated for exactly the given group of userOps in order.
+func (a *AggregatedSignature) isFor(ops []*userop.UserOperation, ep common.Address, chainID *big.Int) bool {
+	if len(a.UserOpHashes) != len(ops) {
+		return false
+	}
+	for i, op := range ops {
+		if a.UserOpHashes[i] != op.GetUserOpHash(ep, chainID) {
+			return false
+		}
+	}
+	return true
+}
+
+// toOpsPerAggregator groups the batch by aggregator and sets the aggregated signature of each group. A
+// signature in opts.AggregatedSignatures is reused if it was aggregated for the same group. Otherwise the
+// signatures are aggregated again, since the group has changed after userOps were dropped from the batch.
+func toOpsPerAggregator(opts *Opts) ([]entrypoint.IEntryPointUserOpsPerAggregator, error) {
+	_, groups, groupOps := GroupByAggregator(opts.Batch, opts.Aggregators, opts.EntryPoint, opts.ChainID)
+
+	opsPerAggregator := []entrypoint.IEntryPointUserOpsPerAggregator{}
+	for i, agg := range groups {
+		sig := []byte{}
+		if agg != (common.Address{}) {
+			if saved, ok := opts.AggregatedSignatures[agg]; ok && saved.isFor(groupOps[i], opts.EntryPoint, opts.ChainID) {
+				sig = saved.Signature
+			} else {
+				s, err := aggregator.AggregateSignatures(opts.Eth, agg, groupOps[i])
+				if err != nil {
+					return nil, err
+				}
+				sig = s
+			}
+		}
+
+		opsPerAggregator = append(opsPerAggregator, entrypoint.IEntryPointUserOpsPerAggregator{
+			UserOps:    toAbiType(groupOps[i]),
+			Aggregator: agg,
+			Signature:  sig,
+		})
+	}
+	return opsPerAggregator, nil
+}
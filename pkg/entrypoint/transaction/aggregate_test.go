@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/methods"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// TestGroupByAggregator verifies that userOps without an aggregator come first and each aggregator group is
+// ordered by first appearance without changing the order within a group.
+func TestGroupByAggregator(t *testing.T) {
+	ops := []*userop.UserOperation{}
+	for i := 0; i < 5; i++ {
+		op := testutils.MockValidInitUserOp()
+		op.Nonce = big.NewInt(int64(i))
+		ops = append(ops, op)
+	}
+	aggs := map[common.Hash]common.Address{
+		ops[0].GetUserOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress2,
+		ops[2].GetUserOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress3,
+		ops[3].GetUserOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress2,
+	}
+
+	ordered, groups, groupOps := GroupByAggregator(ops, aggs, testutils.ValidAddress1, testutils.ChainID)
+	want := []*userop.UserOperation{ops[1], ops[4], ops[0], ops[3], ops[2]}
+	for i, op := range ordered {
+		if op != want[i] {
+			t.Fatalf("got op with nonce %d at index %d, want %d", op.Nonce, i, want[i].Nonce)
+		}
+	}
+	wantGroups := []common.Address{{}, testutils.ValidAddress2, testutils.ValidAddress3}
+	if len(groups) != len(wantGroups) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(wantGroups))
+	}
+	for i, agg := range groups {
+		if agg != wantGroups[i] {
+			t.Fatalf("got aggregator %s at index %d, want %s", agg, i, wantGroups[i])
+		} else if i == 1 && len(groupOps[i]) != 2 {
+			t.Fatalf("got %d ops for aggregator %s, want 2", len(groupOps[i]), agg)
+		}
+	}
+}
+
+// TestGroupByAggregatorWithoutUnaggregated verifies that no empty group is returned if every userOp is
+// aggregated.
+func TestGroupByAggregatorWithoutUnaggregated(t *testing.T) {
+	op := testutils.MockValidInitUserOp()
+	aggs := map[common.Hash]common.Address{
+		op.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress2,
+	}
+
+	_, groups, _ := GroupByAggregator([]*userop.UserOperation{op}, aggs, testutils.ValidAddress1, testutils.ChainID)
+	if len(groups) != 1 || groups[0] != testutils.ValidAddress2 {
+		t.Fatalf("got groups %v, want only %s", groups, testutils.ValidAddress2)
+	}
+}
+
+// TestPackHandleOps verifies that a batch without aggregators is packed as a handleOps call.
+func TestPackHandleOps(t *testing.T) {
+	op := testutils.MockValidInitUserOp()
+	data, err := PackHandleOps(&Opts{
+		ChainID:     testutils.ChainID,
+		EntryPoint:  testutils.ValidAddress1,
+		Batch:       []*userop.UserOperation{op},
+		Beneficiary: testutils.ValidAddress2,
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	args, err := methods.HandleOpsMethod.Inputs.Pack(toAbiType([]*userop.UserOperation{op}), testutils.ValidAddress2)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if want := append(methods.HandleOpsMethod.ID, args...); !bytes.Equal(data, want) {
+		t.Fatal("calldata does not match handleOps")
+	}
+}
+
+// TestToOpsPerAggregatorReusesSignature verifies that a saved signature for the same group of userOps is used
+// without calling the aggregator again.
+func TestToOpsPerAggregatorReusesSignature(t *testing.T) {
+	op := testutils.MockValidInitUserOp()
+	hash := op.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID)
+	sig := []byte{1, 2, 3}
+	opts := &Opts{
+		ChainID:     testutils.ChainID,
+		EntryPoint:  testutils.ValidAddress1,
+		Batch:       []*userop.UserOperation{op},
+		Aggregators: map[common.Hash]common.Address{hash: testutils.ValidAddress2},
+		AggregatedSignatures: map[common.Address]*AggregatedSignature{
+			testutils.ValidAddress2: {UserOpHashes: []common.Hash{hash}, Signature: sig},
+		},
+	}
+
+	opsPerAggregator, err := toOpsPerAggregator(opts)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(opsPerAggregator) != 1 {
+		t.Fatalf("got %d groups, want 1", len(opsPerAggregator))
+	} else if !bytes.Equal(opsPerAggregator[0].Signature, sig) {
+		t.Fatal("saved signature was not reused")
+	}
+}
@@ -22,7 +22,7 @@ import (
 // contract.
 type Opts struct {
 	// Options for the network
-	EOA     *signer.EOA
+	EOA     signer.Signer
 	Eth     *ethclient.Client
 	ChainID *big.Int
 
@@ -38,6 +38,21 @@ type Opts struct {
 	GasLimit    uint64
 	NoSend      bool
 	WaitTimeout time.Duration
+
+	// Options for signature aggregation. Aggregators maps the userOpHash of each aggregated userOp in Batch to
+	// its aggregator. If any userOp is aggregated, the batch is sent with handleAggregatedOps instead.
+	// AggregatedSignatures holds the validated signature of each aggregator to avoid aggregating again.
+	Aggregators          map[common.Hash]common.Address
+	AggregatedSignatures map[common.Address]*AggregatedSignature
+
+	// Options for tracking and replacing in-flight transactions. If Nonces is nil, the latest confirmed nonce
+	// is used and the transaction is never replaced. If NoSend is also set, the nonce is assigned by Nonces
+	// but the caller must track the transaction once it is broadcasted. OnSend is called with every
+	// transaction sent for the batch, including replacements and cancellations.
+	Nonces              *NonceManager
+	RebroadcastInterval time.Duration
+	PriceBump           int64
+	OnSend              func(txn *types.Transaction)
 }
 
 func toAbiType(batch []*userop.UserOperation) []entrypoint.UserOperation {
@@ -49,6 +64,43 @@ func toAbiType(batch []*userop.UserOperation) []entrypoint.UserOperation {
 	return ops
 }
 
+// handleOps calls handleAggregatedOps if any userOp in the batch uses an aggregator. Otherwise handleOps is
+// called.
+func handleOps(ep *entrypoint.Entrypoint, auth *bind.TransactOpts, opts *Opts) (*types.Transaction, error) {
+	if len(opts.Aggregators) == 0 {
+		return ep.HandleOps(auth, toAbiType(opts.Batch), opts.Beneficiary)
+	}
+
+	opsPerAggregator, err := toOpsPerAggregator(opts)
+	if err != nil {
+		return nil, err
+	} else if len(opsPerAggregator) == 1 && opsPerAggregator[0].Aggregator == (common.Address{}) {
+		return ep.HandleOps(auth, opsPerAggregator[0].UserOps, opts.Beneficiary)
+	}
+	return ep.HandleAggregatedOps(auth, opsPerAggregator, opts.Beneficiary)
+}
+
+// PackHandleOps returns the calldata of the transaction that HandleOps would send for the batch without
+// requiring an EOA or any RPC calls for the nonce and fees.
+func PackHandleOps(opts *Opts) ([]byte, error) {
+	abi, err := entrypoint.EntrypointMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Aggregators) == 0 {
+		return abi.Pack("handleOps", toAbiType(opts.Batch), opts.Beneficiary)
+	}
+
+	opsPerAggregator, err := toOpsPerAggregator(opts)
+	if err != nil {
+		return nil, err
+	} else if len(opsPerAggregator) == 1 && opsPerAggregator[0].Aggregator == (common.Address{}) {
+		return abi.Pack("handleOps", opsPerAggregator[0].UserOps, opts.Beneficiary)
+	}
+	return abi.Pack("handleAggregatedOps", opsPerAggregator, opts.Beneficiary)
+}
+
 // EstimateHandleOpsGas returns a gas estimate required to call handleOps() with a given batch. A failed call
 // will return the cause of the revert.
 func EstimateHandleOpsGas(opts *Opts) (gas uint64, revert *reverts.FailedOpRevert, err error) {
@@ -57,20 +109,17 @@ func EstimateHandleOpsGas(opts *Opts) (gas uint64, revert *reverts.FailedOpRever
 		return 0, nil, err
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(opts.EOA.PrivateKey, opts.ChainID)
-	if err != nil {
-		return 0, nil, err
-	}
+	auth := newTransactor(opts.EOA, opts.ChainID)
 	auth.GasLimit = math.MaxUint64
 	auth.NoSend = true
 
-	tx, err := ep.HandleOps(auth, toAbiType(opts.Batch), opts.Beneficiary)
+	tx, err := handleOps(ep, auth, opts)
 	if err != nil {
 		return 0, nil, err
 	}
 
 	est, err := opts.Eth.EstimateGas(context.Background(), ethereum.CallMsg{
-		From:       opts.EOA.Address,
+		From:       opts.EOA.Account().Address,
 		To:         tx.To(),
 		Gas:        tx.Gas(),
 		GasPrice:   tx.GasPrice(),
@@ -98,14 +147,16 @@ func HandleOps(opts *Opts) (txn *types.Transaction, err error) {
 		return nil, err
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(opts.EOA.PrivateKey, opts.ChainID)
-	if err != nil {
-		return nil, err
-	}
+	auth := newTransactor(opts.EOA, opts.ChainID)
 	auth.GasLimit = opts.GasLimit
 	auth.NoSend = opts.NoSend
 
-	nonce, err := opts.Eth.NonceAt(context.Background(), opts.EOA.Address, nil)
+	var nonce uint64
+	if opts.Nonces != nil {
+		nonce, err = opts.Nonces.Next()
+	} else {
+		nonce, err = opts.Eth.NonceAt(context.Background(), opts.EOA.Account().Address, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -120,13 +171,24 @@ func HandleOps(opts *Opts) (txn *types.Transaction, err error) {
 		return nil, errors.New("transaction: either the dynamic or legacy gas fees must be set")
 	}
 
-	txn, err = ep.HandleOps(auth, toAbiType(opts.Batch), opts.Beneficiary)
+	txn, err = handleOps(ep, auth, opts)
 	if err != nil {
 		return nil, err
-	} else if opts.WaitTimeout == 0 || opts.NoSend {
+	} else if !opts.NoSend {
+		if opts.Nonces != nil {
+			opts.Nonces.Track(txn)
+		}
+		if opts.OnSend != nil {
+			opts.OnSend(txn)
+		}
+	}
+
+	if opts.WaitTimeout == 0 || opts.NoSend {
 		// Don't wait for transaction to be included. All userOps in the current batch will be dropped
 		// regardless of the transaction status.
 		return txn, nil
+	} else if opts.Nonces != nil {
+		return WaitOrReplace(txn, opts)
 	}
 
 	return Wait(txn, opts.Eth, opts.WaitTimeout)
@@ -0,0 +1,89 @@
+package transaction
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// NonceManager assigns nonces to the transactions sent by a single EOA and keeps track of the ones that are
+// still in-flight. This allows a new transaction to be sent while a previous one is not yet mined without
+// colliding on the same nonce.
+type NonceManager struct {
+	mu       sync.Mutex
+	eth      *ethclient.Client
+	address  common.Address
+	next     uint64
+	inFlight map[uint64]*types.Transaction
+}
+
+// NewNonceManager returns a NonceManager for the given EOA address.
+func NewNonceManager(eth *ethclient.Client, address common.Address) *NonceManager {
+	return &NonceManager{
+		eth:      eth,
+		address:  address,
+		inFlight: make(map[uint64]*types.Transaction),
+	}
+}
+
+// Next returns the nonce to use for the next transaction. Any in-flight transactions with a nonce below the
+// latest confirmed nonce are no longer tracked. If there are no in-flight transactions, the latest confirmed
+// nonce is always used so that gaps left by dropped transactions are filled.
+func (m *NonceManager) Next() (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latest, err := m.eth.NonceAt(context.Background(), m.address, nil)
+	if err != nil {
+		return 0, err
+	}
+	for nonce := range m.inFlight {
+		if nonce < latest {
+			delete(m.inFlight, nonce)
+		}
+	}
+
+	if len(m.inFlight) == 0 || m.next < latest {
+		m.next = latest
+	}
+	return m.next, nil
+}
+
+// Track records a sent transaction as in-flight. A replacement transaction with the same nonce overrides the
+// previous one.
+func (m *NonceManager) Track(txn *types.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight[txn.Nonce()] = txn
+	if txn.Nonce() >= m.next {
+		m.next = txn.Nonce() + 1
+	}
+}
+
+// Done stops tracking the transaction with the given nonce once it has been mined.
+func (m *NonceManager) Done(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.inFlight, nonce)
+}
+
+// InFlight returns all transactions that have been sent but are not yet known to be mined, sorted by nonce.
+func (m *NonceManager) InFlight() []*types.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	txns := []*types.Transaction{}
+	for _, txn := range m.inFlight {
+		txns = append(txns, txn)
+	}
+	sort.Slice(txns, func(i, j int) bool {
+		return txns[i].Nonce() < txns[j].Nonce()
+	})
+	return txns
+}
@@ -0,0 +1,65 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+func newNonceManagerMock(t *testing.T, latest string) *NonceManager {
+	s := testutils.RpcMock(testutils.MethodMocks{"eth_getTransactionCount": latest})
+	t.Cleanup(s.Close)
+
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	return NewNonceManager(eth, testutils.DummyEOA.Address)
+}
+
+// TestNonceManagerSkipsInFlight verifies that the next nonce is after any in-flight transaction that has not
+// been confirmed yet.
+func TestNonceManagerSkipsInFlight(t *testing.T) {
+	m := newNonceManagerMock(t, "0x5")
+
+	if nonce, err := m.Next(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if nonce != 5 {
+		t.Fatalf("got nonce %d, want 5", nonce)
+	}
+
+	m.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 5}))
+	if nonce, err := m.Next(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if nonce != 6 {
+		t.Fatalf("got nonce %d, want 6", nonce)
+	} else if len(m.InFlight()) != 1 {
+		t.Fatalf("got %d in-flight, want 1", len(m.InFlight()))
+	}
+
+	m.Done(5)
+	if nonce, err := m.Next(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if nonce != 5 {
+		t.Fatalf("got nonce %d, want 5", nonce)
+	}
+}
+
+// TestNonceManagerPrunesConfirmed verifies that in-flight transactions below the latest confirmed nonce are
+// no longer tracked.
+func TestNonceManagerPrunesConfirmed(t *testing.T) {
+	m := newNonceManagerMock(t, "0x7")
+	m.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 5}))
+	m.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 6}))
+
+	if nonce, err := m.Next(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if nonce != 7 {
+		t.Fatalf("got nonce %d, want 7", nonce)
+	} else if len(m.InFlight()) != 0 {
+		t.Fatalf("got %d in-flight, want 0", len(m.InFlight()))
+	}
+}
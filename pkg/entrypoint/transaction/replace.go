@@ -0,0 +1,254 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// DefaultPriceBump is the minimum percentage that the fees of a replacement transaction must be increased
+	// by for the node to accept it. This matches the default of the geth txpool.
+	DefaultPriceBump int64 = 10
+
+	// ErrTransactionCancelled is returned when a transaction was not mined before the wait timeout and a
+	// cancellation was mined in its place.
+	ErrTransactionCancelled = errors.New("transaction: not mined before timeout, cancellation mined")
+
+	// ErrCancellationPending is returned when a cancellation was sent but the nonce was still not consumed
+	// before a second wait timeout. The transaction and its cancellation remain tracked as in-flight.
+	ErrCancellationPending = errors.New("transaction: not mined before timeout, cancellation pending")
+
+	receiptPollInterval = 1 * time.Second
+)
+
+// bumpFee returns the fee increased by the given percentage and rounded up.
+func bumpFee(fee *big.Int, priceBump int64) *big.Int {
+	b := big.NewInt(0).Mul(fee, big.NewInt(100+priceBump))
+	b.Div(b, big.NewInt(100))
+	return b.Add(b, big.NewInt(1))
+}
+
+func maxBig(a *big.Int, b *big.Int) *big.Int {
+	if b != nil && b.Cmp(a) > 0 {
+		return b
+	}
+	return a
+}
+
+// bumpFees returns the fees for a replacement of txn. The fees are increased by at least priceBump percent
+// to satisfy the replacement rules of the node and are never lower than the current network fees. For
+// dynamic fee transactions the fee cap will also cover twice the base fee.
+func bumpFees(
+	txn *types.Transaction,
+	baseFee *big.Int,
+	tip *big.Int,
+	gasPrice *big.Int,
+	priceBump int64,
+) (feeCap *big.Int, tipCap *big.Int, price *big.Int) {
+	if txn.Type() == types.LegacyTxType {
+		return nil, nil, maxBig(bumpFee(txn.GasPrice(), priceBump), gasPrice)
+	}
+
+	tipCap = maxBig(bumpFee(txn.GasTipCap(), priceBump), tip)
+	feeCap = bumpFee(txn.GasFeeCap(), priceBump)
+	if baseFee != nil {
+		feeCap = maxBig(feeCap, big.NewInt(0).Add(big.NewInt(0).Mul(baseFee, big.NewInt(2)), tipCap))
+	}
+	return maxBig(feeCap, tipCap), tipCap, nil
+}
+
+// sendReplacement signs and sends a transaction with the same nonce as txn and bumped fees.
+func sendReplacement(
+	opts *Opts,
+	txn *types.Transaction,
+	to *common.Address,
+	gas uint64,
+	data []byte,
+) (*types.Transaction, error) {
+	ctx := context.Background()
+	priceBump := opts.PriceBump
+	if priceBump == 0 {
+		priceBump = DefaultPriceBump
+	}
+
+	var inner types.TxData
+	if txn.Type() == types.LegacyTxType {
+		gp, err := opts.Eth.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		_, _, price := bumpFees(txn, nil, nil, gp, priceBump)
+		inner = &types.LegacyTx{
+			Nonce:    txn.Nonce(),
+			GasPrice: price,
+			Gas:      gas,
+			To:       to,
+			Value:    txn.Value(),
+			Data:     data,
+		}
+	} else {
+		head, err := opts.Eth.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		tip, err := opts.Eth.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		feeCap, tipCap, _ := bumpFees(txn, head.BaseFee, tip, nil, priceBump)
+		inner = &types.DynamicFeeTx{
+			ChainID:   opts.ChainID,
+			Nonce:     txn.Nonce(),
+			GasTipCap: tipCap,
+			GasFeeCap: feeCap,
+			Gas:       gas,
+			To:        to,
+			Value:     txn.Value(),
+			Data:      data,
+		}
+	}
+
+	signed, err := opts.EOA.SignTx(types.NewTx(inner), opts.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Eth.SendTransaction(ctx, signed); err != nil {
+		return nil, err
+	}
+	if opts.Nonces != nil {
+		opts.Nonces.Track(signed)
+	}
+	if opts.OnSend != nil {
+		opts.OnSend(signed)
+	}
+	return signed, nil
+}
+
+// Replace re-broadcasts txn with the same call and bumped fees.
+func Replace(opts *Opts, txn *types.Transaction) (*types.Transaction, error) {
+	return sendReplacement(opts, txn, txn.To(), txn.Gas(), txn.Data())
+}
+
+// Cancel replaces txn with an empty transfer to the EOA itself and bumped fees. This frees up the nonce
+// without calling the EntryPoint.
+func Cancel(opts *Opts, txn *types.Transaction) (*types.Transaction, error) {
+	addr := opts.EOA.Account().Address
+	return sendReplacement(opts, txn, &addr, params.TxGas, nil)
+}
+
+// findReceipt returns the first transaction in txns that has been mined and its receipt.
+func findReceipt(opts *Opts, txns []*types.Transaction) (*types.Transaction, *types.Receipt) {
+	for _, txn := range txns {
+		receipt, err := opts.Eth.TransactionReceipt(context.Background(), txn.Hash())
+		if err == nil && receipt != nil {
+			return txn, receipt
+		}
+	}
+	return nil, nil
+}
+
+// WaitOrReplace blocks the process until txn or one of its replacements has been included on-chain. While
+// waiting, the transaction is re-broadcasted with bumped fees after every RebroadcastInterval. If the timeout
+// is reached, a cancellation is sent with the same nonce and the process blocks until that nonce is consumed.
+// ErrTransactionCancelled is only returned if none of the sent transactions was the one included.
+func WaitOrReplace(txn *types.Transaction, opts *Opts) (*types.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.WaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	sent := []*types.Transaction{txn}
+	lastSent := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			if mined, receipt := findReceipt(opts, sent); receipt != nil {
+				return checkMined(opts, mined, receipt)
+			}
+			cancellation, err := Cancel(opts, sent[len(sent)-1])
+			if err != nil {
+				return nil, fmt.Errorf("transaction: failed to cancel after timeout: %w", err)
+			}
+			return waitCancelled(opts, sent, cancellation)
+
+		case <-ticker.C:
+			if mined, receipt := findReceipt(opts, sent); receipt != nil {
+				return checkMined(opts, mined, receipt)
+			}
+			if opts.RebroadcastInterval == 0 || time.Since(lastSent) < opts.RebroadcastInterval {
+				continue
+			}
+
+			// A failed replacement (e.g. underpriced or the nonce was just used) is retried after the next
+			// interval while the previously sent transactions are still being waited on.
+			if rep, err := Replace(opts, sent[len(sent)-1]); err == nil {
+				sent = append(sent, rep)
+			}
+			lastSent = time.Now()
+		}
+	}
+}
+
+// waitCancelled blocks the process until the nonce shared by sent and the cancellation has been consumed. A
+// transaction in sent can still be included instead of the cancellation if it reached the block producer
+// first, in which case its result is returned. The cancellation is re-broadcasted with bumped fees after every
+// RebroadcastInterval.
+func waitCancelled(opts *Opts, sent []*types.Transaction, cancellation *types.Transaction) (
+	*types.Transaction,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.WaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	nonce := cancellation.Nonce()
+	lastSent := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ErrCancellationPending
+
+		case <-ticker.C:
+			latest, err := opts.Eth.NonceAt(context.Background(), opts.EOA.Account().Address, nil)
+			if err != nil || latest <= nonce {
+				if opts.RebroadcastInterval > 0 && time.Since(lastSent) >= opts.RebroadcastInterval {
+					if rep, err := Replace(opts, cancellation); err == nil {
+						cancellation = rep
+					}
+					lastSent = time.Now()
+				}
+				continue
+			}
+
+			if mined, receipt := findReceipt(opts, sent); receipt != nil {
+				return checkMined(opts, mined, receipt)
+			}
+			if opts.Nonces != nil {
+				opts.Nonces.Done(nonce)
+			}
+			return nil, ErrTransactionCancelled
+		}
+	}
+}
+
+func checkMined(opts *Opts, txn *types.Transaction, receipt *types.Receipt) (*types.Transaction, error) {
+	if opts.Nonces != nil {
+		opts.Nonces.Done(txn.Nonce())
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		// Return an error here so that the current batch stays in the mempool. In the next bundler iteration,
+		// the offending userOps will be dropped during gas estimation.
+		return nil, errors.New("transaction: failed status")
+	}
+	return txn, nil
+}
@@ -0,0 +1,118 @@
+package transaction
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestBumpFeesDynamic verifies that the fees of a replacement dynamic fee transaction are increased by at
+// least the price bump and the fee cap covers twice the base fee.
+func TestBumpFeesDynamic(t *testing.T) {
+	txn := types.NewTx(&types.DynamicFeeTx{GasTipCap: big.NewInt(100), GasFeeCap: big.NewInt(1000)})
+
+	feeCap, tipCap, _ := bumpFees(txn, big.NewInt(100), big.NewInt(1), nil, DefaultPriceBump)
+	if tipCap.Cmp(big.NewInt(111)) != 0 {
+		t.Fatalf("got tip cap %d, want 111", tipCap)
+	} else if feeCap.Cmp(big.NewInt(1101)) != 0 {
+		t.Fatalf("got fee cap %d, want 1101", feeCap)
+	}
+
+	feeCap, tipCap, _ = bumpFees(txn, big.NewInt(1000), big.NewInt(200), nil, DefaultPriceBump)
+	if tipCap.Cmp(big.NewInt(200)) != 0 {
+		t.Fatalf("got tip cap %d, want 200", tipCap)
+	} else if feeCap.Cmp(big.NewInt(2200)) != 0 {
+		t.Fatalf("got fee cap %d, want 2200", feeCap)
+	}
+}
+
+// TestBumpFeesLegacy verifies that the gas price of a replacement legacy transaction is increased by at least
+// the price bump and is never below the network gas price.
+func TestBumpFeesLegacy(t *testing.T) {
+	txn := types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(1000)})
+
+	if _, _, price := bumpFees(txn, nil, nil, big.NewInt(1), DefaultPriceBump); price.Cmp(big.NewInt(1101)) != 0 {
+		t.Fatalf("got gas price %d, want 1101", price)
+	}
+	if _, _, price := bumpFees(txn, nil, nil, big.NewInt(5000), DefaultPriceBump); price.Cmp(big.NewInt(5000)) != 0 {
+		t.Fatalf("got gas price %d, want 5000", price)
+	}
+}
+
+// newCancelMock returns Opts and a signed transaction for a node that consumes the nonce once a cancellation
+// has been broadcasted. If bundleMined is true, the original transaction is the one reported as included.
+func newCancelMock(t *testing.T, bundleMined bool) (*Opts, *types.Transaction) {
+	interval := receiptPollInterval
+	receiptPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { receiptPollInterval = interval })
+
+	txn, err := testutils.DummyEOA.SignTx(
+		types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(1), Gas: 100000}),
+		testutils.ChainID,
+	)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	var cancelled atomic.Bool
+	s := testutils.RpcMock(testutils.MethodMocks{
+		"eth_gasPrice": "0x1",
+		"eth_sendRawTransaction": testutils.MethodMockFunc(func(params []any) any {
+			cancelled.Store(true)
+			return testutils.MockHash
+		}),
+		"eth_getTransactionCount": testutils.MethodMockFunc(func(params []any) any {
+			if cancelled.Load() {
+				return "0x1"
+			}
+			return "0x0"
+		}),
+		"eth_getTransactionReceipt": testutils.MethodMockFunc(func(params []any) any {
+			if bundleMined && cancelled.Load() && params[0] == txn.Hash().String() {
+				return testutils.NewTransactionReceiptMock()
+			}
+			return nil
+		}),
+	})
+	t.Cleanup(s.Close)
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	return &Opts{
+		EOA:         testutils.DummyEOA,
+		Eth:         eth,
+		ChainID:     testutils.ChainID,
+		WaitTimeout: 50 * time.Millisecond,
+	}, txn
+}
+
+// TestWaitOrReplaceReturnsCancelledOnceNonceConsumed verifies that ErrTransactionCancelled is only returned
+// after the cancellation has consumed the nonce.
+func TestWaitOrReplaceReturnsCancelledOnceNonceConsumed(t *testing.T) {
+	opts, txn := newCancelMock(t, false)
+
+	if _, err := WaitOrReplace(txn, opts); err != ErrTransactionCancelled {
+		t.Fatalf("got %v, want %v", err, ErrTransactionCancelled)
+	}
+}
+
+// TestWaitOrReplaceReturnsBundleMinedAfterCancel verifies that the original transaction is returned if it was
+// included instead of the cancellation.
+func TestWaitOrReplaceReturnsBundleMinedAfterCancel(t *testing.T) {
+	opts, txn := newCancelMock(t, true)
+
+	mined, err := WaitOrReplace(txn, opts)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if mined.Hash() != txn.Hash() {
+		t.Fatalf("got %s, want %s", mined.Hash(), txn.Hash())
+	}
+}
@@ -0,0 +1,73 @@
+package transaction
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TransferFee returns the maximum fee paid for a plain ETH transfer with the fees in opts.
+func TransferFee(opts *Opts) *big.Int {
+	gas := big.NewInt(int64(params.TxGas))
+	if opts.BaseFee != nil && opts.Tip != nil {
+		feeCap := big.NewInt(0).Add(big.NewInt(0).Mul(opts.BaseFee, common.Big2), opts.Tip)
+		return feeCap.Mul(feeCap, gas)
+	}
+	if opts.GasPrice != nil {
+		return big.NewInt(0).Mul(opts.GasPrice, gas)
+	}
+	return big.NewInt(0)
+}
+
+// Transfer sends value from the EOA in opts to the given address. The nonce is assigned by opts.Nonces if set
+// so that it does not collide with bundle transactions sent by the same EOA. Otherwise the pending nonce of the
+// EOA is used. A dynamic fee transaction is sent if BaseFee and Tip are set, otherwise a legacy transaction with
+// GasPrice.
+func Transfer(opts *Opts, to common.Address, value *big.Int) (*types.Transaction, error) {
+	ctx := context.Background()
+	var nonce uint64
+	var err error
+	if opts.Nonces != nil {
+		nonce, err = opts.Nonces.Next()
+	} else {
+		nonce, err = opts.Eth.PendingNonceAt(ctx, opts.EOA.Account().Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var inner types.TxData
+	if opts.BaseFee != nil && opts.Tip != nil {
+		inner = &types.DynamicFeeTx{
+			ChainID:   opts.ChainID,
+			Nonce:     nonce,
+			GasTipCap: opts.Tip,
+			GasFeeCap: big.NewInt(0).Add(big.NewInt(0).Mul(opts.BaseFee, common.Big2), opts.Tip),
+			Gas:       params.TxGas,
+			To:        &to,
+			Value:     value,
+		}
+	} else {
+		inner = &types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: opts.GasPrice,
+			Gas:      params.TxGas,
+			To:       &to,
+			Value:    value,
+		}
+	}
+
+	signed, err := opts.EOA.SignTx(types.NewTx(inner), opts.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Eth.SendTransaction(ctx, signed); err != nil {
+		return nil, err
+	} else if opts.Nonces != nil {
+		opts.Nonces.Track(signed)
+	}
+	return signed, nil
+}
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
 )
 
 // ToRawTxHex Takes a Geth types.Transaction and returns the encoded raw hex string.
@@ -19,6 +22,21 @@ func ToRawTxHex(txn *types.Transaction) string {
 	return hexutil.Encode(rawTxn.Bytes())
 }
 
+// newTransactor returns the options for a transaction to be signed by the given Signer.
+func newTransactor(s signer.Signer, chainID *big.Int) *bind.TransactOpts {
+	from := s.Account().Address
+	return &bind.TransactOpts{
+		From: from,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return s.SignTx(tx, chainID)
+		},
+		Context: context.Background(),
+	}
+}
+
 // Wait blocks the process until a given transaction has been included on-chain or timeout has been reached.
 func Wait(txn *types.Transaction, eth *ethclient.Client, timeout time.Duration) (*types.Transaction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
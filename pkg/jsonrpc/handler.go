@@ -1,4 +1,5 @@
-// Package jsonrpc implements Gin middleware for handling JSON-RPC requests via HTTP.
+// Package jsonrpc implements Gin middleware for handling JSON-RPC requests via HTTP and subscriptions via
+// WebSocket.
 package jsonrpc
 
 import (
@@ -486,6 +487,7 @@ var bundlerMethods = map[string]bool{
 	"eth_estimateuseroperationgas":  true,
 	"eth_getuseroperationreceipt":   true,
 	"eth_getuseroperationbyhash":    true,
+	"eth_getintentstatus":           true,
 	"eth_supportedentrypoints":      true,
 	"eth_chainid":                   true,
 	"debug_bundler_clearstate":      true,
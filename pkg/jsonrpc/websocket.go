@@ -0,0 +1,238 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+)
+
+const (
+	ethSubscribe    = "eth_subscribe"
+	ethUnsubscribe  = "eth_unsubscribe"
+	ethSubscription = "eth_subscription"
+)
+
+// SubscribeFunc returns a channel of notifications for the given subscription kind and filter, and a function
+// to unsubscribe. The channel must be closed once unsubscribed.
+type SubscribeFunc = func(kind string, filter map[string]any) (<-chan any, func(), error)
+
+var (
+	DefaultWebSocketWriteTimeout     = 10 * time.Second
+	DefaultWebSocketMaxSubscriptions = 100
+)
+
+// WebSocketOpts are the options for serving subscriptions over WebSocket connections.
+type WebSocketOpts struct {
+	// AllowedOrigins is the list of Origin header values that can open a connection. A "*" allows any origin.
+	// If empty, only requests without an Origin header or from the same host are allowed.
+	AllowedOrigins []string
+
+	// WriteTimeout is the maximum time to write a single message to a connection. A connection that is too
+	// slow to receive its messages is closed. The default is DefaultWebSocketWriteTimeout.
+	WriteTimeout time.Duration
+
+	// MaxSubscriptions is the maximum number of active subscriptions for a single connection. The default is
+	// DefaultWebSocketMaxSubscriptions.
+	MaxSubscriptions int
+}
+
+func newUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	if len(allowedOrigins) == 0 {
+		// Use the default same origin check.
+		return &websocket.Upgrader{}
+	}
+
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			for _, allowed := range allowedOrigins {
+				if allowed == "*" || strings.EqualFold(allowed, origin) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+type wsRequest struct {
+	JsonRpc string `json:"jsonrpc"`
+	ID      any    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+// wsConn serializes writes to a WebSocket connection and keeps track of its subscriptions.
+type wsConn struct {
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	subs    map[rpc.ID]func()
+	timeout time.Duration
+	maxSubs int
+}
+
+func (c *wsConn) write(msg any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *wsConn) writeError(code int, message string, data any, id any) error {
+	return c.write(gin.H{
+		"jsonrpc": "2.0",
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+			"data":    data,
+		},
+		"id": id,
+	})
+}
+
+func (c *wsConn) writeResult(result any, id any) error {
+	return c.write(gin.H{"jsonrpc": "2.0", "result": result, "id": id})
+}
+
+func (c *wsConn) subscribe(subscribe SubscribeFunc, req *wsRequest) error {
+	kind, ok := "", len(req.Params) > 0
+	if ok {
+		kind, ok = req.Params[0].(string)
+	}
+	if !ok {
+		return c.writeError(-32602, "Invalid params", "No or invalid subscription kind", req.ID)
+	}
+	filter := map[string]any{}
+	if len(req.Params) > 1 {
+		if filter, ok = req.Params[1].(map[string]any); !ok {
+			return c.writeError(-32602, "Invalid params", "Subscription filter must be an object", req.ID)
+		}
+	}
+
+	c.mu.Lock()
+	count := len(c.subs)
+	c.mu.Unlock()
+	if count >= c.maxSubs {
+		return c.writeError(-32005, "Limit exceeded", "Too many subscriptions for this connection", req.ID)
+	}
+
+	notifications, unsubscribe, err := subscribe(kind, filter)
+	if rpcErr, ok := err.(*errors.RPCError); ok {
+		return c.writeError(rpcErr.Code(), rpcErr.Error(), rpcErr.Data(), req.ID)
+	} else if err != nil {
+		return c.writeError(-32603, "Internal error", err.Error(), req.ID)
+	}
+
+	id := rpc.NewID()
+	c.mu.Lock()
+	c.subs[id] = unsubscribe
+	c.mu.Unlock()
+	if err := c.writeResult(id, req.ID); err != nil {
+		return err
+	}
+
+	go func() {
+		for n := range notifications {
+			err := c.write(gin.H{
+				"jsonrpc": "2.0",
+				"method":  ethSubscription,
+				"params":  gin.H{"subscription": id, "result": n},
+			})
+			if err != nil {
+				// Closing the connection stops the read loop which cancels all subscriptions.
+				c.conn.Close()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *wsConn) unsubscribe(req *wsRequest) error {
+	id, ok := "", len(req.Params) > 0
+	if ok {
+		id, ok = req.Params[0].(string)
+	}
+	if !ok {
+		return c.writeError(-32602, "Invalid params", "No or invalid subscription id", req.ID)
+	}
+
+	c.mu.Lock()
+	unsubscribe, found := c.subs[rpc.ID(id)]
+	delete(c.subs, rpc.ID(id))
+	c.mu.Unlock()
+	if found {
+		unsubscribe()
+	}
+	return c.writeResult(found, req.ID)
+}
+
+func (c *wsConn) close() {
+	c.mu.Lock()
+	subs := c.subs
+	c.subs = make(map[rpc.ID]func())
+	c.mu.Unlock()
+
+	for _, unsubscribe := range subs {
+		unsubscribe()
+	}
+	c.conn.Close()
+}
+
+// WebSocketController returns a Gin handler that upgrades the request to a WebSocket connection and serves
+// eth_subscribe and eth_unsubscribe requests with the given SubscribeFunc. Notifications are pushed with the
+// eth_subscription method. All subscriptions are cancelled when the connection is closed.
+func WebSocketController(subscribe SubscribeFunc, opts *WebSocketOpts) gin.HandlerFunc {
+	timeout := DefaultWebSocketWriteTimeout
+	if opts.WriteTimeout > 0 {
+		timeout = opts.WriteTimeout
+	}
+	maxSubs := DefaultWebSocketMaxSubscriptions
+	if opts.MaxSubscriptions > 0 {
+		maxSubs = opts.MaxSubscriptions
+	}
+	upgrader := newUpgrader(opts.AllowedOrigins)
+
+	return func(g *gin.Context) {
+		conn, err := upgrader.Upgrade(g.Writer, g.Request, nil)
+		if err != nil {
+			return
+		}
+		c := &wsConn{conn: conn, subs: make(map[rpc.ID]func()), timeout: timeout, maxSubs: maxSubs}
+		defer c.close()
+
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var req wsRequest
+			if err := json.Unmarshal(msg, &req); err != nil {
+				err = c.writeError(-32700, "Parse error", "Error parsing json request", nil)
+			} else if req.JsonRpc != "2.0" {
+				err = c.writeError(-32600, "Invalid Request", "Version of jsonrpc is not 2.0", req.ID)
+			} else if req.Method == ethSubscribe {
+				err = c.subscribe(subscribe, &req)
+			} else if req.Method == ethUnsubscribe {
+				err = c.unsubscribe(&req)
+			} else {
+				err = c.writeError(-32601, "Method not found", "Method not found", req.ID)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func newWebSocketServerMock(t *testing.T, subscribe SubscribeFunc, opts *WebSocketOpts) string {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", WebSocketController(subscribe, opts))
+	s := httptest.NewServer(r)
+	t.Cleanup(s.Close)
+
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+}
+
+func newWebSocketMock(t *testing.T, subscribe SubscribeFunc) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(newWebSocketServerMock(t, subscribe, &WebSocketOpts{}), nil)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// TestWebSocketControllerStreamsNotifications verifies that a subscription id is returned for eth_subscribe
+// and that notifications are pushed with it until eth_unsubscribe is called.
+func TestWebSocketControllerStreamsNotifications(t *testing.T) {
+	notifications := make(chan any, 1)
+	unsubscribed := make(chan bool, 1)
+	var gotFilter map[string]any
+	conn := newWebSocketMock(t, func(kind string, filter map[string]any) (<-chan any, func(), error) {
+		gotFilter = filter
+		return notifications, func() { unsubscribed <- true }, nil
+	})
+
+	if err := conn.WriteJSON(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_subscribe",
+		"params":  []any{"newPendingUserOperations", map[string]any{"sender": "0x01"}},
+	}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	var res struct {
+		Result string `json:"result"`
+	}
+	if err := conn.ReadJSON(&res); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if res.Result == "" {
+		t.Fatal("got empty subscription id, want id")
+	} else if gotFilter["sender"] != "0x01" {
+		t.Fatalf("got filter %v, want sender 0x01", gotFilter)
+	}
+
+	notifications <- "hello"
+	var n struct {
+		Method string `json:"method"`
+		Params struct {
+			Subscription string `json:"subscription"`
+			Result       string `json:"result"`
+		} `json:"params"`
+	}
+	if err := conn.ReadJSON(&n); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if n.Method != "eth_subscription" {
+		t.Fatalf("got method %s, want eth_subscription", n.Method)
+	} else if n.Params.Subscription != res.Result || n.Params.Result != "hello" {
+		t.Fatalf("got params %v, want subscription %s with result hello", n.Params, res.Result)
+	}
+
+	if err := conn.WriteJSON(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      2,
+		"method":  "eth_unsubscribe",
+		"params":  []any{res.Result},
+	}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	var unsub struct {
+		Result bool `json:"result"`
+	}
+	if err := conn.ReadJSON(&unsub); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if !unsub.Result {
+		t.Fatal("got false, want true")
+	} else if !<-unsubscribed {
+		t.Fatal("got subscription still active, want unsubscribed")
+	}
+}
+
+// TestWebSocketControllerRejectsOtherMethods verifies that only subscription methods are served.
+func TestWebSocketControllerRejectsOtherMethods(t *testing.T) {
+	conn := newWebSocketMock(t, func(kind string, filter map[string]any) (<-chan any, func(), error) {
+		return nil, nil, nil
+	})
+
+	if err := conn.WriteJSON(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_chainId",
+		"params":  []any{},
+	}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	var res struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := conn.ReadJSON(&res); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if res.Error.Code != -32601 {
+		t.Fatalf("got code %d, want -32601", res.Error.Code)
+	}
+}
+
+// TestWebSocketControllerChecksOrigin verifies that a connection is only upgraded for an allowed origin.
+func TestWebSocketControllerChecksOrigin(t *testing.T) {
+	subscribe := func(kind string, filter map[string]any) (<-chan any, func(), error) {
+		return nil, nil, nil
+	}
+	header := http.Header{"Origin": []string{"https://dapp.example"}}
+
+	url := newWebSocketServerMock(t, subscribe, &WebSocketOpts{})
+	if _, _, err := websocket.DefaultDialer.Dial(url, header); err == nil {
+		t.Fatal("got nil, want err")
+	}
+
+	url = newWebSocketServerMock(t, subscribe, &WebSocketOpts{AllowedOrigins: []string{"https://dapp.example"}})
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	conn.Close()
+}
+
+// TestWebSocketControllerLimitsSubscriptions verifies that eth_subscribe is rejected once a connection has
+// reached the maximum number of subscriptions.
+func TestWebSocketControllerLimitsSubscriptions(t *testing.T) {
+	url := newWebSocketServerMock(
+		t,
+		func(kind string, filter map[string]any) (<-chan any, func(), error) {
+			return make(chan any), func() {}, nil
+		},
+		&WebSocketOpts{MaxSubscriptions: 1},
+	)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	defer conn.Close()
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		if err := conn.WriteJSON(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      i,
+			"method":  "eth_subscribe",
+			"params":  []any{"newPendingUserOperations"},
+		}); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		var res struct {
+			Error struct {
+				Code int `json:"code"`
+			} `json:"error"`
+		}
+		if err := conn.ReadJSON(&res); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		codes = append(codes, res.Error.Code)
+	}
+	if codes[0] != 0 || codes[1] != -32005 {
+		t.Fatalf("got codes %v, want [0 -32005]", codes)
+	}
+}
@@ -0,0 +1,270 @@
+package mempool
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+	"github.com/wangjia184/sortedset"
+)
+
+// Capacity defines the maximum number of UserOperations the mempool will hold. Limits with a value of 0 are
+// not enforced.
+type Capacity struct {
+	// Global is the limit across all EntryPoints.
+	Global int
+
+	// PerEntryPoint is the limit for each EntryPoint.
+	PerEntryPoint int
+
+	// PerEntity is the limit for each sender, factory, and paymaster within an EntryPoint.
+	PerEntity int
+}
+
+func (c Capacity) isEnforced() bool {
+	return c.Global > 0 || c.PerEntryPoint > 0 || c.PerEntity > 0
+}
+
+type pendingOp struct {
+	entryPoint common.Address
+	op         *userop.UserOperation
+	fee        *big.Int
+}
+
+func (p *pendingOp) key() string {
+	return string(getUniqueKey(p.entryPoint, p.op.Sender, p.op.Nonce))
+}
+
+func getEntities(op *userop.UserOperation) []common.Address {
+	entities := []common.Address{op.Sender}
+	if factory := op.GetFactory(); factory != common.HexToAddress("0x") {
+		entities = append(entities, factory)
+	}
+	if paymaster := op.GetPaymaster(); paymaster != common.HexToAddress("0x") {
+		entities = append(entities, paymaster)
+	}
+
+	return entities
+}
+
+func getFeeScore(fee *big.Int) sortedset.SCORE {
+	if !fee.IsInt64() {
+		return sortedset.SCORE(math.MaxInt64)
+	}
+	return sortedset.SCORE(fee.Int64())
+}
+
+type indexEntry struct {
+	entryPoint common.Address
+	entities   []common.Address
+}
+
+// capacityIndex keeps the number of pending ops for each limit in Capacity and the evictable ops ordered by
+// effective fee. Only the op with the highest nonce of each sender is evictable, since evicting any other op
+// would leave a nonce gap for the sender. The index is not safe for concurrent use.
+type capacityIndex struct {
+	basefee   *big.Int
+	global    int
+	perEP     map[common.Address]int
+	perEntity map[common.Address]map[common.Address]int
+	entries   map[string]indexEntry
+	tails     *sortedset.SortedSet
+	tailKeys  map[common.Address]map[common.Address]string
+}
+
+func newCapacityIndex() *capacityIndex {
+	return &capacityIndex{
+		perEP:     make(map[common.Address]int),
+		perEntity: make(map[common.Address]map[common.Address]int),
+		entries:   make(map[string]indexEntry),
+		tails:     sortedset.New(),
+		tailKeys:  make(map[common.Address]map[common.Address]string),
+	}
+}
+
+func (i *capacityIndex) add(entryPoint common.Address, op *userop.UserOperation) {
+	key := string(getUniqueKey(entryPoint, op.Sender, op.Nonce))
+	i.remove(key)
+
+	entities := getEntities(op)
+	i.entries[key] = indexEntry{entryPoint, entities}
+	i.global++
+	i.perEP[entryPoint]++
+	if _, ok := i.perEntity[entryPoint]; !ok {
+		i.perEntity[entryPoint] = make(map[common.Address]int)
+	}
+	for _, entity := range entities {
+		i.perEntity[entryPoint][entity]++
+	}
+}
+
+func (i *capacityIndex) remove(key string) {
+	entry, ok := i.entries[key]
+	if !ok {
+		return
+	}
+
+	delete(i.entries, key)
+	i.global--
+	i.perEP[entry.entryPoint]--
+	for _, entity := range entry.entities {
+		i.perEntity[entry.entryPoint][entity]--
+		if i.perEntity[entry.entryPoint][entity] <= 0 {
+			delete(i.perEntity[entry.entryPoint], entity)
+		}
+	}
+}
+
+// updateTail sets the evictable op for a sender given all of its pending ops sorted by descending nonce.
+func (i *capacityIndex) updateTail(entryPoint common.Address, sender common.Address, ops []*userop.UserOperation) {
+	if _, ok := i.tailKeys[entryPoint]; !ok {
+		i.tailKeys[entryPoint] = make(map[common.Address]string)
+	}
+	if key, ok := i.tailKeys[entryPoint][sender]; ok {
+		i.tails.Remove(key)
+		delete(i.tailKeys[entryPoint], sender)
+	}
+	if len(ops) == 0 {
+		return
+	}
+
+	p := &pendingOp{entryPoint, ops[0], ops[0].GetDynamicGasPrice(i.basefee)}
+	i.tails.AddOrUpdate(p.key(), getFeeScore(p.fee), p)
+	i.tailKeys[entryPoint][sender] = p.key()
+}
+
+func newIndexFromQueue(q *userOpQueues) *capacityIndex {
+	i := newCapacityIndex()
+	for _, ep := range q.EntryPoints() {
+		senders := make(map[common.Address]bool)
+		for _, op := range q.All(ep) {
+			i.add(ep, op)
+			senders[op.Sender] = true
+		}
+		for sender := range senders {
+			i.updateTail(ep, sender, q.GetOps(ep, sender))
+		}
+	}
+
+	return i
+}
+
+// setBaseFee reorders the evictable ops if the basefee has changed since the last call.
+func (i *capacityIndex) setBaseFee(basefee *big.Int) {
+	if basefee == nil || (i.basefee != nil && i.basefee.Cmp(basefee) == 0) {
+		return
+	}
+
+	i.basefee = basefee
+	for _, n := range i.tails.GetByRankRange(1, -1, false) {
+		p := n.Value.(*pendingOp)
+		p.fee = p.op.GetDynamicGasPrice(basefee)
+		i.tails.AddOrUpdate(n.Key(), getFeeScore(p.fee), p)
+	}
+}
+
+// selectEvictions returns the ops that must be removed from the pool for the newcomer to be added without
+// exceeding the capacity. The cheapest evictable ops are selected for each exceeded limit, at most one per
+// sender, and the newcomer is rejected if it does not pay a higher effective fee than all of them.
+func (i *capacityIndex) selectEvictions(c Capacity, entryPoint common.Address, op *userop.UserOperation) (
+	[]*pendingOp,
+	error,
+) {
+	type scope struct {
+		name  string
+		limit int
+		size  int
+		match func(p *pendingOp) bool
+	}
+
+	// The op being replaced is not counted towards any limit.
+	entry, isReplacement := i.entries[string(getUniqueKey(entryPoint, op.Sender, op.Nonce))]
+	countExcludingReplaced := func(n int, entity *common.Address) int {
+		if !isReplacement {
+			return n
+		} else if entity == nil {
+			return n - 1
+		}
+
+		for _, e := range entry.entities {
+			if e == *entity {
+				return n - 1
+			}
+		}
+		return n
+	}
+
+	scopes := []scope{}
+	for _, entity := range getEntities(op) {
+		entity := entity
+		scopes = append(scopes, scope{
+			name:  "entity " + entity.String(),
+			limit: c.PerEntity,
+			size:  countExcludingReplaced(i.perEntity[entryPoint][entity], &entity),
+			match: func(p *pendingOp) bool {
+				return p.entryPoint == entryPoint &&
+					(p.op.Sender == entity || p.op.GetFactory() == entity || p.op.GetPaymaster() == entity)
+			},
+		})
+	}
+	scopes = append(
+		scopes,
+		scope{
+			name:  "entryPoint " + entryPoint.String(),
+			limit: c.PerEntryPoint,
+			size:  countExcludingReplaced(i.perEP[entryPoint], nil),
+			match: func(p *pendingOp) bool { return p.entryPoint == entryPoint },
+		},
+		scope{
+			name:  "global",
+			limit: c.Global,
+			size:  countExcludingReplaced(i.global, nil),
+			match: func(p *pendingOp) bool { return true },
+		},
+	)
+
+	fee := op.GetDynamicGasPrice(i.basefee)
+	evicted := []*pendingOp{}
+	selected := make(map[string]bool)
+	for _, s := range scopes {
+		if s.limit <= 0 {
+			continue
+		}
+
+		size := s.size
+		for _, p := range evicted {
+			if s.match(p) {
+				size--
+			}
+		}
+		for ; size >= s.limit; size-- {
+			var cheapest *pendingOp
+			for rank := 1; rank <= i.tails.GetCount(); rank++ {
+				p := i.tails.GetByRank(rank, false).Value.(*pendingOp)
+				if selected[p.key()] ||
+					(p.entryPoint == entryPoint && p.op.Sender == op.Sender) ||
+					!s.match(p) {
+					continue
+				}
+
+				cheapest = p
+				break
+			}
+			if cheapest == nil || fee.Cmp(cheapest.fee) <= 0 {
+				msg := fmt.Sprintf(
+					"mempool: %s capacity reached and effective fee is too low to evict pending ops",
+					s.name,
+				)
+				return nil, errors.NewRPCError(errors.MEMPOOL_FULL, msg, msg)
+			}
+
+			selected[cheapest.key()] = true
+			evicted = append(evicted, cheapest)
+		}
+	}
+
+	return evicted, nil
+}
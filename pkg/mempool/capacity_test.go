@@ -0,0 +1,139 @@
+package mempool
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+func mockOpWithFee(sender common.Address, nonce int64, fee int64) *userop.UserOperation {
+	op := testutils.MockValidInitUserOp()
+	op.Sender = sender
+	op.Nonce = big.NewInt(nonce)
+	op.MaxFeePerGas = big.NewInt(fee)
+	op.MaxPriorityFeePerGas = big.NewInt(fee)
+	return op
+}
+
+// TestAddOpEvictsLowestFee verifies that adding a UserOperation to a full mempool evicts the pending op with
+// the lowest effective fee.
+func TestAddOpEvictsLowestFee(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	mem.SetCapacity(Capacity{Global: 2})
+	ep := testutils.ValidAddress1
+	op1 := mockOpWithFee(testutils.ValidAddress1, 0, 2)
+	op2 := mockOpWithFee(testutils.ValidAddress2, 0, 1)
+	op3 := mockOpWithFee(testutils.ValidAddress3, 0, 3)
+
+	for _, op := range []*userop.UserOperation{op1, op2, op3} {
+		if err := mem.AddOp(ep, op); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	memOps, _ := mem.Dump(ep)
+	if len(memOps) != 2 {
+		t.Fatalf("got length %d, want 2", len(memOps))
+	} else if !testutils.IsOpsEqual(memOps[0], op1) {
+		t.Fatal("incorrect eviction: first op out of place")
+	} else if !testutils.IsOpsEqual(memOps[1], op3) {
+		t.Fatal("incorrect eviction: second op out of place")
+	}
+}
+
+// TestAddOpRejectsLowFeeWhenFull verifies that a UserOperation is rejected with a MEMPOOL_FULL error if it
+// does not pay a higher effective fee than the cheapest pending op.
+func TestAddOpRejectsLowFeeWhenFull(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	mem.SetCapacity(Capacity{Global: 1})
+	ep := testutils.ValidAddress1
+
+	if err := mem.AddOp(ep, mockOpWithFee(testutils.ValidAddress1, 0, 2)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	err := mem.AddOp(ep, mockOpWithFee(testutils.ValidAddress2, 0, 2))
+	if err == nil {
+		t.Fatal("got nil, want err")
+	} else if rpcErr, ok := err.(*errors.RPCError); !ok || rpcErr.Code() != errors.MEMPOOL_FULL {
+		t.Fatalf("got %v, want MEMPOOL_FULL error", err)
+	}
+
+	if memOps, _ := mem.Dump(ep); len(memOps) != 1 {
+		t.Fatalf("got length %d, want 1", len(memOps))
+	}
+}
+
+// TestAddOpNeverEvictsBelowHigherNonce verifies that only the highest pending nonce of a sender can be
+// evicted, even if an op with a lower nonce pays a lower effective fee.
+func TestAddOpNeverEvictsBelowHigherNonce(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	mem.SetCapacity(Capacity{Global: 2})
+	ep := testutils.ValidAddress1
+	op1 := mockOpWithFee(testutils.ValidAddress1, 0, 1)
+	op2 := mockOpWithFee(testutils.ValidAddress1, 1, 5)
+
+	if err := mem.AddOp(ep, op1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddOp(ep, op2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if err := mem.AddOp(ep, mockOpWithFee(testutils.ValidAddress2, 0, 3)); err == nil {
+		t.Fatal("got nil, want err")
+	}
+
+	op3 := mockOpWithFee(testutils.ValidAddress2, 0, 6)
+	if err := mem.AddOp(ep, op3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	memOps, _ := mem.GetOps(ep, testutils.ValidAddress1)
+	if len(memOps) != 1 {
+		t.Fatalf("got length %d, want 1", len(memOps))
+	} else if !testutils.IsOpsEqual(memOps[0], op1) {
+		t.Fatalf("ops not equal: %s", testutils.GetOpsDiff(op1, memOps[0]))
+	}
+}
+
+// TestReplaceIfEqualIgnoresCapacity verifies that replacing a pending op in place is not rejected by a full
+// mempool even if it pays a lower effective fee than all other pending ops.
+func TestReplaceIfEqualIgnoresCapacity(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	mem.SetCapacity(Capacity{Global: 2})
+	ep := testutils.ValidAddress1
+	op1 := mockOpWithFee(testutils.ValidAddress1, 0, 2)
+	op2 := mockOpWithFee(testutils.ValidAddress2, 0, 3)
+
+	if err := mem.AddOp(ep, op1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddOp(ep, op2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	op3 := mockOpWithFee(testutils.ValidAddress1, 0, 1)
+	hash := op1.GetUserOpHash(ep, testutils.ChainID)
+	if ok, err := mem.ReplaceIfEqual(ep, testutils.ChainID, hash, op3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if !ok {
+		t.Fatal("got false, want true")
+	}
+
+	if memOps, _ := mem.Dump(ep); len(memOps) != 2 {
+		t.Fatalf("got length %d, want 2", len(memOps))
+	}
+}
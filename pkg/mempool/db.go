@@ -3,10 +3,11 @@ package mempool
 import (
 	"encoding/json"
 	"math/big"
+	"time"
 
-	badger "github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
@@ -14,6 +15,24 @@ var (
 	keyPrefix = dbutils.JoinValues("mempool")
 )
 
+// OpInfo is the metadata persisted along with a pending UserOperation.
+type OpInfo struct {
+	// AdmittedAt is when the UserOperation was added to the mempool. Replacing a pending UserOperation with
+	// AddOp resets it.
+	AdmittedAt time.Time
+
+	// ValidUntil is the validUntil timestamp returned by simulation. A zero value means the UserOperation does
+	// not expire.
+	ValidUntil time.Time
+}
+
+// opInfoJSON holds the OpInfo fields that are stored in the same DB value as the UserOperation fields. Values
+// written before OpInfo was persisted do not have them.
+type opInfoJSON struct {
+	AdmittedAt int64 `json:"admittedAt,omitempty"`
+	ValidUntil int64 `json:"validUntil,omitempty"`
+}
+
 func getUniqueKey(entryPoint common.Address, sender common.Address, nonce *big.Int) []byte {
 	return []byte(
 		dbutils.JoinValues(keyPrefix, entryPoint.String(), sender.String(), nonce.String()),
@@ -25,6 +44,34 @@ func getEntryPointFromDBKey(key []byte) common.Address {
 	return common.HexToAddress(slc[1])
 }
 
+func getDBValue(op *userop.UserOperation, info *OpInfo) ([]byte, error) {
+	data, err := op.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	v := opInfoJSON{}
+	if !info.AdmittedAt.IsZero() {
+		v.AdmittedAt = info.AdmittedAt.Unix()
+	}
+	if !info.ValidUntil.IsZero() {
+		v.ValidUntil = info.ValidUntil.Unix()
+	}
+	meta, err := json.Marshal(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(meta, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
 func getUserOpFromDBValue(value []byte) (*userop.UserOperation, error) {
 	data := make(map[string]any)
 	if err := json.Unmarshal(value, &data); err != nil {
@@ -39,33 +86,54 @@ func getUserOpFromDBValue(value []byte) (*userop.UserOperation, error) {
 	return op, nil
 }
 
-func loadFromDisk(db *badger.DB, q *userOpQueues) error {
-	return db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 10
-		it := txn.NewIterator(opts)
-		prefix := []byte(keyPrefix)
-		defer it.Close()
-
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			ep := getEntryPointFromDBKey(item.Key())
-
-			err := item.Value(func(v []byte) error {
-				op, err := getUserOpFromDBValue(v)
-				if err != nil {
-					return err
-				}
+// getOpInfoFromDBValue returns the OpInfo stored in the DB value. Fields that are not set are left as zero
+// values.
+func getOpInfoFromDBValue(value []byte) (*OpInfo, error) {
+	var v opInfoJSON
+	if err := json.Unmarshal(value, &v); err != nil {
+		return nil, err
+	}
 
-				q.AddOp(ep, op)
-				return nil
-			})
+	info := &OpInfo{}
+	if v.AdmittedAt != 0 {
+		info.AdmittedAt = time.Unix(v.AdmittedAt, 0)
+	}
+	if v.ValidUntil != 0 {
+		info.ValidUntil = time.Unix(v.ValidUntil, 0)
+	}
+	return info, nil
+}
 
+// loadFromDisk adds all persisted UserOperations to the queue and their OpInfo to infos. UserOperations
+// without an admission time are considered admitted at loadedAt and returned so that it can be persisted.
+func loadFromDisk(
+	db storage.Store,
+	q *userOpQueues,
+	infos map[string]*OpInfo,
+	loadedAt time.Time,
+) ([]*Entry, error) {
+	unset := []*Entry{}
+	err := db.View(func(txn storage.Txn) error {
+		return txn.Iterate([]byte(keyPrefix), func(key []byte, value []byte) error {
+			op, err := getUserOpFromDBValue(value)
+			if err != nil {
+				return err
+			}
+			info, err := getOpInfoFromDBValue(value)
 			if err != nil {
 				return err
 			}
-		}
+			ep := getEntryPointFromDBKey(key)
+			if info.AdmittedAt.IsZero() {
+				info.AdmittedAt = loadedAt
+				unset = append(unset, &Entry{EntryPoint: ep, UserOp: op, Info: *info})
+			}
 
-		return nil
+			q.AddOp(ep, op)
+			infos[string(key)] = info
+			return nil
+		})
 	})
+
+	return unset, err
 }
@@ -0,0 +1,124 @@
+package mempool
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// EventType describes a change to the mempool.
+type EventType string
+
+const (
+	// EventAdded is published when a new UserOperation is added to the mempool.
+	EventAdded EventType = "added"
+
+	// EventReplaced is published when a pending UserOperation is replaced by one with the same EntryPoint,
+	// Sender, and Nonce values.
+	EventReplaced EventType = "replaced"
+
+	// EventRemoved is published when a UserOperation is removed from the mempool. The Reason of the Event is
+	// set to one of the Reason constants.
+	EventRemoved EventType = "removed"
+
+	// EventIncluded is published when a UserOperation has been confirmed on-chain.
+	EventIncluded EventType = "included"
+)
+
+const (
+	// ReasonRemoved is the default reason of a removed UserOperation.
+	ReasonRemoved = "removed"
+
+	// ReasonBundled is the reason of a UserOperation removed because it was sent in a bundle.
+	ReasonBundled = "bundled"
+
+	// ReasonDropped is the reason of a UserOperation removed because it failed a check.
+	ReasonDropped = "dropped"
+
+	// ReasonEvicted is the reason of a UserOperation removed to make room for one paying a higher fee.
+	ReasonEvicted = "evicted"
+)
+
+// DefaultEventBuffer is the number of Events that can be pending for a subscriber before new ones are
+// dropped.
+var DefaultEventBuffer = 256
+
+// Event is published to all subscribers of the mempool on every change.
+type Event struct {
+	Type       EventType
+	EntryPoint common.Address
+	UserOp     *userop.UserOperation
+	Reason     string
+	TxHash     common.Hash
+}
+
+// eventBus fans out Events to all subscribers. Publishing never blocks, an Event is dropped for a subscriber
+// that does not keep up.
+type eventBus struct {
+	mu   sync.RWMutex
+	next int
+	subs map[int]chan *Event
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[int]chan *Event)}
+}
+
+func (b *eventBus) subscribe() (<-chan *Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	ch := make(chan *Event, DefaultEventBuffer)
+	b.next++
+	b.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subs, id)
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBus) publish(events ...*Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ev := range events {
+		for _, ch := range b.subs {
+			select {
+			case ch <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel that receives every Event published by the mempool from now on and a function
+// to unsubscribe. The channel is closed once unsubscribed. Events are dropped if the channel is full.
+func (m *Mempool) Subscribe() (<-chan *Event, func()) {
+	return m.events.subscribe()
+}
+
+// NotifyIncluded publishes an EventIncluded for every UserOperation confirmed on-chain in the given
+// transaction. Its signature matches a reconcile.ConfirmedFunc.
+func (m *Mempool) NotifyIncluded(
+	entryPoint common.Address,
+	chainID *big.Int,
+	txHash common.Hash,
+	included []*userop.UserOperation,
+) error {
+	events := []*Event{}
+	for _, op := range included {
+		events = append(events, &Event{Type: EventIncluded, EntryPoint: entryPoint, UserOp: op, TxHash: txHash})
+	}
+	m.events.publish(events...)
+	return nil
+}
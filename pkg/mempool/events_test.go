@@ -0,0 +1,71 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// TestSubscribePublishesChanges verifies that subscribers receive an Event for every add, replacement,
+// removal, and inclusion.
+func TestSubscribePublishesChanges(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	ep := testutils.ValidAddress1
+	events, unsubscribe := mem.Subscribe()
+	defer unsubscribe()
+
+	op := mockOpWithFee(testutils.ValidAddress1, 0, 1)
+	rep := mockOpWithFee(testutils.ValidAddress1, 0, 2)
+	if err := mem.AddOp(ep, op); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddOp(ep, rep); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.RemoveOpsWithReason(ep, ReasonDropped, rep); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	txHash := common.HexToHash(testutils.MockHash)
+	if err := mem.NotifyIncluded(ep, testutils.ChainID, txHash, []*userop.UserOperation{rep}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	want := []EventType{EventAdded, EventReplaced, EventRemoved, EventIncluded}
+	for _, typ := range want {
+		ev := <-events
+		if ev.Type != typ {
+			t.Fatalf("got event %s, want %s", ev.Type, typ)
+		} else if ev.Type == EventRemoved && ev.Reason != ReasonDropped {
+			t.Fatalf("got reason %s, want %s", ev.Reason, ReasonDropped)
+		} else if ev.Type == EventIncluded && ev.TxHash != txHash {
+			t.Fatalf("got txHash %s, want %s", ev.TxHash, txHash)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("got event %s, want none", ev.Type)
+	default:
+	}
+}
+
+// TestUnsubscribeClosesChannel verifies that the channel is closed and no longer receives Events once
+// unsubscribed.
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	events, unsubscribe := mem.Subscribe()
+	unsubscribe()
+	unsubscribe()
+
+	if err := mem.AddOp(testutils.ValidAddress1, mockOpWithFee(testutils.ValidAddress1, 0, 1)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("got event, want closed channel")
+	}
+}
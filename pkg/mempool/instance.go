@@ -3,39 +3,101 @@
 package mempool
 
 import (
-	badger "github.com/dgraph-io/badger/v3"
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-logr/logr"
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/gasprice"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Mempool provides read and write access to a pool of pending UserOperations which have passed all Client
 // checks.
 type Mempool struct {
-	db    *badger.DB
-	queue *userOpQueues
+	mu       sync.Mutex
+	db       storage.Store
+	queue    *userOpQueues
+	infos    map[string]*OpInfo
+	index    *capacityIndex
+	capacity Capacity
+	gbf      gasprice.GetBaseFeeFunc
+	logger   logr.Logger
+	evicted  metric.Int64Counter
+	events   *eventBus
 }
 
-// New creates an instance of a mempool that uses an embedded DB to persist and load UserOperations from disk
-// incase of a reset.
-func New(db *badger.DB) (*Mempool, error) {
+// New creates an instance of a mempool that uses an embedded Store to persist and load UserOperations incase
+// of a reset. The OpInfo of each UserOperation is persisted along with it so that its age is kept across
+// resets.
+func New(db storage.Store) (*Mempool, error) {
 	queue := newUserOpQueue()
-	err := loadFromDisk(db, queue)
+	infos := make(map[string]*OpInfo)
+	unset, err := loadFromDisk(db, queue, infos, time.Now())
 	if err != nil {
 		return nil, err
 	}
+	if len(unset) > 0 {
+		if err := WriteEntries(db, unset...); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Mempool{
+		db:     db,
+		queue:  queue,
+		infos:  infos,
+		index:  newIndexFromQueue(queue),
+		gbf:    gasprice.NoopGetBaseFeeFunc(),
+		logger: logger.NewZeroLogr().WithName("mempool"),
+		events: newEventBus(),
+	}, nil
+}
 
-	return &Mempool{db, queue}, nil
+// SetCapacity defines the maximum number of UserOperations the mempool will hold. The default value has no
+// limits.
+func (m *Mempool) SetCapacity(c Capacity) {
+	m.capacity = c
+}
+
+// SetGetBaseFeeFunc defines the function used to retrieve an estimate for basefee when comparing the
+// effective fee of UserOperations for eviction.
+func (m *Mempool) SetGetBaseFeeFunc(gbf gasprice.GetBaseFeeFunc) {
+	m.gbf = gbf
+}
+
+// UseLogger defines the logger object used by the Mempool instance based on the go-logr/logr interface.
+func (m *Mempool) UseLogger(logger logr.Logger) {
+	m.logger = logger.WithName("mempool")
+}
+
+// UserMeter defines an opentelemetry meter object used by the Mempool instance to count the UserOperations
+// evicted when the pool is at capacity.
+func (m *Mempool) UserMeter(meter metric.Meter) error {
+	evicted, err := meter.Int64Counter("mempool_evicted_ops")
+	if err != nil {
+		return err
+	}
+
+	m.evicted = evicted
+	return nil
 }
 
 // HasUserOpHash returns true if the UserOperation with the given userOpHash is
 // in the mempool.
 func (m *Mempool) HasUserOpHash(userOpHash string) (bool, error) {
-	err := m.db.View(func(txn *badger.Txn) error {
+	err := m.db.View(func(txn storage.Txn) error {
 		_, err := txn.Get([]byte(userOpHash))
 		return err
 	})
 
-	if err == badger.ErrKeyNotFound {
+	if err == storage.ErrKeyNotFound {
 
 		return false, nil
 	} else if err != nil {
@@ -51,28 +113,228 @@ func (m *Mempool) GetOps(entryPoint common.Address, sender common.Address) ([]*u
 	return ops, nil
 }
 
+// GetOpInfo returns the OpInfo of the pending UserOperation with the same EntryPoint, Sender, and Nonce
+// values as op. It returns false if there is no such UserOperation in the mempool.
+func (m *Mempool) GetOpInfo(entryPoint common.Address, op *userop.UserOperation) (OpInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, ok := m.infos[string(getUniqueKey(entryPoint, op.Sender, op.Nonce))]
+	if !ok {
+		return OpInfo{}, false
+	}
+	return *info, true
+}
+
+// CountOpsByEntity returns the number of pending UserOperations for an EntryPoint that use the given address
+// as their sender, factory, or paymaster.
+func (m *Mempool) CountOpsByEntity(entryPoint common.Address, entity common.Address) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.index.perEntity[entryPoint][entity]
+}
+
 // AddOp adds a UserOperation to the mempool or replace an existing one with the same EntryPoint, Sender, and
-// Nonce values.
+// Nonce values. Replacement rules (i.e. the minimum fee bump) are not enforced by the mempool and must be
+// validated by a Client module before calling AddOp.
+//
+// If the mempool is at capacity, the pending UserOperations with the lowest effective fee are evicted to make
+// room. A UserOperation that does not pay a higher effective fee than the ones it would evict is rejected.
 func (m *Mempool) AddOp(entryPoint common.Address, op *userop.UserOperation) error {
-	data, err := op.MarshalJSON()
+	return m.AddOpWithValidUntil(entryPoint, op, time.Time{})
+}
+
+// AddOpWithValidUntil adds a UserOperation to the mempool in the same way as AddOp and persists the validUntil
+// timestamp returned by simulation along with it. A zero validUntil means the UserOperation does not expire.
+func (m *Mempool) AddOpWithValidUntil(
+	entryPoint common.Address,
+	op *userop.UserOperation,
+	validUntil time.Time,
+) error {
+	return m.AddOpWithInfo(entryPoint, op, OpInfo{AdmittedAt: time.Now(), ValidUntil: validUntil})
+}
+
+// AddOpWithInfo adds a UserOperation to the mempool in the same way as AddOp and persists the given OpInfo
+// along with it. This is used to put back a UserOperation that was previously removed (e.g. from a failed
+// bundle) without resetting its admission time. A zero AdmittedAt is set to the current time.
+func (m *Mempool) AddOpWithInfo(entryPoint common.Address, op *userop.UserOperation, info OpInfo) error {
+	if info.AdmittedAt.IsZero() {
+		info.AdmittedAt = time.Now()
+	}
+
+	var bf *big.Int
+	if m.capacity.isEnforced() {
+		var err error
+		if bf, err = m.gbf(); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.index.setBaseFee(bf)
+	evicted, err := m.getEvictions(entryPoint, op)
+	if err != nil {
+		return err
+	}
+	return m.addOp(entryPoint, op, &info, evicted...)
+}
+
+// ReplaceIfEqual replaces a pending UserOperation with the same EntryPoint, Sender, and Nonce values as op
+// only if its userOpHash is equal to the given one. It returns false if the pending UserOperation was removed
+// or replaced by a different one in the meantime. The check and the write are done atomically. Since the
+// replacement does not grow the mempool, it is exempt from the capacity limits. The OpInfo of the pending
+// UserOperation is kept.
+func (m *Mempool) ReplaceIfEqual(
+	entryPoint common.Address,
+	chainID *big.Int,
+	userOpHash common.Hash,
+	op *userop.UserOperation,
+) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.isPendingEqual(entryPoint, chainID, userOpHash, op) {
+		return false, nil
+	}
+
+	info := *m.infos[string(getUniqueKey(entryPoint, op.Sender, op.Nonce))]
+	return true, m.addOp(entryPoint, op, &info)
+}
+
+// RemoveIfEqual removes a pending UserOperation with the same EntryPoint, Sender, and Nonce values as op only
+// if its userOpHash is equal to the given one and publishes an EventRemoved with the given reason. It returns
+// false if the pending UserOperation was removed or replaced by a different one in the meantime. The check
+// and the write are done atomically.
+func (m *Mempool) RemoveIfEqual(
+	entryPoint common.Address,
+	chainID *big.Int,
+	userOpHash common.Hash,
+	op *userop.UserOperation,
+	reason string,
+) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.isPendingEqual(entryPoint, chainID, userOpHash, op) {
+		return false, nil
+	}
+
+	return true, m.removeOps(entryPoint, reason, op)
+}
+
+// isPendingEqual returns true if the pending UserOperation with the same EntryPoint, Sender, and Nonce values
+// as op has the given userOpHash.
+func (m *Mempool) isPendingEqual(
+	entryPoint common.Address,
+	chainID *big.Int,
+	userOpHash common.Hash,
+	op *userop.UserOperation,
+) bool {
+	for _, penOp := range m.queue.GetOps(entryPoint, op.Sender) {
+		if penOp.Nonce.Cmp(op.Nonce) == 0 {
+			return penOp.GetUserOpHash(entryPoint, chainID) == userOpHash
+		}
+	}
+	return false
+}
+
+func (m *Mempool) addOp(
+	entryPoint common.Address,
+	op *userop.UserOperation,
+	info *OpInfo,
+	evicted ...*pendingOp,
+) error {
+	data, err := getDBValue(op, info)
 	if err != nil {
 		return err
 	}
 
-	err = m.db.Update(func(txn *badger.Txn) error {
+	err = m.db.Update(func(txn storage.Txn) error {
+		for _, p := range evicted {
+			if err := txn.Delete(getUniqueKey(p.entryPoint, p.op.Sender, p.op.Nonce)); err != nil {
+				return err
+			}
+		}
+
 		return txn.Set(getUniqueKey(entryPoint, op.Sender, op.Nonce), data)
 	})
 	if err != nil {
 		return err
 	}
 
+	events := []*Event{}
+	for _, p := range evicted {
+		m.removeFromQueue(p.entryPoint, p.op)
+		events = append(
+			events,
+			&Event{Type: EventRemoved, EntryPoint: p.entryPoint, UserOp: p.op, Reason: ReasonEvicted},
+		)
+		m.logger.Info(
+			"userOp evicted",
+			"entrypoint", p.entryPoint.String(),
+			"sender", p.op.Sender.String(),
+			"nonce", p.op.Nonce.String(),
+			"effective_fee", p.fee.String(),
+		)
+		if m.evicted != nil {
+			m.evicted.Add(
+				context.Background(),
+				1,
+				metric.WithAttributes(attribute.String("entrypoint", p.entryPoint.String())),
+			)
+		}
+	}
+	ev := &Event{Type: EventAdded, EntryPoint: entryPoint, UserOp: op}
+	if _, ok := m.index.entries[string(getUniqueKey(entryPoint, op.Sender, op.Nonce))]; ok {
+		ev.Type = EventReplaced
+	}
 	m.queue.AddOp(entryPoint, op)
+	m.infos[string(getUniqueKey(entryPoint, op.Sender, op.Nonce))] = info
+	m.index.add(entryPoint, op)
+	m.index.updateTail(entryPoint, op.Sender, m.queue.GetOps(entryPoint, op.Sender))
+	m.events.publish(append(events, ev)...)
 	return nil
 }
 
+func (m *Mempool) removeFromQueue(entryPoint common.Address, op *userop.UserOperation) {
+	key := string(getUniqueKey(entryPoint, op.Sender, op.Nonce))
+	m.queue.RemoveOps(entryPoint, op)
+	delete(m.infos, key)
+	m.index.remove(key)
+	m.index.updateTail(entryPoint, op.Sender, m.queue.GetOps(entryPoint, op.Sender))
+}
+
+func (m *Mempool) getEvictions(entryPoint common.Address, op *userop.UserOperation) ([]*pendingOp, error) {
+	if !m.capacity.isEnforced() {
+		return nil, nil
+	}
+
+	return m.index.selectEvictions(m.capacity, entryPoint, op)
+}
+
 // RemoveOps removes a list of UserOperations from the mempool by EntryPoint, Sender, and Nonce values.
 func (m *Mempool) RemoveOps(entryPoint common.Address, ops ...*userop.UserOperation) error {
-	err := m.db.Update(func(txn *badger.Txn) error {
+	return m.RemoveOpsWithReason(entryPoint, ReasonRemoved, ops...)
+}
+
+// RemoveOpsWithReason removes a list of UserOperations from the mempool like RemoveOps and publishes an
+// EventRemoved with the given reason for each of them.
+func (m *Mempool) RemoveOpsWithReason(
+	entryPoint common.Address,
+	reason string,
+	ops ...*userop.UserOperation,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.removeOps(entryPoint, reason, ops...)
+}
+
+func (m *Mempool) removeOps(entryPoint common.Address, reason string, ops ...*userop.UserOperation) error {
+	err := m.db.Update(func(txn storage.Txn) error {
 		for _, op := range ops {
 			err := txn.Delete(getUniqueKey(entryPoint, op.Sender, op.Nonce))
 			if err != nil {
@@ -86,7 +348,12 @@ func (m *Mempool) RemoveOps(entryPoint common.Address, ops ...*userop.UserOperat
 		return err
 	}
 
-	m.queue.RemoveOps(entryPoint, ops...)
+	events := []*Event{}
+	for _, op := range ops {
+		m.removeFromQueue(entryPoint, op)
+		events = append(events, &Event{Type: EventRemoved, EntryPoint: entryPoint, UserOp: op, Reason: reason})
+	}
+	m.events.publish(events...)
 	return nil
 }
 
@@ -97,10 +364,15 @@ func (m *Mempool) Dump(entryPoint common.Address) ([]*userop.UserOperation, erro
 
 // Clear will clear the entire embedded db and reset it to a clean state.
 func (m *Mempool) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if err := m.db.DropAll(); err != nil {
 		return err
 	}
 	m.queue = newUserOpQueue()
+	m.infos = make(map[string]*OpInfo)
+	m.index = newCapacityIndex()
 
 	return nil
 }
@@ -3,6 +3,7 @@ package mempool
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
@@ -11,7 +12,7 @@ import (
 // TestAddOpToMempool verifies that a UserOperation can be added to the mempool and later retrieved without
 // any changes.
 func TestAddOpToMempool(t *testing.T) {
-	db := testutils.DBMock()
+	db := testutils.StoreMock()
 	defer db.Close()
 	mem, _ := New(db)
 	ep := testutils.ValidAddress1
@@ -37,7 +38,7 @@ func TestAddOpToMempool(t *testing.T) {
 // TestReplaceOpInMempool verifies that a UserOperation with same Sender and Nonce can replace another
 // UserOperation already in the mempool.
 func TestReplaceOpInMempool(t *testing.T) {
-	db := testutils.DBMock()
+	db := testutils.StoreMock()
 	defer db.Close()
 	mem, _ := New(db)
 	ep := testutils.ValidAddress1
@@ -67,7 +68,7 @@ func TestReplaceOpInMempool(t *testing.T) {
 
 // TestRemoveOpsFromMempool verifies that a UserOperation can be added to the mempool and later removed.
 func TestRemoveOpsFromMempool(t *testing.T) {
-	db := testutils.DBMock()
+	db := testutils.StoreMock()
 	defer db.Close()
 	mem, _ := New(db)
 	ep := testutils.ValidAddress1
@@ -93,7 +94,7 @@ func TestRemoveOpsFromMempool(t *testing.T) {
 // TestDumpFromMempool verifies that bundles are being built with UserOperations in the mempool. Ordering is
 // FIFO and more specific sorting and filtering is left up to downstream modules to implement.
 func TestDumpFromMempool(t *testing.T) {
-	db := testutils.DBMock()
+	db := testutils.StoreMock()
 	defer db.Close()
 	mem, _ := New(db)
 	ep := testutils.ValidAddress1
@@ -138,7 +139,7 @@ func TestDumpFromMempool(t *testing.T) {
 // TestNewMempoolLoadsFromDisk verifies that a new Mempool instance is built from ops saved in the DB without
 // including ops previously removed.
 func TestNewMempoolLoadsFromDisk(t *testing.T) {
-	db := testutils.DBMock()
+	db := testutils.StoreMock()
 	defer db.Close()
 	mem1, _ := New(db)
 	ep := testutils.ValidAddress1
@@ -170,3 +171,125 @@ func TestNewMempoolLoadsFromDisk(t *testing.T) {
 		t.Fatalf("ops not equal: %s", testutils.GetOpsDiff(op2, memOps[0]))
 	}
 }
+
+// TestReplaceIfEqualSkipsChangedOp verifies that a UserOperation is only replaced if the pending op with the
+// same Sender and Nonce still has the expected userOpHash.
+func TestReplaceIfEqualSkipsChangedOp(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	ep := testutils.ValidAddress1
+	op1 := testutils.MockValidInitUserOp()
+	op2 := testutils.MockValidInitUserOp()
+	op2.MaxPriorityFeePerGas = big.NewInt(0).Add(op1.MaxPriorityFeePerGas, common.Big1)
+	op3 := testutils.MockValidInitUserOp()
+	op3.CallData = []byte{0x01}
+
+	if err := mem.AddOp(ep, op1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddOp(ep, op2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	hash := op1.GetUserOpHash(ep, testutils.ChainID)
+	if ok, err := mem.ReplaceIfEqual(ep, testutils.ChainID, hash, op3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if ok {
+		t.Fatal("got true, want false")
+	}
+
+	hash = op2.GetUserOpHash(ep, testutils.ChainID)
+	if ok, err := mem.ReplaceIfEqual(ep, testutils.ChainID, hash, op3); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if !ok {
+		t.Fatal("got false, want true")
+	}
+
+	memOps, _ := mem.GetOps(ep, op3.Sender)
+	if len(memOps) != 1 {
+		t.Fatalf("got length %d, want 1", len(memOps))
+	} else if !testutils.IsOpsEqual(op3, memOps[0]) {
+		t.Fatalf("ops not equal: %s", testutils.GetOpsDiff(op3, memOps[0]))
+	}
+}
+
+// TestRemoveIfEqualSkipsChangedOp verifies that a UserOperation is only removed if the pending op with the
+// same Sender and Nonce still has the expected userOpHash.
+func TestRemoveIfEqualSkipsChangedOp(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	ep := testutils.ValidAddress1
+	op1 := testutils.MockValidInitUserOp()
+	op2 := testutils.MockValidInitUserOp()
+	op2.MaxPriorityFeePerGas = big.NewInt(0).Add(op1.MaxPriorityFeePerGas, common.Big1)
+
+	if err := mem.AddOp(ep, op1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := mem.AddOp(ep, op2); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	hash := op1.GetUserOpHash(ep, testutils.ChainID)
+	if ok, err := mem.RemoveIfEqual(ep, testutils.ChainID, hash, op1, ReasonDropped); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if ok {
+		t.Fatal("got true, want false")
+	}
+	if memOps, _ := mem.GetOps(ep, op2.Sender); len(memOps) != 1 {
+		t.Fatalf("got length %d, want 1", len(memOps))
+	}
+
+	hash = op2.GetUserOpHash(ep, testutils.ChainID)
+	if ok, err := mem.RemoveIfEqual(ep, testutils.ChainID, hash, op2, ReasonDropped); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if !ok {
+		t.Fatal("got false, want true")
+	}
+	if memOps, _ := mem.GetOps(ep, op2.Sender); len(memOps) != 0 {
+		t.Fatalf("got length %d, want 0", len(memOps))
+	}
+}
+
+// TestOpInfoPersisted verifies that the admission time and validUntil timestamp of a UserOperation are loaded
+// by a new mempool created with the same Store and kept when it is replaced by ReplaceIfEqual.
+func TestOpInfoPersisted(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := New(db)
+	ep := testutils.ValidAddress1
+	op := testutils.MockValidInitUserOp()
+	validUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	if err := mem.AddOpWithValidUntil(ep, op, validUntil); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	info, ok := mem.GetOpInfo(ep, op)
+	if !ok {
+		t.Fatal("got false, want true")
+	}
+
+	solved := testutils.MockValidInitUserOp()
+	solved.CallData = common.Hex2Bytes("0xdead")
+	hash := op.GetUserOpHash(ep, testutils.ChainID)
+	if ok, err := mem.ReplaceIfEqual(ep, testutils.ChainID, hash, solved); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if !ok {
+		t.Fatal("got false, want true")
+	}
+
+	loaded, err := New(db)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	got, ok := loaded.GetOpInfo(ep, solved)
+	if !ok {
+		t.Fatal("got false, want true")
+	} else if got.AdmittedAt.Unix() != info.AdmittedAt.Unix() {
+		t.Fatalf("got admittedAt %s, want %s", got.AdmittedAt, info.AdmittedAt)
+	} else if !got.ValidUntil.Equal(validUntil) {
+		t.Fatalf("got validUntil %s, want %s", got.ValidUntil, validUntil)
+	}
+}
@@ -0,0 +1,147 @@
+package mempool
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// Entry is a UserOperation persisted in the mempool Store along with its EntryPoint and OpInfo. It is used to
+// inspect and repair a Store without running a Mempool.
+type Entry struct {
+	EntryPoint common.Address
+	UserOp     *userop.UserOperation
+	Info       OpInfo
+}
+
+type entryJSON struct {
+	EntryPoint    common.Address  `json:"entryPoint"`
+	UserOperation json.RawMessage `json:"userOperation"`
+	AdmittedAt    int64           `json:"admittedAt,omitempty"`
+	ValidUntil    int64           `json:"validUntil,omitempty"`
+}
+
+// MarshalJSON returns the JSON encoding of an Entry as used for NDJSON exports.
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	op, err := e.UserOp.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &entryJSON{EntryPoint: e.EntryPoint, UserOperation: op}
+	if !e.Info.AdmittedAt.IsZero() {
+		v.AdmittedAt = e.Info.AdmittedAt.Unix()
+	}
+	if !e.Info.ValidUntil.IsZero() {
+		v.ValidUntil = e.Info.ValidUntil.Unix()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON parses an Entry encoded by MarshalJSON.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var v entryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	op, err := getUserOpFromDBValue(v.UserOperation)
+	if err != nil {
+		return err
+	}
+	e.EntryPoint = v.EntryPoint
+	e.UserOp = op
+	e.Info = OpInfo{}
+	if v.AdmittedAt != 0 {
+		e.Info.AdmittedAt = time.Unix(v.AdmittedAt, 0)
+	}
+	if v.ValidUntil != 0 {
+		e.Info.ValidUntil = time.Unix(v.ValidUntil, 0)
+	}
+	return nil
+}
+
+// Filter selects the Entries of a Store. Nil fields match every Entry. ChainID is required to match by
+// UserOpHash.
+type Filter struct {
+	EntryPoint *common.Address
+	Sender     *common.Address
+	UserOpHash *common.Hash
+	ChainID    *big.Int
+}
+
+func (f *Filter) match(e *Entry) bool {
+	return (f.EntryPoint == nil || *f.EntryPoint == e.EntryPoint) &&
+		(f.Sender == nil || *f.Sender == e.UserOp.Sender) &&
+		(f.UserOpHash == nil || (f.ChainID != nil && *f.UserOpHash == e.UserOp.GetUserOpHash(e.EntryPoint, f.ChainID)))
+}
+
+func iterateEntries(txn storage.Txn, f Filter, fn func(key []byte, e *Entry) error) error {
+	return txn.Iterate([]byte(keyPrefix), func(key []byte, value []byte) error {
+		op, err := getUserOpFromDBValue(value)
+		if err != nil {
+			return err
+		}
+		info, err := getOpInfoFromDBValue(value)
+		if err != nil {
+			return err
+		}
+
+		e := &Entry{EntryPoint: getEntryPointFromDBKey(key), UserOp: op, Info: *info}
+		if !f.match(e) {
+			return nil
+		}
+		return fn(key, e)
+	})
+}
+
+// ReadEntries returns all Entries in the Store that match the Filter in key order.
+func ReadEntries(db storage.Store, f Filter) ([]*Entry, error) {
+	entries := []*Entry{}
+	err := db.View(func(txn storage.Txn) error {
+		return iterateEntries(txn, f, func(key []byte, e *Entry) error {
+			entries = append(entries, e)
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// WriteEntries persists the Entries to the Store, replacing any with the same EntryPoint, Sender, and Nonce.
+// Capacity limits are not enforced. They are loaded by the next Mempool created with the Store, which
+// considers Entries without an admission time as admitted when it is created.
+func WriteEntries(db storage.Store, entries ...*Entry) error {
+	return db.Update(func(txn storage.Txn) error {
+		for _, e := range entries {
+			data, err := getDBValue(e.UserOp, &e.Info)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(getUniqueKey(e.EntryPoint, e.UserOp.Sender, e.UserOp.Nonce), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteEntries removes all Entries in the Store that match the Filter and returns them.
+func DeleteEntries(db storage.Store, f Filter) ([]*Entry, error) {
+	deleted := []*Entry{}
+	err := db.Update(func(txn storage.Txn) error {
+		return iterateEntries(txn, f, func(key []byte, e *Entry) error {
+			deleted = append(deleted, e)
+			return txn.Delete(key)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,86 @@
+package mempool
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// TestEntriesRoundTrip verifies that Entries exported as JSON can be written back to an empty Store and loaded
+// by a Mempool.
+func TestEntriesRoundTrip(t *testing.T) {
+	src := testutils.StoreMock()
+	defer src.Close()
+	mem, _ := New(src)
+	ep := testutils.ValidAddress1
+	op1 := mockOpWithFee(testutils.ValidAddress2, 0, 1)
+	op2 := mockOpWithFee(testutils.ValidAddress3, 0, 1)
+	for _, op := range []*userop.UserOperation{op1, op2} {
+		if err := mem.AddOp(ep, op); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	entries, err := ReadEntries(src, Filter{})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(entries) != 2 {
+		t.Fatalf("got length %d, want 2", len(entries))
+	}
+
+	imported := []*Entry{}
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		var out Entry
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		imported = append(imported, &out)
+	}
+
+	dst := testutils.StoreMock()
+	defer dst.Close()
+	if err := WriteEntries(dst, imported...); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	loaded, err := New(dst)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if memOps, _ := loaded.GetOps(ep, op1.Sender); len(memOps) != 1 {
+		t.Fatalf("got length %d, want 1", len(memOps))
+	} else if !testutils.IsOpsEqual(memOps[0], op1) {
+		t.Fatalf("ops not equal: %s", testutils.GetOpsDiff(op1, memOps[0]))
+	}
+}
+
+// TestDeleteEntriesByHash verifies that only the Entry with a matching userOpHash is deleted.
+func TestDeleteEntriesByHash(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	ep := testutils.ValidAddress1
+	op1 := mockOpWithFee(testutils.ValidAddress2, 0, 1)
+	op2 := mockOpWithFee(testutils.ValidAddress3, 0, 1)
+	if err := WriteEntries(db, &Entry{EntryPoint: ep, UserOp: op1}, &Entry{EntryPoint: ep, UserOp: op2}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	hash := op2.GetUserOpHash(ep, testutils.ChainID)
+	deleted, err := DeleteEntries(db, Filter{UserOpHash: &hash, ChainID: testutils.ChainID})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(deleted) != 1 || !testutils.IsOpsEqual(deleted[0].UserOp, op2) {
+		t.Fatalf("got %d deleted entries, want op2", len(deleted))
+	}
+
+	if entries, _ := ReadEntries(db, Filter{}); len(entries) != 1 {
+		t.Fatalf("got length %d, want 1", len(entries))
+	} else if !testutils.IsOpsEqual(entries[0].UserOp, op1) {
+		t.Fatalf("ops not equal: %s", testutils.GetOpsDiff(op1, entries[0].UserOp))
+	}
+}
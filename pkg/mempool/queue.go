@@ -21,24 +21,40 @@ func (s *set) getSenderSortedSet(sender common.Address) *sortedset.SortedSet {
 	return s.senders[sender]
 }
 
+// userOpQueues is safe for concurrent use. The Bundler and the background solving Stage may read and write
+// to it at the same time.
 type userOpQueues struct {
-	setsByEntryPoint sync.Map
+	mu               sync.RWMutex
+	setsByEntryPoint map[common.Address]*set
 }
 
 func (q *userOpQueues) getEntryPointSet(entryPoint common.Address) *set {
-	val, ok := q.setsByEntryPoint.Load(entryPoint)
-	if !ok {
-		val = &set{
+	if _, ok := q.setsByEntryPoint[entryPoint]; !ok {
+		q.setsByEntryPoint[entryPoint] = &set{
 			all:     sortedset.New(),
 			senders: make(map[common.Address]*sortedset.SortedSet),
 		}
-		q.setsByEntryPoint.Store(entryPoint, val)
 	}
 
-	return val.(*set)
+	return q.setsByEntryPoint[entryPoint]
+}
+
+func (q *userOpQueues) EntryPoints() []common.Address {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	eps := []common.Address{}
+	for ep := range q.setsByEntryPoint {
+		eps = append(eps, ep)
+	}
+
+	return eps
 }
 
 func (q *userOpQueues) AddOp(entryPoint common.Address, op *userop.UserOperation) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	eps := q.getEntryPointSet(entryPoint)
 	sss := eps.getSenderSortedSet(op.Sender)
 	key := string(getUniqueKey(entryPoint, op.Sender, op.Nonce))
@@ -48,6 +64,9 @@ func (q *userOpQueues) AddOp(entryPoint common.Address, op *userop.UserOperation
 }
 
 func (q *userOpQueues) GetOps(entryPoint common.Address, sender common.Address) []*userop.UserOperation {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	eps := q.getEntryPointSet(entryPoint)
 	sss := eps.getSenderSortedSet(sender)
 	nodes := sss.GetByRankRange(-1, -sss.GetCount(), false)
@@ -60,6 +79,9 @@ func (q *userOpQueues) GetOps(entryPoint common.Address, sender common.Address)
 }
 
 func (q *userOpQueues) All(entryPoint common.Address) []*userop.UserOperation {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	eps := q.getEntryPointSet(entryPoint)
 	nodes := eps.all.GetByRankRange(1, -1, false)
 	batch := []*userop.UserOperation{}
@@ -71,6 +93,9 @@ func (q *userOpQueues) All(entryPoint common.Address) []*userop.UserOperation {
 }
 
 func (q *userOpQueues) RemoveOps(entryPoint common.Address, ops ...*userop.UserOperation) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	eps := q.getEntryPointSet(entryPoint)
 	for _, op := range ops {
 		sss := eps.getSenderSortedSet(op.Sender)
@@ -81,5 +106,5 @@ func (q *userOpQueues) RemoveOps(entryPoint common.Address, ops ...*userop.UserO
 }
 
 func newUserOpQueue() *userOpQueues {
-	return &userOpQueues{}
+	return &userOpQueues{setsByEntryPoint: make(map[common.Address]*set)}
 }
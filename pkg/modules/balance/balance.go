@@ -0,0 +1,247 @@
+// Package balance implements a module for keeping the bundler EOAs funded and pausing bundling when none of
+// them can pay for a bundle transaction.
+package balance
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+const (
+	// StatusKey is the key in the BatchHandlerCtx Data field for the bundling status set by CheckBalance.
+	StatusKey = "bundler_status"
+
+	// StatusOK is the bundling status when at least one EOA is at or above the low water mark.
+	StatusOK = "ok"
+
+	// StatusPausedLowBalance is the bundling status when every EOA is below the low water mark.
+	StatusPausedLowBalance = "paused_low_balance"
+)
+
+var (
+	// DefaultTopUpTimeout is the time to wait for a top-up to be included before another one is sent to the
+	// same EOA.
+	DefaultTopUpTimeout = 5 * time.Minute
+)
+
+// Manager checks the balance of every bundler EOA against a low and high water mark. EOAs below the low
+// water mark can be topped up to the high water mark from a beneficiary that is controlled by the bundler. If
+// all EOAs are below the low water mark, bundling is paused until at least one has been funded again.
+type Manager struct {
+	mu           sync.Mutex
+	eoas         *signer.Pool
+	eth          *ethclient.Client
+	chainID      *big.Int
+	lowWater     *big.Int
+	highWater    *big.Int
+	beneficiary  signer.Signer
+	nonces       *transaction.NonceManager
+	topUpTimeout time.Duration
+	topUps       map[common.Address]time.Time
+	lowEOAs      int
+	paused       bool
+	logger       logr.Logger
+}
+
+// New returns a Manager for the EOAs in the given Pool. The default water marks are 0, which never pauses
+// bundling or tops up an EOA.
+func New(eoas *signer.Pool, eth *ethclient.Client, chainID *big.Int) *Manager {
+	return &Manager{
+		eoas:         eoas,
+		eth:          eth,
+		chainID:      chainID,
+		lowWater:     big.NewInt(0),
+		highWater:    big.NewInt(0),
+		topUpTimeout: DefaultTopUpTimeout,
+		topUps:       make(map[common.Address]time.Time),
+		logger:       logger.NewZeroLogr().WithName("balance"),
+	}
+}
+
+// SetLowWaterMark defines the balance in wei below which an EOA is not used to send bundles and is topped
+// up.
+func (m *Manager) SetLowWaterMark(wei *big.Int) {
+	m.lowWater = wei
+}
+
+// SetHighWaterMark defines the balance in wei that an EOA is topped up to.
+func (m *Manager) SetHighWaterMark(wei *big.Int) {
+	m.highWater = wei
+}
+
+// SetTopUpSigner enables automatic top-ups of EOAs below the low water mark using the funds collected by
+// the beneficiary. The Signer must be for the beneficiary address of the bundler.
+func (m *Manager) SetTopUpSigner(beneficiary signer.Signer) {
+	m.beneficiary = beneficiary
+}
+
+// SetTopUpNonceManager defines the NonceManager used to assign nonces to top-ups. It must be set if the
+// beneficiary is also an EOA in the Pool so that top-ups do not collide with bundle transactions.
+func (m *Manager) SetTopUpNonceManager(nonces *transaction.NonceManager) {
+	m.nonces = nonces
+}
+
+// UseLogger defines the logger object used by the Manager instance based on the go-logr/logr interface.
+func (m *Manager) UseLogger(logger logr.Logger) {
+	m.logger = logger.WithName("balance")
+}
+
+// UserMeter defines an opentelemetry meter object used by the Manager to report the number of EOAs below the
+// low water mark and whether bundling is paused as of the last check.
+func (m *Manager) UserMeter(meter metric.Meter) error {
+	_, err := meter.Int64ObservableGauge(
+		"bundler_eoa_low_balance",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			io.Observe(int64(m.lowEOAs))
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"bundler_paused",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			paused := 0
+			if m.paused {
+				paused = 1
+			}
+			io.Observe(int64(paused))
+			return nil
+		}),
+	)
+	return err
+}
+
+// IsPaused returns true if bundling was paused by the last balance check.
+func (m *Manager) IsPaused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.paused
+}
+
+// Status returns StatusPausedLowBalance if bundling was paused by the last balance check or StatusOK
+// otherwise.
+func (m *Manager) Status() string {
+	if m.IsPaused() {
+		return StatusPausedLowBalance
+	}
+	return StatusOK
+}
+
+// topUp sends the funds needed to bring the EOA to the high water mark from the beneficiary. At most the
+// beneficiary balance minus the transfer fee is sent.
+func (m *Manager) topUp(ctx *modules.BatchHandlerCtx, eoa common.Address, bal *big.Int) error {
+	if sent, ok := m.topUps[eoa]; ok && time.Since(sent) < m.topUpTimeout {
+		return nil
+	}
+
+	opts := &transaction.Opts{
+		EOA:      m.beneficiary,
+		Eth:      m.eth,
+		ChainID:  m.chainID,
+		BaseFee:  ctx.BaseFee,
+		Tip:      ctx.Tip,
+		GasPrice: ctx.GasPrice,
+		Nonces:   m.nonces,
+	}
+	available, err := m.eth.BalanceAt(context.Background(), m.beneficiary.Account().Address, nil)
+	if err != nil {
+		return err
+	}
+	available.Sub(available, transaction.TransferFee(opts))
+
+	value := big.NewInt(0).Sub(m.highWater, bal)
+	if value.Cmp(available) > 0 {
+		value = available
+	}
+	if value.Sign() <= 0 {
+		m.logger.Info("beneficiary balance too low to top up EOA", "eoa_address", eoa.String())
+		return nil
+	}
+
+	txn, err := transaction.Transfer(opts, eoa, value)
+	if err != nil {
+		return err
+	}
+	m.topUps[eoa] = time.Now()
+	m.logger.Info(
+		"topped up EOA from beneficiary",
+		"eoa_address", eoa.String(),
+		"value", value.String(),
+		"txn_hash", txn.Hash().String(),
+	)
+	return nil
+}
+
+// CheckBalance returns a BatchHandlerFunc that checks the balance of every EOA before a bundle is built. EOAs
+// below the low water mark are topped up if a top-up signer is set. If no EOA is at or above the low water
+// mark, the batch is postponed by leaving it in the mempool and StatusKey is set to StatusPausedLowBalance.
+func (m *Manager) CheckBalance() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		low := 0
+		for _, s := range m.eoas.EOAs() {
+			eoa := s.Account().Address
+			bal, err := m.eth.BalanceAt(context.Background(), eoa, nil)
+			if err != nil {
+				return err
+			}
+			if bal.Cmp(m.lowWater) >= 0 {
+				delete(m.topUps, eoa)
+				continue
+			}
+
+			low++
+			if m.beneficiary == nil || m.beneficiary.Account().Address == eoa {
+				continue
+			}
+			if err := m.topUp(ctx, eoa, bal); err != nil {
+				m.logger.Error(err, "top up error", "eoa_address", eoa.String())
+			}
+		}
+		m.lowEOAs = low
+
+		paused := low == len(m.eoas.EOAs())
+		if paused != m.paused {
+			if paused {
+				m.logger.Info(
+					"bundling paused: all EOAs are below the low water mark",
+					"low_water_mark", m.lowWater.String(),
+				)
+			} else {
+				m.logger.Info("bundling resumed")
+			}
+		}
+		m.paused = paused
+
+		if paused {
+			ctx.Batch = []*userop.UserOperation{}
+			ctx.Data[StatusKey] = StatusPausedLowBalance
+		}
+		return nil
+	}
+}
@@ -0,0 +1,200 @@
+package balance
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// balanceMock returns a node that reports the given balance in wei for each lowercase address and counts the
+// transactions sent in sent.
+func balanceMock(balances map[string]int64, sent *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []any           `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			panic(err)
+		}
+
+		res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_getBalance":
+			addr, _ := req.Params[0].(string)
+			res["result"] = hexutil.EncodeBig(big.NewInt(balances[strings.ToLower(addr)]))
+		case "eth_getTransactionCount":
+			res["result"] = "0x0"
+		case "eth_sendRawTransaction":
+			*sent++
+			res["result"] = testutils.MockHash
+		default:
+			res["error"] = map[string]any{"code": -32601, "message": "method not in mocks: " + req.Method}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(err)
+		}
+	}))
+}
+
+func newBatchCtx() *modules.BatchHandlerCtx {
+	return modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{testutils.MockValidInitUserOp()},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		big.NewInt(1),
+		big.NewInt(1),
+		big.NewInt(1),
+	)
+}
+
+func newBeneficiary(t *testing.T) *signer.EOA {
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	s, err := signer.New(hexutil.Encode(crypto.FromECDSA(pk))[2:])
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	return s
+}
+
+// TestCheckBalanceAboveLowWaterMark verifies that the batch is unchanged if an EOA is funded.
+func TestCheckBalanceAboveLowWaterMark(t *testing.T) {
+	sent := 0
+	s := balanceMock(map[string]int64{
+		strings.ToLower(testutils.DummyEOA.Address.String()): 1000,
+	}, &sent)
+	defer s.Close()
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	m := New(testutils.DummyEOAPool(eth), eth, testutils.ChainID)
+	m.SetLowWaterMark(big.NewInt(100))
+	ctx := newBatchCtx()
+	if err := m.CheckBalance()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if m.IsPaused() {
+		t.Fatal("got paused, want not paused")
+	} else if len(ctx.Batch) != 1 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	} else if sent != 0 {
+		t.Fatalf("got %d sent transactions, want 0", sent)
+	}
+}
+
+// TestCheckBalancePausesBelowLowWaterMark verifies that the batch is postponed without being removed from the
+// mempool if all EOAs are below the low water mark.
+func TestCheckBalancePausesBelowLowWaterMark(t *testing.T) {
+	sent := 0
+	s := balanceMock(map[string]int64{
+		strings.ToLower(testutils.DummyEOA.Address.String()): 10,
+	}, &sent)
+	defer s.Close()
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	m := New(testutils.DummyEOAPool(eth), eth, testutils.ChainID)
+	m.SetLowWaterMark(big.NewInt(100))
+	ctx := newBatchCtx()
+	if err := m.CheckBalance()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if !m.IsPaused() {
+		t.Fatal("got not paused, want paused")
+	} else if len(ctx.Batch) != 0 {
+		t.Fatalf("got batch length %d, want 0", len(ctx.Batch))
+	} else if len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("got pending removal length %d, want 0", len(ctx.PendingRemoval))
+	} else if ctx.Data[StatusKey] != StatusPausedLowBalance {
+		t.Fatalf("got status %v, want %s", ctx.Data[StatusKey], StatusPausedLowBalance)
+	} else if m.Status() != StatusPausedLowBalance {
+		t.Fatalf("got status %s, want %s", m.Status(), StatusPausedLowBalance)
+	}
+}
+
+// TestCheckBalanceTopsUpFromBeneficiary verifies that an EOA below the low water mark is topped up once from
+// the beneficiary while the top-up is pending.
+func TestCheckBalanceTopsUpFromBeneficiary(t *testing.T) {
+	beneficiary := newBeneficiary(t)
+	sent := 0
+	s := balanceMock(map[string]int64{
+		strings.ToLower(testutils.DummyEOA.Address.String()): 10,
+		strings.ToLower(beneficiary.Address.String()):        1000000,
+	}, &sent)
+	defer s.Close()
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	m := New(testutils.DummyEOAPool(eth), eth, testutils.ChainID)
+	m.SetLowWaterMark(big.NewInt(100))
+	m.SetHighWaterMark(big.NewInt(1000))
+	m.SetTopUpSigner(beneficiary)
+	for i := 0; i < 2; i++ {
+		if err := m.CheckBalance()(newBatchCtx()); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	if sent != 1 {
+		t.Fatalf("got %d sent transactions, want 1", sent)
+	}
+}
+
+// TestCheckBalanceTopUpUsesNonceManager verifies that a top-up is assigned its nonce by the NonceManager of the
+// beneficiary so that it does not collide with an in-flight bundle transaction.
+func TestCheckBalanceTopUpUsesNonceManager(t *testing.T) {
+	beneficiary := newBeneficiary(t)
+	sent := 0
+	s := balanceMock(map[string]int64{
+		strings.ToLower(testutils.DummyEOA.Address.String()): 10,
+		strings.ToLower(beneficiary.Address.String()):        1000000,
+	}, &sent)
+	defer s.Close()
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	nonces := transaction.NewNonceManager(eth, beneficiary.Address)
+	nonces.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 0}))
+	m := New(testutils.DummyEOAPool(eth), eth, testutils.ChainID)
+	m.SetLowWaterMark(big.NewInt(100))
+	m.SetHighWaterMark(big.NewInt(1000))
+	m.SetTopUpSigner(beneficiary)
+	m.SetTopUpNonceManager(nonces)
+	if err := m.CheckBalance()(newBatchCtx()); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	inFlight := nonces.InFlight()
+	if sent != 1 {
+		t.Fatalf("got %d sent transactions, want 1", sent)
+	} else if len(inFlight) != 2 {
+		t.Fatalf("got %d in-flight, want 2", len(inFlight))
+	} else if inFlight[1].Nonce() != 1 {
+		t.Fatalf("got top-up nonce %d, want 1", inFlight[1].Nonce())
+	}
+}
@@ -4,6 +4,7 @@ package builder
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"math/big"
@@ -11,41 +12,75 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/metachris/flashbotsrpc"
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
 	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
 )
 
 // BuilderClient provides a connection to a block builder API to enable UserOperations to be sent through the
 // mev-boost process.
 type BuilderClient struct {
-	eoa               *signer.EOA
+	eoas              *signer.Pool
 	eth               *ethclient.Client
 	rpc               *flashbotsrpc.BuilderBroadcastRPC
 	beneficiary       common.Address
 	blocksInTheFuture int
 	waitTimeout       time.Duration
+	nonces            map[common.Address]*transaction.NonceManager
+	authKey           *ecdsa.PrivateKey
+	randomAuthKey     *ecdsa.PrivateKey
 }
 
 // New returns an instance of a BuilderClient with modules to send UserOperation bundles via the mev-boost
-// process.
+// process. Each bundle is signed by an idle EOA leased from the pool.
 func New(
-	eoa *signer.EOA,
+	eoas *signer.Pool,
 	eth *ethclient.Client,
 	fb *flashbotsrpc.BuilderBroadcastRPC,
 	beneficiary common.Address,
 	blocksInTheFuture int,
 ) *BuilderClient {
+	randomAuthKey, err := crypto.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	nonces := make(map[common.Address]*transaction.NonceManager)
+	for _, eoa := range eoas.EOAs() {
+		nonces[eoa.Account().Address] = transaction.NewNonceManager(eth, eoa.Account().Address)
+	}
+
 	return &BuilderClient{
-		eoa:               eoa,
+		eoas:              eoas,
 		eth:               eth,
 		rpc:               fb,
 		beneficiary:       beneficiary,
 		blocksInTheFuture: blocksInTheFuture,
 		waitTimeout:       DefaultWaitTimeout,
+		nonces:            nonces,
+		authKey:           nil,
+		randomAuthKey:     randomAuthKey,
+	}
+}
+
+// SetAuthKey sets the private key used to sign the X-Flashbots-Signature header of requests to the block
+// builders. This key only identifies the searcher and does not need to hold any funds. If not set, the key
+// of the EOA signing the bundle is used if it is held in memory. Otherwise, a random key is generated.
+func (b *BuilderClient) SetAuthKey(key *ecdsa.PrivateKey) {
+	b.authKey = key
+}
+
+func (b *BuilderClient) getAuthKey(eoa signer.Signer) *ecdsa.PrivateKey {
+	if b.authKey != nil {
+		return b.authKey
 	}
+	if local, ok := eoa.(*signer.EOA); ok {
+		return local.PrivateKey
+	}
+	return b.randomAuthKey
 }
 
 // SetWaitTimeout sets the total time to wait for a transaction to be included. When a timeout is reached, the
@@ -57,27 +92,44 @@ func (b *BuilderClient) SetWaitTimeout(timeout time.Duration) {
 	b.waitTimeout = timeout
 }
 
+// GetNonceManager returns the NonceManager used for bundle transactions signed by the given EOA or nil if the
+// EOA is not in the Pool. Any other transaction sent by the same EOA must use it to avoid nonce collisions.
+func (b *BuilderClient) GetNonceManager(eoa common.Address) *transaction.NonceManager {
+	return b.nonces[eoa]
+}
+
 // SendUserOperation returns a BatchHandler that is used by the Bundler to send batches to a block builder
 // that supports eth_sendBundle.
 func (b *BuilderClient) SendUserOperation() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
+		eoa, release, err := b.eoas.Lease()
+		if err != nil {
+			return err
+		}
+		defer release()
+		ctx.Data["builder_eoa"] = eoa.Account().Address.String()
+
 		opts := transaction.Opts{
-			EOA:         b.eoa,
-			Eth:         b.eth,
-			ChainID:     ctx.ChainID,
-			EntryPoint:  ctx.EntryPoint,
-			Batch:       ctx.Batch,
-			Beneficiary: b.beneficiary,
-			BaseFee:     ctx.BaseFee,
-			Tip:         ctx.Tip,
-			GasPrice:    ctx.GasPrice,
-			GasLimit:    0,
-			NoSend:      true,
-			WaitTimeout: b.waitTimeout,
+			EOA:                  eoa,
+			Eth:                  b.eth,
+			ChainID:              ctx.ChainID,
+			EntryPoint:           ctx.EntryPoint,
+			Batch:                ctx.Batch,
+			Beneficiary:          b.beneficiary,
+			Aggregators:          checks.GetAggregators(ctx),
+			AggregatedSignatures: checks.GetAggregatedSignatures(ctx),
+			BaseFee:              ctx.BaseFee,
+			Tip:                  ctx.Tip,
+			GasPrice:             ctx.GasPrice,
+			GasLimit:             0,
+			NoSend:               true,
+			WaitTimeout:          b.waitTimeout,
+			Nonces:               b.nonces[eoa.Account().Address],
 		}
 		// Estimate gas for handleOps() and drop all userOps that cause unexpected reverts.
 		estRev := []string{}
 		for len(ctx.Batch) > 0 {
+			opts.Batch = ctx.Batch
 			est, revert, err := transaction.EstimateHandleOpsGas(&opts)
 
 			if err != nil {
@@ -127,7 +179,7 @@ func (b *BuilderClient) SendUserOperation() modules.BatchHandlerFunc {
 				BlockNumber: hexutil.EncodeBig(fbn),
 			}
 
-			results := b.rpc.BroadcastBundle(b.eoa.PrivateKey, sendBundleArgs)
+			results := b.rpc.BroadcastBundle(b.getAuthKey(eoa), sendBundleArgs)
 			for _, result := range results {
 				if result.Err != nil {
 					errs = errors.Join(errs, result.Err)
@@ -142,6 +194,11 @@ func (b *BuilderClient) SendUserOperation() modules.BatchHandlerFunc {
 			return fmt.Errorf("%w: \n\n%w", ErrFlashbotsBroadcastBundle, errs)
 		}
 
+		// The bundle is only valid up to the last targeted block, so the nonce is free again once the wait is
+		// over regardless of whether the transaction was included.
+		opts.Nonces.Track(txn)
+		defer opts.Nonces.Done(txn.Nonce())
+
 		// Wait for transaction to be included on-chain.
 		if _, err := transaction.Wait(txn, opts.Eth, opts.WaitTimeout); err != nil {
 			return err
@@ -17,6 +17,7 @@ import (
 func TestSendUserOperationWithAllUpstreamErrors(t *testing.T) {
 	n := testutils.RpcMock(testutils.MethodMocks{
 		"eth_blockNumber":           "0x1",
+		"eth_getBalance":            "0x1",
 		"eth_gasPrice":              "0x1",
 		"eth_getTransactionCount":   "0x1",
 		"eth_estimateGas":           "0x1",
@@ -29,7 +30,7 @@ func TestSendUserOperationWithAllUpstreamErrors(t *testing.T) {
 	bb1 := testutils.BadBuilderRpcMock()
 	bb2 := testutils.BadBuilderRpcMock()
 	fb := flashbotsrpc.NewBuilderBroadcastRPC([]string{bb1.URL, bb2.URL})
-	fn := New(testutils.DummyEOA, eth, fb, testutils.DummyEOA.Address, 1).SendUserOperation()
+	fn := New(testutils.DummyEOAPool(eth), eth, fb, testutils.DummyEOA.Address, 1).SendUserOperation()
 
 	if err := fn(
 		modules.NewBatchHandlerContext(
@@ -48,6 +49,7 @@ func TestSendUserOperationWithAllUpstreamErrors(t *testing.T) {
 func TestSendUserOperationWithPartialUpstreamErrors(t *testing.T) {
 	n := testutils.RpcMock(testutils.MethodMocks{
 		"eth_blockNumber":           "0x1",
+		"eth_getBalance":            "0x1",
 		"eth_gasPrice":              "0x1",
 		"eth_getTransactionCount":   "0x1",
 		"eth_estimateGas":           "0x1",
@@ -64,7 +66,7 @@ func TestSendUserOperationWithPartialUpstreamErrors(t *testing.T) {
 	})
 	bb2 := testutils.BadBuilderRpcMock()
 	fb := flashbotsrpc.NewBuilderBroadcastRPC([]string{bb1.URL, bb2.URL})
-	fn := New(testutils.DummyEOA, eth, fb, testutils.DummyEOA.Address, 1).SendUserOperation()
+	fn := New(testutils.DummyEOAPool(eth), eth, fb, testutils.DummyEOA.Address, 1).SendUserOperation()
 
 	if err := fn(
 		modules.NewBatchHandlerContext(
@@ -83,6 +85,7 @@ func TestSendUserOperationWithPartialUpstreamErrors(t *testing.T) {
 func TestSendUserOperationWithNoUpstreamErrors(t *testing.T) {
 	n := testutils.RpcMock(testutils.MethodMocks{
 		"eth_blockNumber":           "0x1",
+		"eth_getBalance":            "0x1",
 		"eth_gasPrice":              "0x1",
 		"eth_getTransactionCount":   "0x1",
 		"eth_estimateGas":           "0x1",
@@ -103,7 +106,7 @@ func TestSendUserOperationWithNoUpstreamErrors(t *testing.T) {
 		},
 	})
 	fb := flashbotsrpc.NewBuilderBroadcastRPC([]string{bb1.URL, bb2.URL})
-	fn := New(testutils.DummyEOA, eth, fb, testutils.DummyEOA.Address, 1).SendUserOperation()
+	fn := New(testutils.DummyEOAPool(eth), eth, fb, testutils.DummyEOA.Address, 1).SendUserOperation()
 
 	if err := fn(
 		modules.NewBatchHandlerContext(
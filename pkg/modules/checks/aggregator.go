@@ -0,0 +1,98 @@
+package checks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/aggregator"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// AggregatorsKey is the key in the BatchHandlerCtx Data field for the aggregator of each aggregated userOp in
+// the batch, mapped by userOpHash. Modules that send the batch use it to call handleAggregatedOps.
+const AggregatorsKey = "aggregators"
+
+// AggregatedSignaturesKey is the key in the BatchHandlerCtx Data field for the validated signature of each
+// aggregator in the batch. Modules that send the batch use it to avoid aggregating the signatures again.
+const AggregatedSignaturesKey = "aggregated_signatures"
+
+// GetAggregators returns the aggregators set by the AggregateSignatures module or nil if no userOp in the
+// batch is aggregated.
+func GetAggregators(ctx *modules.BatchHandlerCtx) map[common.Hash]common.Address {
+	aggs, _ := ctx.Data[AggregatorsKey].(map[common.Hash]common.Address)
+	return aggs
+}
+
+// GetAggregatedSignatures returns the signatures set by the AggregateSignatures module or nil if no userOp in
+// the batch is aggregated.
+func GetAggregatedSignatures(ctx *modules.BatchHandlerCtx) map[common.Address]*transaction.AggregatedSignature {
+	sigs, _ := ctx.Data[AggregatedSignaturesKey].(map[common.Address]*transaction.AggregatedSignature)
+	return sigs
+}
+
+// AggregateSignatures returns a BatchHandler that groups the batch by the aggregator found during simulation.
+// The signatures of each group are aggregated and validated with the aggregator. All userOps of an
+// aggregator that fails either call are dropped. The batch is reordered with UserOps without an aggregator
+// first, followed by each aggregator in the order it first appears. This should be executed after any module
+// that sorts the batch. The validated signature of each aggregator is reported under AggregatedSignaturesKey.
+func (s *Standalone) AggregateSignatures() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		aggs := make(map[common.Hash]common.Address)
+		for _, op := range ctx.Batch {
+			hash := op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+			agg, err := getSavedAggregator(s.db, hash)
+			if err != nil {
+				return err
+			}
+			if agg != (common.Address{}) {
+				aggs[hash] = agg
+			}
+		}
+		if len(aggs) == 0 {
+			return nil
+		}
+
+		ordered, groups, groupOps := transaction.GroupByAggregator(ctx.Batch, aggs, ctx.EntryPoint, ctx.ChainID)
+		ctx.Batch = ordered
+
+		sigs := make(map[common.Address]*transaction.AggregatedSignature)
+		invalid := make(map[*userop.UserOperation]bool)
+		for i, agg := range groups {
+			if agg == (common.Address{}) {
+				continue
+			}
+
+			sig, err := aggregator.AggregateSignatures(s.eth, agg, groupOps[i])
+			if err == nil {
+				err = aggregator.ValidateSignatures(s.eth, agg, groupOps[i], sig)
+			}
+			if err != nil {
+				for _, op := range groupOps[i] {
+					invalid[op] = true
+				}
+				continue
+			}
+
+			hashes := []common.Hash{}
+			for _, op := range groupOps[i] {
+				hashes = append(hashes, op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID))
+			}
+			sigs[agg] = &transaction.AggregatedSignature{UserOpHashes: hashes, Signature: sig}
+		}
+
+		end := len(ctx.Batch) - 1
+		for i := end; i >= 0; i-- {
+			op := ctx.Batch[i]
+			if invalid[op] {
+				delete(aggs, op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID))
+				ctx.MarkOpIndexForRemoval(i)
+			}
+		}
+
+		if len(aggs) > 0 {
+			ctx.Data[AggregatorsKey] = aggs
+			ctx.Data[AggregatedSignaturesKey] = sigs
+		}
+		return nil
+	}
+}
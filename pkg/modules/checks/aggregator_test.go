@@ -0,0 +1,45 @@
+package checks
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/reverts"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+)
+
+func newAggregatedValidationResult() *reverts.ValidationResultRevert {
+	return &reverts.ValidationResultRevert{
+		AggregatorInfo: &reverts.AggregatorStakeInfo{
+			Aggregator: testutils.ValidAddress3,
+			StakeInfo:  &reverts.StakeInfo{Stake: big.NewInt(0), UnstakeDelaySec: big.NewInt(0)},
+		},
+	}
+}
+
+// TestValidateAggregatorWithoutAggregator calls checks.validateAggregator for a userOp that does not use an
+// aggregator. Expects nil.
+func TestValidateAggregatorWithoutAggregator(t *testing.T) {
+	if err := validateAggregator(&reverts.ValidationResultRevert{}, testutils.MockGetNotStake); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestValidateAggregatorStaked calls checks.validateAggregator with a staked aggregator. Expects nil.
+func TestValidateAggregatorStaked(t *testing.T) {
+	if err := validateAggregator(newAggregatedValidationResult(), testutils.MockGetStake); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestValidateAggregatorNotStaked calls checks.validateAggregator with an unstaked aggregator. Expects an
+// INVALID_AGGREGATOR error.
+func TestValidateAggregatorNotStaked(t *testing.T) {
+	err := validateAggregator(newAggregatedValidationResult(), testutils.MockGetNotStake)
+	if err == nil {
+		t.Fatal("got nil, want err")
+	} else if rpcErr, ok := err.(*errors.RPCError); !ok || rpcErr.Code() != errors.INVALID_AGGREGATOR {
+		t.Fatalf("got %v, want INVALID_AGGREGATOR", err)
+	}
+}
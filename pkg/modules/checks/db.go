@@ -3,22 +3,23 @@ package checks
 import (
 	"encoding/json"
 
-	"github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
 )
 
 var (
 	keyPrefix        = dbutils.JoinValues("checks")
 	codeHashesPrefix = dbutils.JoinValues(keyPrefix, "codeHashes")
+	aggregatorPrefix = dbutils.JoinValues(keyPrefix, "aggregator")
 )
 
 func getCodeHashesKey(userOpHash common.Hash) []byte {
 	return []byte(dbutils.JoinValues(codeHashesPrefix, userOpHash.String()))
 }
 
-func saveCodeHashes(db *badger.DB, userOpHash common.Hash, codeHashes []codeHash) error {
-	return db.Update(func(txn *badger.Txn) error {
+func saveCodeHashes(db storage.Store, userOpHash common.Hash, codeHashes []codeHash) error {
+	return db.Update(func(txn storage.Txn) error {
 		data, err := json.Marshal(codeHashes)
 		if err != nil {
 			return err
@@ -28,24 +29,22 @@ func saveCodeHashes(db *badger.DB, userOpHash common.Hash, codeHashes []codeHash
 	})
 }
 
-func getSavedCodeHashes(db *badger.DB, userOpHash common.Hash) ([]codeHash, error) {
+func getSavedCodeHashes(db storage.Store, userOpHash common.Hash) ([]codeHash, error) {
 	var ch []codeHash
-	err := db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(getCodeHashesKey(userOpHash))
+	err := db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(getCodeHashesKey(userOpHash))
 		if err != nil {
 			return err
 		}
 
-		return item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &ch)
-		})
+		return json.Unmarshal(val, &ch)
 	})
 
 	return ch, err
 }
 
-func removeSavedCodeHashes(db *badger.DB, userOpHashes ...common.Hash) error {
-	return db.Update(func(txn *badger.Txn) error {
+func removeSavedCodeHashes(db storage.Store, userOpHashes ...common.Hash) error {
+	return db.Update(func(txn storage.Txn) error {
 		for _, userOpHash := range userOpHashes {
 			if err := txn.Delete(getCodeHashesKey(userOpHash)); err != nil {
 				return err
@@ -54,3 +53,43 @@ func removeSavedCodeHashes(db *badger.DB, userOpHashes ...common.Hash) error {
 		return nil
 	})
 }
+
+func getAggregatorKey(userOpHash common.Hash) []byte {
+	return []byte(dbutils.JoinValues(aggregatorPrefix, userOpHash.String()))
+}
+
+func saveAggregator(db storage.Store, userOpHash common.Hash, aggregator common.Address) error {
+	return db.Update(func(txn storage.Txn) error {
+		return txn.Set(getAggregatorKey(userOpHash), aggregator.Bytes())
+	})
+}
+
+// getSavedAggregator returns the aggregator saved for the userOp during simulation or the zero address if it
+// does not use one.
+func getSavedAggregator(db storage.Store, userOpHash common.Hash) (common.Address, error) {
+	var agg common.Address
+	err := db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(getAggregatorKey(userOpHash))
+		if err == storage.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		agg = common.BytesToAddress(val)
+		return nil
+	})
+
+	return agg, err
+}
+
+func removeSavedAggregators(db storage.Store, userOpHashes ...common.Hash) error {
+	return db.Update(func(txn storage.Txn) error {
+		for _, userOpHash := range userOpHashes {
+			if err := txn.Delete(getAggregatorKey(userOpHash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
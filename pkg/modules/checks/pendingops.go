@@ -7,30 +7,51 @@ import (
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
-const minPriceBump = 10
-
-// calcNewThresholds returns new threshold values where newFee = oldFee  * (100 + minPriceBump) / 100.
-func calcNewThresholds(cap *big.Int, tip *big.Int) (newCap *big.Int, newTip *big.Int) {
-	a := big.NewInt(100 + minPriceBump)
-	aFeeCap := big.NewInt(0).Mul(a, cap)
-	aTip := big.NewInt(0).Mul(a, tip)
+// DefaultReplacementPriceBump is the minimum percentage that both maxFeePerGas and maxPriorityFeePerGas must
+// be increased by for a UserOperation to replace a pending one with the same sender and nonce.
+const DefaultReplacementPriceBump int64 = 10
 
+// calcNewThresholds returns new threshold values where newFee = oldFee * (100 + priceBump) / 100 rounded up.
+func calcNewThresholds(cap *big.Int, tip *big.Int, priceBump int64) (newCap *big.Int, newTip *big.Int) {
+	a := big.NewInt(100 + priceBump)
 	b := big.NewInt(100)
-	newCap = aFeeCap.Div(aFeeCap, b)
-	newTip = aTip.Div(aTip, b)
+	ceilDiv := func(x *big.Int) *big.Int {
+		n := big.NewInt(0).Mul(a, x)
+		n.Add(n, big.NewInt(99))
+		return n.Div(n, b)
+	}
+
+	return ceilDiv(cap), ceilDiv(tip)
+}
 
-	return newCap, newTip
+// ValidateReplacement checks that op increases both maxFeePerGas and maxPriorityFeePerGas of the pending
+// oldOp by at least priceBump percent.
+func ValidateReplacement(op *userop.UserOperation, oldOp *userop.UserOperation, priceBump int64) error {
+	newMf, newMpf := calcNewThresholds(oldOp.MaxFeePerGas, oldOp.MaxPriorityFeePerGas, priceBump)
+	if op.MaxFeePerGas.Cmp(newMf) < 0 || op.MaxPriorityFeePerGas.Cmp(newMpf) < 0 {
+		return fmt.Errorf(
+			"pending ops: replacement underpriced: maxFeePerGas and maxPriorityFeePerGas must increase by >= %d%% "+
+				"(want >= %s and >= %s, got %s and %s)",
+			priceBump,
+			newMf,
+			newMpf,
+			op.MaxFeePerGas,
+			op.MaxPriorityFeePerGas,
+		)
+	}
+	return nil
 }
 
 // ValidatePendingOps checks the pending UserOperations by the same sender and only passes if:
 //
 //  1. Sender doesn't have another UserOperation already present in the pool.
-//  2. It replaces an existing UserOperation with same nonce and higher fee.
+//  2. It replaces an existing UserOperation with same nonce and fees increased by at least priceBump percent.
 //  3. Sender is staked and is allowed uncapped UserOperations in the pool.
 func ValidatePendingOps(
 	op *userop.UserOperation,
 	penOps []*userop.UserOperation,
 	maxOpsForUnstakedSender int,
+	priceBump int64,
 	gs GetStakeFunc,
 ) error {
 	dep, err := gs(op.Sender)
@@ -47,14 +68,7 @@ func ValidatePendingOps(
 		}
 
 		if oldOp != nil {
-			newMf, newMpf := calcNewThresholds(oldOp.MaxFeePerGas, oldOp.MaxPriorityFeePerGas)
-
-			if op.MaxFeePerGas.Cmp(newMf) < 0 || op.MaxPriorityFeePerGas.Cmp(newMpf) < 0 {
-				return fmt.Errorf(
-					"pending ops: replacement op must increase maxFeePerGas and MaxPriorityFeePerGas by >= %d%%",
-					minPriceBump,
-				)
-			}
+			return ValidateReplacement(op, oldOp, priceBump)
 		} else if !dep.Staked && len(penOps) >= maxOpsForUnstakedSender {
 			return fmt.Errorf(
 				"pending ops: sender must be staked to have more than %d ops in the mempool",
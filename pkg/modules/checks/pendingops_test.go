@@ -2,6 +2,7 @@ package checks
 
 import (
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -17,6 +18,7 @@ func TestNoPendingOps(t *testing.T) {
 		op,
 		penOps,
 		testutils.MaxOpsForUnstakedSender,
+		DefaultReplacementPriceBump,
 		testutils.MockGetNotStakeZeroDeposit,
 	)
 
@@ -36,6 +38,7 @@ func TestPendingOpsNotStaked(t *testing.T) {
 		op,
 		penOps,
 		testutils.MaxOpsForUnstakedSender,
+		DefaultReplacementPriceBump,
 		testutils.MockGetNotStakeZeroDeposit,
 	)
 
@@ -55,6 +58,7 @@ func TestPendingOpsStaked(t *testing.T) {
 		op,
 		penOps,
 		testutils.MaxOpsForUnstakedSender,
+		DefaultReplacementPriceBump,
 		testutils.MockGetStakeZeroDeposit,
 	)
 
@@ -62,3 +66,86 @@ func TestPendingOpsStaked(t *testing.T) {
 		t.Fatalf("got err %v, want nil", err)
 	}
 }
+
+// TestPendingOpsReplacementUnderpriced calls checks.ValidatePendingOps with a replacement UserOperation that
+// does not increase its fees. Expect a replacement underpriced error.
+func TestPendingOpsReplacementUnderpriced(t *testing.T) {
+	penOp := testutils.MockValidInitUserOp()
+	op := testutils.MockValidInitUserOp()
+	err := ValidatePendingOps(
+		op,
+		[]*userop.UserOperation{penOp},
+		testutils.MaxOpsForUnstakedSender,
+		DefaultReplacementPriceBump,
+		testutils.MockGetNotStakeZeroDeposit,
+	)
+
+	if err == nil {
+		t.Fatal("got nil, want err")
+	} else if !strings.Contains(err.Error(), "replacement underpriced") {
+		t.Fatalf("got %v, want replacement underpriced error", err)
+	}
+}
+
+// TestPendingOpsReplacementOnlyFeeCapBumped calls checks.ValidatePendingOps with a replacement UserOperation
+// that only increases maxFeePerGas. Expect error.
+func TestPendingOpsReplacementOnlyFeeCapBumped(t *testing.T) {
+	penOp := testutils.MockValidInitUserOp()
+	op := testutils.MockValidInitUserOp()
+	op.MaxFeePerGas = big.NewInt(0).Mul(penOp.MaxFeePerGas, common.Big2)
+	err := ValidatePendingOps(
+		op,
+		[]*userop.UserOperation{penOp},
+		testutils.MaxOpsForUnstakedSender,
+		DefaultReplacementPriceBump,
+		testutils.MockGetNotStakeZeroDeposit,
+	)
+
+	if err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
+
+// TestPendingOpsReplacementBumped calls checks.ValidatePendingOps with a replacement UserOperation that
+// increases both fees by the price bump. Expect nil.
+func TestPendingOpsReplacementBumped(t *testing.T) {
+	penOp := testutils.MockValidInitUserOp()
+	penOp.MaxFeePerGas = big.NewInt(100)
+	penOp.MaxPriorityFeePerGas = big.NewInt(10)
+	op := testutils.MockValidInitUserOp()
+	op.MaxFeePerGas = big.NewInt(125)
+	op.MaxPriorityFeePerGas = big.NewInt(13)
+	err := ValidatePendingOps(
+		op,
+		[]*userop.UserOperation{penOp},
+		testutils.MaxOpsForUnstakedSender,
+		25,
+		testutils.MockGetNotStakeZeroDeposit,
+	)
+
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+}
+
+// TestPendingOpsReplacementRoundsUp calls checks.ValidatePendingOps with a replacement UserOperation for a
+// pending UserOperation with fees too low for the price bump to round to a higher value. Expect error.
+func TestPendingOpsReplacementRoundsUp(t *testing.T) {
+	penOp := testutils.MockValidInitUserOp()
+	penOp.MaxFeePerGas = big.NewInt(1)
+	penOp.MaxPriorityFeePerGas = big.NewInt(1)
+	op := testutils.MockValidInitUserOp()
+	op.MaxFeePerGas = big.NewInt(1)
+	op.MaxPriorityFeePerGas = big.NewInt(1)
+	err := ValidatePendingOps(
+		op,
+		[]*userop.UserOperation{penOp},
+		testutils.MaxOpsForUnstakedSender,
+		DefaultReplacementPriceBump,
+		testutils.MockGetNotStakeZeroDeposit,
+	)
+
+	if err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
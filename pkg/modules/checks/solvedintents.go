@@ -0,0 +1,58 @@
+package checks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/simulation"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// SolvedIntentsSimulationFailuresKey is the key in the BatchHandlerCtx Data field for the list of solved
+// Intent userOps that were dropped from the batch due to a failed re-simulation.
+const SolvedIntentsSimulationFailuresKey = "solved_intents_simulation_failures"
+
+// SimulationFailure is a structured reason for dropping a solved Intent userOp from the batch.
+type SimulationFailure struct {
+	UserOpHash common.Hash    `json:"userOpHash"`
+	Sender     common.Address `json:"sender"`
+	Code       int            `json:"code"`
+	Reason     string         `json:"reason"`
+}
+
+func newSimulationFailure(hash common.Hash, op *userop.UserOperation, err error) SimulationFailure {
+	code := errors.REJECTED_BY_EP_OR_ACCOUNT
+	if rpcErr, ok := err.(*errors.RPCError); ok {
+		code = rpcErr.Code()
+	}
+	return SimulationFailure{UserOpHash: hash, Sender: op.Sender, Code: code, Reason: err.Error()}
+}
+
+// AddSimulationFailure records a solved Intent userOp that was dropped from the batch by a later module (e.g.
+// a revert during gas estimation of handleOps) under SolvedIntentsSimulationFailuresKey.
+func AddSimulationFailure(ctx *modules.BatchHandlerCtx, op *userop.UserOperation, err error) {
+	failures, _ := ctx.Data[SolvedIntentsSimulationFailuresKey].([]SimulationFailure)
+	hash := op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+	ctx.Data[SolvedIntentsSimulationFailuresKey] = append(failures, newSimulationFailure(hash, op, err))
+}
+
+// getEntityStakes returns the EntryPoint stake info for all the entities of a userOp.
+func getEntityStakes(ep *entrypoint.Entrypoint, op *userop.UserOperation) (simulation.EntityStakes, error) {
+	stakes := simulation.EntityStakes{}
+	for _, addr := range []common.Address{op.GetFactory(), op.Sender, op.GetPaymaster()} {
+		if addr == common.HexToAddress("0x") {
+			continue
+		}
+		if _, ok := stakes[addr]; ok {
+			continue
+		}
+
+		dep, err := ep.GetDepositInfo(nil, addr)
+		if err != nil {
+			return nil, err
+		}
+		stakes[addr] = &dep
+	}
+	return stakes, nil
+}
@@ -0,0 +1,58 @@
+package checks
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// TestSimulateSolvedIntentsDropsFailures verifies that a solved Intent userOp that fails simulation is
+// dropped from the batch with a structured reason while conventional userOps are left untouched.
+func TestSimulateSolvedIntentsDropsFailures(t *testing.T) {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_call": "0x" + strings.Repeat("0", 64*5),
+	})
+	defer n.Close()
+	r, _ := rpc.Dial(n.URL)
+
+	conventional := testutils.MockValidInitUserOp()
+	solved := testutils.MockValidInitUserOp()
+	solved.Signature = append(solved.Signature, []byte(testutils.MockIntentJSON)...)
+	if !solved.IsSolvedIntent() {
+		t.Fatal("got unsolved op, want solved intent")
+	}
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{conventional, solved},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		big.NewInt(1),
+		big.NewInt(1),
+		big.NewInt(1),
+	)
+	db := testutils.StoreMock()
+	defer db.Close()
+	s := New(db, r, gas.NewDefaultOverhead(), nil, nil, nil, 0)
+	if err := s.SimulateSolvedIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 || ctx.Batch[0] != conventional {
+		t.Fatalf("got batch length %d, want conventional op only", len(ctx.Batch))
+	} else if len(ctx.PendingRemoval) != 1 || ctx.PendingRemoval[0] != solved {
+		t.Fatalf("got pending removal length %d, want solved op only", len(ctx.PendingRemoval))
+	}
+
+	failures, ok := ctx.Data[SolvedIntentsSimulationFailuresKey].([]SimulationFailure)
+	if !ok || len(failures) != 1 {
+		t.Fatalf("got failures %v, want length 1", ctx.Data[SolvedIntentsSimulationFailuresKey])
+	} else if failures[0].UserOpHash != solved.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID) {
+		t.Fatalf("got hash %s, want solved op hash", failures[0].UserOpHash)
+	} else if failures[0].Reason == "" {
+		t.Fatal("got empty reason, want failure reason")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -14,11 +13,13 @@ import (
 
 	"github.com/stackup-wallet/stackup-bundler/pkg/altmempools"
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/reverts"
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/simulation"
 	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/gas"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules/gasprice"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
@@ -26,7 +27,7 @@ import (
 // intended for bundlers that are independent of an Ethereum node and hence relies on a given ethClient to
 // query blockchain state.
 type Standalone struct {
-	db                      *badger.DB
+	db                      storage.Store
 	rpc                     *rpc.Client
 	eth                     *ethclient.Client
 	ov                      *gas.Overhead
@@ -34,12 +35,13 @@ type Standalone struct {
 	maxVerificationGas      *big.Int
 	maxBatchGasLimit        *big.Int
 	maxOpsForUnstakedSender int
+	replacementPriceBump    int64
 }
 
 // New returns a Standalone instance with methods that can be used in Client and Bundler modules to perform
 // standard checks as specified in EIP-4337.
 func New(
-	db *badger.DB,
+	db storage.Store,
 	rpc *rpc.Client,
 	ov *gas.Overhead,
 	alt *altmempools.Directory,
@@ -48,7 +50,23 @@ func New(
 	maxOpsForUnstakedSender int,
 ) *Standalone {
 	eth := ethclient.NewClient(rpc)
-	return &Standalone{db, rpc, eth, ov, alt, maxVerificationGas, maxBatchGasLimit, maxOpsForUnstakedSender}
+	return &Standalone{
+		db,
+		rpc,
+		eth,
+		ov,
+		alt,
+		maxVerificationGas,
+		maxBatchGasLimit,
+		maxOpsForUnstakedSender,
+		DefaultReplacementPriceBump,
+	}
+}
+
+// SetReplacementPriceBump defines the minimum percentage that both maxFeePerGas and maxPriorityFeePerGas must
+// be increased by to replace a pending UserOperation with the same sender and nonce. The default value is 10.
+func (s *Standalone) SetReplacementPriceBump(pct int64) {
+	s.replacementPriceBump = pct
 }
 
 // ValidateOpValues returns a UserOpHandler that runs through some first line sanity checks for new UserOps
@@ -78,7 +96,9 @@ func (s *Standalone) ValidateOpValues() modules.UserOpHandlerFunc {
 			g.Go(func() error { return ValidateFeePerGas(ctx.UserOp, gbf) })
 		}
 
-		g.Go(func() error { return ValidatePendingOps(ctx.UserOp, penOps, s.maxOpsForUnstakedSender, gs) })
+		g.Go(func() error {
+			return ValidatePendingOps(ctx.UserOp, penOps, s.maxOpsForUnstakedSender, s.replacementPriceBump, gs)
+		})
 		g.Go(func() error { return ValidateGasAvailable(ctx.UserOp, s.maxBatchGasLimit) })
 
 		if err := g.Wait(); err != nil {
@@ -96,27 +116,27 @@ func (s *Standalone) SimulateOp() modules.UserOpHandlerFunc {
 			return nil
 		}
 		gc := getCodeWithEthClient(s.eth)
+		gs, err := getStakeWithEthClient(ctx, s.eth)
+		if err != nil {
+			return err
+		}
+
 		g := new(errgroup.Group)
 		g.Go(func() error {
-			sim, err := simulation.SimulateValidation(s.rpc, ctx.EntryPoint, ctx.UserOp)
-
+			sim, err := simulateValidation(s.rpc, ctx.EntryPoint, ctx.UserOp)
 			if err != nil {
-				return errors.NewRPCError(errors.REJECTED_BY_EP_OR_ACCOUNT, err.Error(), err.Error())
-			}
-			if sim.ReturnInfo.SigFailed {
-				return errors.NewRPCError(
-					errors.INVALID_SIGNATURE,
-					"Invalid UserOp signature or paymaster signature",
-					nil,
-				)
-			}
-			if sim.ReturnInfo.ValidUntil.Cmp(common.Big0) != 0 &&
-				time.Now().Unix() >= sim.ReturnInfo.ValidUntil.Int64()-30 {
-				return errors.NewRPCError(
-					errors.SHORT_DEADLINE,
-					"expires too soon",
-					nil,
-				)
+				return err
+			}
+			if err := validateAggregator(sim, gs); err != nil {
+				return err
+			}
+			if sim.ReturnInfo.ValidUntil.Cmp(common.Big0) != 0 {
+				ctx.SetValidUntil(time.Unix(sim.ReturnInfo.ValidUntil.Int64(), 0))
+			}
+			if sim.AggregatorInfo != nil && sim.AggregatorInfo.Aggregator != (common.Address{}) {
+				ctx.SetAggregator(sim.AggregatorInfo.Aggregator)
+				hash := ctx.UserOp.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+				return saveAggregator(s.db, hash, sim.AggregatorInfo.Aggregator)
 			}
 			return nil
 		})
@@ -148,6 +168,34 @@ func (s *Standalone) SimulateOp() modules.UserOpHandlerFunc {
 	}
 }
 
+// GetAggregator returns the aggregator saved for a userOp during simulation or the zero address if it does not
+// use one. The aggregator is available from the time the userOp passes SimulateOp until it is cleaned up.
+func (s *Standalone) GetAggregator(userOpHash common.Hash) (common.Address, error) {
+	return getSavedAggregator(s.db, userOpHash)
+}
+
+// CleanReplacedOp returns a UserOpHandler that clears the DB of data saved during the simulation of a pending
+// userOp that was replaced by the userOp in the current context. This module must only run once the userOp
+// has been added to the mempool, otherwise a rejected replacement would leave the pending userOp without its
+// saved code hashes.
+func (s *Standalone) CleanReplacedOp() modules.UserOpHandlerFunc {
+	return func(ctx *modules.UserOpHandlerCtx) error {
+		replaced := ctx.GetReplacedOp()
+		if replaced == nil {
+			return nil
+		}
+
+		hash := replaced.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+		if hash == ctx.UserOp.GetUserOpHash(ctx.EntryPoint, ctx.ChainID) {
+			return nil
+		}
+		if err := removeSavedAggregators(s.db, hash); err != nil {
+			return err
+		}
+		return removeSavedCodeHashes(s.db, hash)
+	}
+}
+
 // CodeHashes returns a BatchHandler that verifies the code for any interacted contracts has not changed since
 // the first simulation.
 func (s *Standalone) CodeHashes() modules.BatchHandlerFunc {
@@ -221,6 +269,128 @@ func (s *Standalone) PaymasterDeposit() modules.BatchHandlerFunc {
 	}
 }
 
+// SimulateSolvedIntents returns a BatchHandler that re-runs simulation with the EntryPoint for every solved
+// Intent userOp in the batch (i.e. EVM calldata with the Intent JSON carried in the signature). Intents skip
+// simulation when first received by the Client and the solution returned by the Solver is otherwise
+// unchecked. Any solved Intent that fails is dropped from the batch and a SimulationFailure is recorded in
+// ctx.Data. The aggregator and code hashes found during simulation are saved in the same way as the Client
+// does for conventional userOps. This should be executed after the Solver module and before the
+// AggregateSignatures module.
+func (s *Standalone) SimulateSolvedIntents() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		ep, err := entrypoint.NewEntrypoint(ctx.EntryPoint, s.eth)
+		if err != nil {
+			return err
+		}
+
+		failures := []SimulationFailure{}
+		end := len(ctx.Batch) - 1
+		for i := end; i >= 0; i-- {
+			op := ctx.Batch[i]
+			if !op.HasIntent() || !op.IsSolvedIntent() {
+				continue
+			}
+
+			stakes, err := getEntityStakes(ep, op)
+			if err != nil {
+				return err
+			}
+
+			hash := op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+			sim, out, err := s.simulateSolvedOp(ep, ctx.EntryPoint, ctx.ChainID, op, stakes)
+			if err != nil {
+				failures = append(failures, newSimulationFailure(hash, op, err))
+				ctx.MarkOpIndexForRemoval(i)
+				continue
+			}
+
+			if sim.AggregatorInfo != nil && sim.AggregatorInfo.Aggregator != (common.Address{}) {
+				if err := saveAggregator(s.db, hash, sim.AggregatorInfo.Aggregator); err != nil {
+					return err
+				}
+			}
+			ch, err := getCodeHashes(out.TouchedContracts, getCodeWithEthClient(s.eth))
+			if err != nil {
+				return err
+			}
+			if err := saveCodeHashes(s.db, hash, ch); err != nil {
+				return err
+			}
+		}
+
+		if len(failures) > 0 {
+			ctx.Data[SolvedIntentsSimulationFailuresKey] = failures
+		}
+		return nil
+	}
+}
+
+// ValidateSolvedOp returns a function that runs the same simulation as SimulateSolvedIntents for a single
+// solved Intent userOp. It can be used to check a solution before it is accepted from a Solver.
+func (s *Standalone) ValidateSolvedOp() func(
+	entryPoint common.Address,
+	chainID *big.Int,
+	op *userop.UserOperation,
+) error {
+	return func(entryPoint common.Address, chainID *big.Int, op *userop.UserOperation) error {
+		ep, err := entrypoint.NewEntrypoint(entryPoint, s.eth)
+		if err != nil {
+			return err
+		}
+
+		stakes, err := getEntityStakes(ep, op)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = s.simulateSolvedOp(ep, entryPoint, chainID, op, stakes)
+		return err
+	}
+}
+
+// simulateSolvedOp runs simulateValidation and the tracer for a solved Intent userOp in parallel.
+func (s *Standalone) simulateSolvedOp(
+	ep *entrypoint.Entrypoint,
+	entryPoint common.Address,
+	chainID *big.Int,
+	op *userop.UserOperation,
+	stakes simulation.EntityStakes,
+) (*reverts.ValidationResultRevert, *simulation.TraceOutput, error) {
+	var sim *reverts.ValidationResultRevert
+	var out *simulation.TraceOutput
+	g := new(errgroup.Group)
+	g.Go(func() error {
+		var err error
+		if sim, err = simulateValidation(s.rpc, entryPoint, op); err != nil {
+			return err
+		}
+		return validateAggregator(sim, func(addr common.Address) (*entrypoint.IStakeManagerDepositInfo, error) {
+			dep, err := ep.GetDepositInfo(nil, addr)
+			return &dep, err
+		})
+	})
+	g.Go(func() error {
+		var err error
+		out, err = simulation.TraceSimulateValidation(&simulation.TraceInput{
+			Rpc:         s.rpc,
+			EntryPoint:  entryPoint,
+			AltMempools: s.alt,
+			Op:          op,
+			ChainID:     chainID,
+			Stakes:      stakes,
+		})
+		if err != nil {
+			return errors.NewRPCError(errors.BANNED_OPCODE, err.Error(), err.Error())
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	return sim, out, nil
+}
+
 // Clean returns a BatchHandler that clears the DB of data that is no longer required. This should be one of
 // the last modules executed by the Bundler.
 func (s *Standalone) Clean() modules.BatchHandlerFunc {
@@ -232,6 +402,9 @@ func (s *Standalone) Clean() modules.BatchHandlerFunc {
 			hashes = append(hashes, op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID))
 		}
 
+		if err := removeSavedAggregators(s.db, hashes...); err != nil {
+			return err
+		}
 		return removeSavedCodeHashes(s.db, hashes...)
 	}
 }
@@ -2,11 +2,18 @@ package checks
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/reverts"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/simulation"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
 // GetCodeFunc provides a general interface for retrieving the bytecode for a given address.
@@ -40,3 +47,57 @@ func getStakeWithEthClient(ctx *modules.UserOpHandlerCtx, eth *ethclient.Client)
 		return &dep, nil
 	}, nil
 }
+
+// simulateValidation runs simulateValidation on the EntryPoint for the given userOp and returns an RPCError
+// if the signature check failed or the userOp expires too soon.
+func simulateValidation(
+	rpc *rpc.Client,
+	entryPoint common.Address,
+	op *userop.UserOperation,
+) (*reverts.ValidationResultRevert, error) {
+	sim, err := simulation.SimulateValidation(rpc, entryPoint, op)
+	if err != nil {
+		return nil, errors.NewRPCError(errors.REJECTED_BY_EP_OR_ACCOUNT, err.Error(), err.Error())
+	}
+	if sim.ReturnInfo.SigFailed {
+		return nil, errors.NewRPCError(
+			errors.INVALID_SIGNATURE,
+			"Invalid UserOp signature or paymaster signature",
+			nil,
+		)
+	}
+	if sim.ReturnInfo.ValidUntil.Cmp(common.Big0) != 0 &&
+		time.Now().Unix() >= sim.ReturnInfo.ValidUntil.Int64()-30 {
+		return nil, errors.NewRPCError(
+			errors.SHORT_DEADLINE,
+			"expires too soon",
+			nil,
+		)
+	}
+	return sim, nil
+}
+
+// validateAggregator returns an RPCError if the userOp uses an aggregator that is not staked with the
+// EntryPoint.
+func validateAggregator(sim *reverts.ValidationResultRevert, gs GetStakeFunc) error {
+	if sim.AggregatorInfo == nil {
+		return nil
+	}
+
+	agg := sim.AggregatorInfo.Aggregator
+	if agg == (common.Address{}) {
+		return nil
+	}
+	dep, err := gs(agg)
+	if err != nil {
+		return err
+	}
+	if !dep.Staked {
+		return errors.NewRPCError(
+			errors.INVALID_AGGREGATOR,
+			fmt.Sprintf("aggregator %s is not staked", agg),
+			agg,
+		)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package modules
 import (
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 
@@ -76,6 +77,8 @@ type UserOpHandlerCtx struct {
 	ChainID    *big.Int
 	deposits   sync.Map
 	pendingOps []*userop.UserOperation
+	validUntil time.Time
+	aggregator common.Address
 }
 
 // NewUserOpHandlerContext creates a new UserOpHandlerCtx using a given op.
@@ -124,3 +127,36 @@ func (c *UserOpHandlerCtx) GetDeposits() (deps []*entrypoint.IStakeManagerDeposi
 func (c *UserOpHandlerCtx) GetPendingOps() []*userop.UserOperation {
 	return c.pendingOps
 }
+
+// GetReplacedOp returns the pending UserOperation by the same UserOp.Sender with the same nonce as UserOp.
+// This is the UserOperation that will be replaced in the mempool if UserOp is accepted. Otherwise returns nil.
+func (c *UserOpHandlerCtx) GetReplacedOp() *userop.UserOperation {
+	for _, op := range c.pendingOps {
+		if op.Nonce.Cmp(c.UserOp.Nonce) == 0 {
+			return op
+		}
+	}
+	return nil
+}
+
+// SetValidUntil sets the validUntil timestamp returned by simulation of UserOp. A zero time means the UserOp
+// does not expire.
+func (c *UserOpHandlerCtx) SetValidUntil(validUntil time.Time) {
+	c.validUntil = validUntil
+}
+
+// GetValidUntil returns the validUntil timestamp of UserOp if it was previously set. Otherwise returns a zero
+// time.
+func (c *UserOpHandlerCtx) GetValidUntil() time.Time {
+	return c.validUntil
+}
+
+// SetAggregator sets the aggregator of UserOp found during simulation.
+func (c *UserOpHandlerCtx) SetAggregator(aggregator common.Address) {
+	c.aggregator = aggregator
+}
+
+// GetAggregator returns the aggregator of UserOp if it was previously set. Otherwise returns the zero address.
+func (c *UserOpHandlerCtx) GetAggregator() common.Address {
+	return c.aggregator
+}
@@ -59,3 +59,31 @@ func TestGetPendingOps(t *testing.T) {
 		}
 	}
 }
+
+// TestGetReplacedOp calls (c *UserOpHandlerCtx).GetReplacedOp and verifies that it returns the pending
+// UserOperation with the same nonce.
+func TestGetReplacedOp(t *testing.T) {
+	penOp1 := testutils.MockValidInitUserOp()
+	penOp2 := testutils.MockValidInitUserOp()
+	penOp2.Nonce = big.NewInt(0).Add(penOp1.Nonce, common.Big1)
+	op := testutils.MockValidInitUserOp()
+	op.Nonce = penOp2.Nonce
+	ctx := NewUserOpHandlerContext(op, []*userop.UserOperation{penOp1, penOp2}, testutils.ValidAddress1, testutils.ChainID)
+
+	if replaced := ctx.GetReplacedOp(); replaced != penOp2 {
+		t.Fatalf("got %+v, want %+v", replaced, penOp2)
+	}
+}
+
+// TestGetNilReplacedOp calls (c *UserOpHandlerCtx).GetReplacedOp when no pending UserOperation has the same
+// nonce. Expects nil.
+func TestGetNilReplacedOp(t *testing.T) {
+	penOp := testutils.MockValidInitUserOp()
+	op := testutils.MockValidInitUserOp()
+	op.Nonce = big.NewInt(0).Add(penOp.Nonce, common.Big1)
+	ctx := NewUserOpHandlerContext(op, []*userop.UserOperation{penOp}, testutils.ValidAddress1, testutils.ChainID)
+
+	if replaced := ctx.GetReplacedOp(); replaced != nil {
+		t.Fatalf("got %+v, want nil", replaced)
+	}
+}
@@ -0,0 +1,256 @@
+// Package entities implements modules for reputation scoring and throttling/banning of the entities in a
+// UserOperation as specified in ERC-7562. Paymasters, factories, aggregators, and staked senders are given a
+// reputation.
+package entities
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// GetAggregatorFunc returns the aggregator found during the simulation of a pending userOp or the zero address
+// if it does not use one.
+type GetAggregatorFunc = func(userOpHash common.Hash) (common.Address, error)
+
+func noopGetAggregator(userOpHash common.Hash) (common.Address, error) {
+	return common.Address{}, nil
+}
+
+// Reputation provides Client and Bundler modules to track the status of every entity seen in a
+// UserOperation.
+type Reputation struct {
+	db            storage.Store
+	mempool       *mempool.Mempool
+	getAggregator GetAggregatorFunc
+}
+
+// New returns an instance of a Reputation object to track and appropriately process userOps by entity status.
+// The mempool is used to count the pending userOps of throttled entities.
+func New(db storage.Store, mempool *mempool.Mempool) *Reputation {
+	return &Reputation{db, mempool, noopGetAggregator}
+}
+
+// SetGetAggregatorFunc defines the function used to look up the aggregator of a pending userOp so that the
+// status of aggregators is enforced for userOps in the mempool. Aggregators are not tracked for pending
+// userOps by default.
+func (r *Reputation) SetGetAggregatorFunc(fn GetAggregatorFunc) {
+	r.getAggregator = fn
+}
+
+func getUserOpEntities(ctx *modules.UserOpHandlerCtx) []entity {
+	dep := ctx.GetDepositInfo(ctx.UserOp.Sender)
+	return getEntities(ctx.UserOp, dep != nil && dep.Staked, ctx.GetAggregator())
+}
+
+// getPendingEntities returns the entities of a userOp in the mempool including its aggregator.
+func (r *Reputation) getPendingEntities(
+	ep common.Address,
+	chainID *big.Int,
+	op *userop.UserOperation,
+	stakedSender bool,
+) ([]entity, error) {
+	agg, err := r.getAggregator(op.GetUserOpHash(ep, chainID))
+	if err != nil {
+		return nil, err
+	}
+	return getEntities(op, stakedSender, agg), nil
+}
+
+// countPendingOps returns the number of userOps in the mempool with the entity, not counting the userOp that
+// is replaced by the one in the current context. Since aggregators are not indexed by the mempool, they are
+// looked up for every pending userOp.
+func (r *Reputation) countPendingOps(ctx *modules.UserOpHandlerCtx, e entity) (int, error) {
+	replaced := ctx.GetReplacedOp()
+	if e.kind != "aggregator" {
+		count := r.mempool.CountOpsByEntity(ctx.EntryPoint, e.address)
+		if replaced != nil && hasEntity(getEntities(replaced, true, common.Address{}), e.address) {
+			count--
+		}
+		return count, nil
+	}
+
+	pending, err := r.mempool.Dump(ctx.EntryPoint)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, op := range pending {
+		if replaced != nil && op.Sender == replaced.Sender && op.Nonce.Cmp(replaced.Nonce) == 0 {
+			continue
+		}
+
+		agg, err := r.getAggregator(op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID))
+		if err != nil {
+			return 0, err
+		} else if agg == e.address {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func hasEntity(entities []entity, address common.Address) bool {
+	for _, e := range entities {
+		if e.address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckStatus returns a UserOpHandler that is used by the Client to determine if the userOp is allowed based
+// on the status of each entity. This module should run after checks.SimulateOp so that the aggregator of the
+// userOp is known.
+//  1. ok: The entity is allowed.
+//  2. throttled: New ops are only allowed while the mempool holds less than ThrottledEntityMempoolCount ops
+//     of the entity.
+//  3. banned: No ops with the entity are allowed.
+func (r *Reputation) CheckStatus() modules.UserOpHandlerFunc {
+	return func(ctx *modules.UserOpHandlerCtx) error {
+		return r.db.Update(func(txn storage.Txn) error {
+			now := time.Now()
+			for _, e := range getUserOpEntities(ctx) {
+				status, err := getStatus(txn, e.address, now)
+				if err != nil {
+					return err
+				}
+
+				switch status {
+				case banned:
+					msg := fmt.Sprintf("entities: %s %s is currently banned", e.kind, e.address)
+					return errors.NewRPCError(errors.BANNED_OR_THROTTLED_PAYMASTER, msg, e.address)
+				case throttled:
+					count, err := r.countPendingOps(ctx, e)
+					if err != nil {
+						return err
+					}
+					if count >= ThrottledEntityMempoolCount {
+						msg := fmt.Sprintf(
+							"entities: %s %s is currently throttled and has %d pending ops",
+							e.kind,
+							e.address,
+							count,
+						)
+						return errors.NewRPCError(errors.BANNED_OR_THROTTLED_PAYMASTER, msg, e.address)
+					}
+				}
+			}
+
+			return nil
+		})
+	}
+}
+
+// IncOpsSeen returns a UserOpHandler that is used by the Client to increment the opsSeen counter of each
+// entity in the userOp. If the userOp replaces a pending one, the opsSeen counters of its entities are
+// decremented since the replaced userOp can no longer be included. This module must only run once the userOp
+// has been added to the mempool and before checks.CleanReplacedOp so that the aggregator of the replaced
+// userOp is still known.
+func (r *Reputation) IncOpsSeen() modules.UserOpHandlerFunc {
+	return func(ctx *modules.UserOpHandlerCtx) error {
+		return r.db.Update(func(txn storage.Txn) error {
+			now := time.Now()
+			if replaced := ctx.GetReplacedOp(); replaced != nil {
+				dep := ctx.GetDepositInfo(replaced.Sender)
+				staked := dep != nil && dep.Staked
+				entities, err := r.getPendingEntities(ctx.EntryPoint, ctx.ChainID, replaced, staked)
+				if err != nil {
+					return err
+				}
+				for _, e := range entities {
+					if err := decrementOpsSeen(txn, e.address, now); err != nil {
+						return err
+					}
+				}
+			}
+
+			for _, e := range getUserOpEntities(ctx) {
+				if err := incrementOpsSeen(txn, e.address, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// FilterByStatus returns a BatchHandler used by the Bundler to enforce the status of each entity in the
+// batch. Ops with a banned entity are dropped from the mempool. Only the first ThrottledEntityBundleCount ops
+// with a throttled entity are kept in the batch and the rest are left in the mempool for a later batch.
+func (r *Reputation) FilterByStatus() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		return r.db.Update(func(txn storage.Txn) error {
+			now := time.Now()
+			statuses := make(map[common.Address]status)
+			included := make(map[common.Address]int)
+			batch := []*userop.UserOperation{}
+			for _, op := range ctx.Batch {
+				entities, err := r.getPendingEntities(ctx.EntryPoint, ctx.ChainID, op, true)
+				if err != nil {
+					return err
+				}
+				drop, skip := false, false
+				for _, e := range entities {
+					if _, ok := statuses[e.address]; !ok {
+						status, err := getStatus(txn, e.address, now)
+						if err != nil {
+							return err
+						}
+						statuses[e.address] = status
+					}
+
+					switch statuses[e.address] {
+					case banned:
+						drop = true
+					case throttled:
+						if included[e.address] >= ThrottledEntityBundleCount {
+							skip = true
+						}
+					}
+				}
+
+				if drop {
+					ctx.PendingRemoval = append(ctx.PendingRemoval, op)
+					continue
+				} else if skip {
+					continue
+				}
+				for _, e := range entities {
+					included[e.address]++
+				}
+				batch = append(batch, op)
+			}
+			ctx.Batch = batch
+
+			return nil
+		})
+	}
+}
+
+// IncOpsIncluded returns a BatchHandler used by the Bundler to increment opsIncluded counters for all
+// relevant entities in the batch. This module should be used last once batches have been sent.
+func (r *Reputation) IncOpsIncluded() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		return r.db.Update(func(txn storage.Txn) error {
+			aggs := checks.GetAggregators(ctx)
+			c := make(map[common.Address]int)
+			for _, op := range ctx.Batch {
+				agg := aggs[op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)]
+				for _, e := range getEntities(op, true, agg) {
+					c[e.address]++
+				}
+			}
+
+			return incrementOpsIncluded(txn, c, time.Now())
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package entities
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+func setOpsCount(t *testing.T, db storage.Store, entity common.Address, opsSeen int, opsIncluded int) {
+	err := db.Update(func(txn storage.Txn) error {
+		c := &opsCount{opsSeen, opsIncluded, time.Now().Unix()}
+		return txn.Set(getOpsCountKey(entity), getOpsCountValue(c))
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+func mockOpWithPaymaster(sender common.Address, paymaster common.Address) *userop.UserOperation {
+	op := testutils.MockValidInitUserOp()
+	op.Sender = sender
+	op.PaymasterAndData = paymaster.Bytes()
+	return op
+}
+
+// TestApplyExpWeightsDecaysHourly verifies that the counters decay by 1/24 for every full hour and that the
+// remainder of the hour is kept for the next decay.
+func TestApplyExpWeightsDecaysHourly(t *testing.T) {
+	now := time.Now()
+	last := now.Add(-150 * time.Minute)
+	c, err := applyExpWeights(getOpsCountValue(&opsCount{240, 48, last.Unix()}), now)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if c.opsSeen != 221 || c.opsIncluded != 45 {
+		t.Fatalf("got opsSeen %d and opsIncluded %d, want 221 and 45", c.opsSeen, c.opsIncluded)
+	} else if c.lastDecay != last.Add(2*time.Hour).Unix() {
+		t.Fatalf("got lastDecay %d, want %d", c.lastDecay, last.Add(2*time.Hour).Unix())
+	}
+}
+
+// TestGetStatus verifies that the status of an entity is derived from the throttling and ban thresholds.
+func TestGetStatus(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	tests := []struct {
+		opsSeen     int
+		opsIncluded int
+		want        status
+	}{
+		{0, 0, ok},
+		{100, 0, ok},
+		{210, 0, throttled},
+		{1000, 60, throttled},
+		{1000, 0, banned},
+	}
+
+	for _, tc := range tests {
+		setOpsCount(t, db, testutils.ValidAddress1, tc.opsSeen, tc.opsIncluded)
+		err := db.View(func(txn storage.Txn) error {
+			got, err := getStatus(txn, testutils.ValidAddress1, time.Now())
+			if err != nil {
+				return err
+			} else if got != tc.want {
+				t.Fatalf("got %s for %d/%d, want %s", got, tc.opsIncluded, tc.opsSeen, tc.want)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+}
+
+// TestCheckStatusRejectsBannedEntity verifies that a userOp is rejected if any of its entities is banned.
+func TestCheckStatusRejectsBannedEntity(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := mempool.New(db)
+	rep := New(db, mem)
+	setOpsCount(t, db, testutils.ValidAddress2, 1000, 0)
+
+	op := mockOpWithPaymaster(testutils.ValidAddress1, testutils.ValidAddress2)
+	ctx := modules.NewUserOpHandlerContext(op, nil, testutils.ValidAddress3, testutils.ChainID)
+	err := rep.CheckStatus()(ctx)
+	if err == nil {
+		t.Fatal("got nil, want err")
+	} else if rpcErr, ok := err.(*errors.RPCError); !ok || rpcErr.Code() != errors.BANNED_OR_THROTTLED_PAYMASTER {
+		t.Fatalf("got %v, want BANNED_OR_THROTTLED_PAYMASTER error", err)
+	}
+}
+
+// TestCheckStatusLimitsThrottledEntity verifies that a userOp with a throttled entity is only accepted while
+// the mempool holds less than ThrottledEntityMempoolCount ops of that entity.
+func TestCheckStatusLimitsThrottledEntity(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := mempool.New(db)
+	rep := New(db, mem)
+	ep := testutils.ValidAddress3
+	setOpsCount(t, db, testutils.ValidAddress2, 210, 0)
+
+	for i := 0; i < ThrottledEntityMempoolCount; i++ {
+		sender := common.BigToAddress(big.NewInt(int64(i + 1)))
+		op := mockOpWithPaymaster(sender, testutils.ValidAddress2)
+		ctx := modules.NewUserOpHandlerContext(op, nil, ep, testutils.ChainID)
+		if err := rep.CheckStatus()(ctx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		if err := mem.AddOp(ep, op); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	op := mockOpWithPaymaster(testutils.ValidAddress1, testutils.ValidAddress2)
+	ctx := modules.NewUserOpHandlerContext(op, nil, ep, testutils.ChainID)
+	if err := rep.CheckStatus()(ctx); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
+
+// TestFilterByStatus verifies that ops with a banned entity are dropped and that at most
+// ThrottledEntityBundleCount ops with a throttled entity are kept in the batch.
+func TestFilterByStatus(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := mempool.New(db)
+	rep := New(db, mem)
+	bannedPm := testutils.ValidAddress1
+	throttledPm := testutils.ValidAddress2
+	setOpsCount(t, db, bannedPm, 1000, 0)
+	setOpsCount(t, db, throttledPm, 210, 0)
+
+	batch := []*userop.UserOperation{mockOpWithPaymaster(testutils.ValidAddress3, bannedPm)}
+	for i := 0; i < ThrottledEntityBundleCount+2; i++ {
+		batch = append(batch, mockOpWithPaymaster(common.BigToAddress(big.NewInt(int64(i+1))), throttledPm))
+	}
+	ctx := modules.NewBatchHandlerContext(batch, testutils.ValidAddress3, testutils.ChainID, nil, nil, nil)
+	if err := rep.FilterByStatus()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != ThrottledEntityBundleCount {
+		t.Fatalf("got batch length %d, want %d", len(ctx.Batch), ThrottledEntityBundleCount)
+	} else if len(ctx.PendingRemoval) != 1 || !testutils.IsOpsEqual(ctx.PendingRemoval[0], batch[0]) {
+		t.Fatal("incorrect pending removal: Didn't drop op with banned entity")
+	}
+}
+
+// TestIncOpsIncludedSkipsUntrackedEntities verifies that opsIncluded is only incremented for entities that
+// have been seen, so that an unstaked sender is not given a reputation.
+func TestIncOpsIncludedSkipsUntrackedEntities(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := mempool.New(db)
+	rep := New(db, mem)
+	op := mockOpWithPaymaster(testutils.ValidAddress1, testutils.ValidAddress2)
+
+	ctx := modules.NewUserOpHandlerContext(op, nil, testutils.ValidAddress3, testutils.ChainID)
+	if err := rep.IncOpsSeen()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	bctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{op},
+		testutils.ValidAddress3,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	if err := rep.IncOpsIncluded()(bctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	err := db.View(func(txn storage.Txn) error {
+		if c, err := getOpsCount(txn, testutils.ValidAddress2, time.Now()); err != nil {
+			return err
+		} else if c == nil || c.opsSeen != 1 || c.opsIncluded != 1 {
+			t.Fatalf("got paymaster counts %v, want 1/1", c)
+		}
+		if c, err := getOpsCount(txn, op.GetFactory(), time.Now()); err != nil {
+			return err
+		} else if c == nil || c.opsSeen != 1 || c.opsIncluded != 1 {
+			t.Fatalf("got factory counts %v, want 1/1", c)
+		}
+		if c, err := getOpsCount(txn, op.Sender, time.Now()); err != nil {
+			return err
+		} else if c != nil {
+			t.Fatalf("got sender counts %v, want nil", c)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestFilterByStatusDropsBannedAggregator verifies that ops are dropped from the batch if the aggregator found
+// during their simulation is banned.
+func TestFilterByStatusDropsBannedAggregator(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	mem, _ := mempool.New(db)
+	rep := New(db, mem)
+	ep := testutils.ValidAddress3
+	bannedAgg := common.BigToAddress(big.NewInt(100))
+	setOpsCount(t, db, bannedAgg, 1000, 0)
+
+	aggOp := mockOpWithPaymaster(testutils.ValidAddress1, common.Address{})
+	op := mockOpWithPaymaster(testutils.ValidAddress2, common.Address{})
+	aggOpHash := aggOp.GetUserOpHash(ep, testutils.ChainID)
+	rep.SetGetAggregatorFunc(func(userOpHash common.Hash) (common.Address, error) {
+		if userOpHash == aggOpHash {
+			return bannedAgg, nil
+		}
+		return common.Address{}, nil
+	})
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{aggOp, op},
+		ep,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	if err := rep.FilterByStatus()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 || !testutils.IsOpsEqual(ctx.Batch[0], op) {
+		t.Fatal("incorrect batch: Didn't keep op without aggregator")
+	} else if len(ctx.PendingRemoval) != 1 || !testutils.IsOpsEqual(ctx.PendingRemoval[0], aggOp) {
+		t.Fatal("incorrect pending removal: Didn't drop op with banned aggregator")
+	}
+}
+
+// TestLegacyOpsCountIsMigrated verifies that counters stored under the legacy paymaster prefix are read and
+// moved to the entities prefix on the next write.
+func TestLegacyOpsCountIsMigrated(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	pm := testutils.ValidAddress2
+	err := db.Update(func(txn storage.Txn) error {
+		c := &opsCount{1000, 0, time.Now().Unix()}
+		return txn.Set(getLegacyOpsCountKey(pm), getOpsCountValue(c))
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	err = db.Update(func(txn storage.Txn) error {
+		if s, err := getStatus(txn, pm, time.Now()); err != nil {
+			return err
+		} else if s != banned {
+			t.Fatalf("got %s, want banned", s)
+		}
+		return incrementOpsSeen(txn, pm, time.Now())
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	err = db.View(func(txn storage.Txn) error {
+		if _, err := txn.Get(getLegacyOpsCountKey(pm)); err != storage.ErrKeyNotFound {
+			t.Fatalf("got %v, want ErrKeyNotFound", err)
+		}
+		value, err := txn.Get(getOpsCountKey(pm))
+		if err != nil {
+			return err
+		}
+		if c, err := applyExpWeights(value, time.Now()); err != nil {
+			return err
+		} else if c.opsSeen != 1001 {
+			t.Fatalf("got opsSeen %d, want 1001", c.opsSeen)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
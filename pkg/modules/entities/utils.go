@@ -0,0 +1,226 @@
+package entities
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+type status int64
+
+const (
+	ok status = iota
+	throttled
+	banned
+)
+
+func (s status) String() string {
+	switch s {
+	case throttled:
+		return "throttled"
+	case banned:
+		return "banned"
+	default:
+		return "ok"
+	}
+}
+
+const minInclusionRateDenominator = 10
+const throttlingSlack = 10
+const banSlack = 50
+const emaHours = 24
+
+// ThrottledEntityMempoolCount is the number of UserOperations with a throttled entity that can stay in the
+// mempool.
+const ThrottledEntityMempoolCount = 4
+
+// ThrottledEntityBundleCount is the number of UserOperations with a throttled entity that can be added in a
+// single bundle.
+const ThrottledEntityBundleCount = 4
+
+var (
+	opsCountPrefix = dbutils.JoinValues("entities", "opsCount")
+
+	// legacyOpsCountPrefix is where the counters of paymasters were stored before all entities were given a
+	// reputation. The values have the same format and are moved to opsCountPrefix on the next write.
+	legacyOpsCountPrefix = dbutils.JoinValues("paymaster", "opsCount")
+)
+
+// entity is an address that is given a reputation and the role it has in a UserOperation.
+type entity struct {
+	kind    string
+	address common.Address
+}
+
+// getEntities returns the entities of a userOp which are given a reputation. The sender is only included if
+// it is staked, and the aggregator only if it is known. An address with more than one role is returned once.
+func getEntities(op *userop.UserOperation, stakedSender bool, aggregator common.Address) []entity {
+	all := []entity{
+		{"factory", op.GetFactory()},
+		{"paymaster", op.GetPaymaster()},
+		{"aggregator", aggregator},
+	}
+	if stakedSender {
+		all = append([]entity{{"sender", op.Sender}}, all...)
+	}
+
+	seen := make(map[common.Address]bool)
+	entities := []entity{}
+	for _, e := range all {
+		if e.address == common.HexToAddress("0x") || seen[e.address] {
+			continue
+		}
+
+		seen[e.address] = true
+		entities = append(entities, e)
+	}
+	return entities
+}
+
+type opsCount struct {
+	opsSeen     int
+	opsIncluded int
+	lastDecay   int64
+}
+
+func getOpsCountKey(entity common.Address) []byte {
+	return []byte(dbutils.JoinValues(opsCountPrefix, entity.String()))
+}
+
+func getLegacyOpsCountKey(entity common.Address) []byte {
+	return []byte(dbutils.JoinValues(legacyOpsCountPrefix, entity.String()))
+}
+
+func getOpsCountValue(c *opsCount) []byte {
+	return []byte(
+		dbutils.JoinValues(
+			strconv.Itoa(c.opsSeen),
+			strconv.Itoa(c.opsIncluded),
+			strconv.FormatInt(c.lastDecay, 10),
+		),
+	)
+}
+
+// applyExpWeights decays the counters by 1/24 for every full hour since the last decay. Only the full hours
+// are consumed so that frequent reads do not postpone the decay.
+func applyExpWeights(value []byte, now time.Time) (*opsCount, error) {
+	counts := dbutils.SplitValues(string(value))
+	opsSeen, err := strconv.Atoi(counts[0])
+	if err != nil {
+		return nil, err
+	}
+	opsIncluded, err := strconv.Atoi(counts[1])
+	if err != nil {
+		return nil, err
+	}
+	lastDecay, err := strconv.ParseInt(counts[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := int(now.Sub(time.Unix(lastDecay, 0)).Hours())
+	c := &opsCount{opsSeen, opsIncluded, lastDecay + int64(hours)*int64(time.Hour/time.Second)}
+	for i := hours; i > 0; i-- {
+		if c.opsSeen < emaHours && c.opsIncluded < emaHours {
+			break
+		}
+
+		c.opsSeen -= c.opsSeen / emaHours
+		c.opsIncluded -= c.opsIncluded / emaHours
+	}
+	return c, nil
+}
+
+// getOpsCount returns the decayed counters of an entity. It returns nil if the entity has not been seen.
+// Counters stored under the legacy paymaster prefix are returned if the entity has no counters yet.
+func getOpsCount(txn storage.Txn, entity common.Address, now time.Time) (*opsCount, error) {
+	value, err := txn.Get(getOpsCountKey(entity))
+	if err == storage.ErrKeyNotFound {
+		value, err = txn.Get(getLegacyOpsCountKey(entity))
+	}
+	if err == storage.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return applyExpWeights(value, now)
+}
+
+// saveOpsCount writes the counters of an entity and removes any counters stored under the legacy prefix.
+func saveOpsCount(txn storage.Txn, entity common.Address, c *opsCount) error {
+	if err := txn.Delete(getLegacyOpsCountKey(entity)); err != nil {
+		return err
+	}
+	return txn.Set(getOpsCountKey(entity), getOpsCountValue(c))
+}
+
+func incrementOpsSeen(txn storage.Txn, entity common.Address, now time.Time) error {
+	c, err := getOpsCount(txn, entity, now)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		c = &opsCount{lastDecay: now.Unix()}
+	}
+
+	c.opsSeen++
+	return saveOpsCount(txn, entity, c)
+}
+
+func decrementOpsSeen(txn storage.Txn, entity common.Address, now time.Time) error {
+	c, err := getOpsCount(txn, entity, now)
+	if err != nil {
+		return err
+	}
+	if c == nil || c.opsSeen == 0 {
+		return nil
+	}
+
+	c.opsSeen--
+	return saveOpsCount(txn, entity, c)
+}
+
+// incrementOpsIncluded adds to the opsIncluded counter of each entity in count. Entities that have not been
+// seen are skipped since they are not given a reputation (e.g. an unstaked sender).
+func incrementOpsIncluded(txn storage.Txn, count map[common.Address]int, now time.Time) error {
+	for entity, n := range count {
+		c, err := getOpsCount(txn, entity, now)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			continue
+		}
+
+		c.opsIncluded += n
+		if err := saveOpsCount(txn, entity, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getStatus(txn storage.Txn, entity common.Address, now time.Time) (status, error) {
+	c, err := getOpsCount(txn, entity, now)
+	if err != nil {
+		return ok, err
+	}
+	if c == nil || c.opsSeen == 0 {
+		return ok, nil
+	}
+
+	minExpectedIncluded := c.opsSeen / minInclusionRateDenominator
+	if minExpectedIncluded <= c.opsIncluded+throttlingSlack {
+		return ok, nil
+	} else if minExpectedIncluded <= c.opsIncluded+banSlack {
+		return throttled, nil
+	} else {
+		return banned, nil
+	}
+}
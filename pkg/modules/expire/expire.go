@@ -3,37 +3,64 @@ package expire
 import (
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
+// ValidUntilMargin is the minimum time left before the validUntil timestamp of a UserOperation for it to be
+// kept in a batch. This leaves time for the batch to be included on-chain before the EntryPoint rejects the
+// UserOperation as expired.
+const ValidUntilMargin = 30 * time.Second
+
 type ExpireHandler struct {
-	seenAt map[common.Hash]time.Time
-	ttl    time.Duration
+	mempool   *mempool.Mempool
+	ttl       time.Duration
+	intentTTL time.Duration
+	history   *intentstatus.History
+	now       func() time.Time
 }
 
 // New returns an ExpireHandler which contains a BatchHandlerFunc to track and drop UserOperations that have
-// been in the mempool for longer than the TTL duration.
-func New(ttl time.Duration) *ExpireHandler {
+// been in the mempool for longer than the TTL duration. The admission time of each UserOperation is read from
+// the mempool so that it is kept across resets.
+func New(mempool *mempool.Mempool, ttl time.Duration) *ExpireHandler {
 	return &ExpireHandler{
-		seenAt: make(map[common.Hash]time.Time),
-		ttl:    ttl,
+		mempool: mempool,
+		ttl:     ttl,
+		now:     time.Now,
 	}
 }
 
 // DropExpired returns a BatchHandlerFunc that will drop UserOperations from the mempool if it has been around
-// for longer than the TTL duration.
+// for longer than the TTL duration or if its validUntil timestamp is within ValidUntilMargin. Intent userOps
+// are skipped since their expiry is handled by DropExpiredIntents.
 func (e *ExpireHandler) DropExpired() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
+		now := e.now()
 		end := len(ctx.Batch) - 1
 		for i := end; i >= 0; i-- {
-			hash := ctx.Batch[i].GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
-			if seenAt, ok := e.seenAt[hash]; !ok {
-				e.seenAt[hash] = time.Now()
-			} else if seenAt.Add(e.ttl).Before(time.Now()) {
+			if ctx.Batch[i].HasIntent() {
+				continue
+			}
+
+			info := e.getOpInfo(ctx, ctx.Batch[i])
+			if info.AdmittedAt.Add(e.ttl).Before(now) ||
+				(!info.ValidUntil.IsZero() && !now.Before(info.ValidUntil.Add(-ValidUntilMargin))) {
 				ctx.MarkOpIndexForRemoval(i)
 			}
 		}
 		return nil
 	}
 }
+
+// getOpInfo returns the OpInfo of a userOp in the batch. A userOp that is no longer in the mempool is
+// considered admitted now.
+func (e *ExpireHandler) getOpInfo(ctx *modules.BatchHandlerCtx, op *userop.UserOperation) mempool.OpInfo {
+	info, ok := e.mempool.GetOpInfo(ctx.EntryPoint, op)
+	if !ok {
+		return mempool.OpInfo{AdmittedAt: e.now()}
+	}
+	return info
+}
@@ -1,26 +1,55 @@
 package expire
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/blndgs/model"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
+// newMempoolWithEntries returns a Mempool loaded from a Store that holds the given Entries.
+func newMempoolWithEntries(t *testing.T, entries ...*mempool.Entry) *mempool.Mempool {
+	db := testutils.StoreMock()
+	t.Cleanup(func() { db.Close() })
+	if err := mempool.WriteEntries(db, entries...); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	return mem
+}
+
 // TestDropExpired calls (*ExpireHandler).DropExpired and verifies that it marks old UserOperations for
 // pending removal.
 func TestDropExpired(t *testing.T) {
-	exp := New(time.Second * 30)
 	op1 := testutils.MockValidInitUserOp()
 	op2 := testutils.MockValidInitUserOp()
-	op2.CallData = common.Hex2Bytes("0xdead")
-	exp.seenAt = map[common.Hash]time.Time{
-		op1.GetUserOpHash(testutils.ValidAddress1, common.Big1): time.Now().Add(time.Second * -45),
-		op2.GetUserOpHash(testutils.ValidAddress1, common.Big1): time.Now().Add(time.Second * -15),
-	}
+	op2.Nonce = common.Big1
+	mem := newMempoolWithEntries(
+		t,
+		&mempool.Entry{
+			EntryPoint: testutils.ValidAddress1,
+			UserOp:     op1,
+			Info:       mempool.OpInfo{AdmittedAt: time.Now().Add(time.Second * -45)},
+		},
+		&mempool.Entry{
+			EntryPoint: testutils.ValidAddress1,
+			UserOp:     op2,
+			Info:       mempool.OpInfo{AdmittedAt: time.Now().Add(time.Second * -15)},
+		},
+	)
+	exp := New(mem, time.Second*30)
 
 	ctx := modules.NewBatchHandlerContext(
 		[]*userop.UserOperation{op1, op2},
@@ -43,3 +72,130 @@ func TestDropExpired(t *testing.T) {
 	}
 
 }
+
+// TestDropExpiredSkipsIntents calls (*ExpireHandler).DropExpired and verifies that Intent userOps are not
+// dropped by the conventional TTL.
+func TestDropExpiredSkipsIntents(t *testing.T) {
+	op := testutils.MockValidIntentUserOp()
+	mem := newMempoolWithEntries(t, &mempool.Entry{
+		EntryPoint: testutils.ValidAddress1,
+		UserOp:     op,
+		Info:       mempool.OpInfo{AdmittedAt: time.Now().Add(time.Second * -45)},
+	})
+	exp := New(mem, time.Second*30)
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{op},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	if err := exp.DropExpired()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("got pending removal length %d, want 0", len(ctx.PendingRemoval))
+	}
+}
+
+// TestDropExpiredValidUntil calls (*ExpireHandler).DropExpired and verifies that it marks UserOperations for
+// pending removal once their validUntil timestamp is within ValidUntilMargin.
+func TestDropExpiredValidUntil(t *testing.T) {
+	op1 := testutils.MockValidInitUserOp()
+	op2 := testutils.MockValidInitUserOp()
+	op2.Nonce = common.Big1
+	mem := newMempoolWithEntries(
+		t,
+		&mempool.Entry{
+			EntryPoint: testutils.ValidAddress1,
+			UserOp:     op1,
+			Info:       mempool.OpInfo{AdmittedAt: time.Now(), ValidUntil: time.Now().Add(ValidUntilMargin / 2)},
+		},
+		&mempool.Entry{
+			EntryPoint: testutils.ValidAddress1,
+			UserOp:     op2,
+			Info:       mempool.OpInfo{AdmittedAt: time.Now(), ValidUntil: time.Now().Add(time.Minute * 5)},
+		},
+	)
+	exp := New(mem, time.Minute)
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{op1, op2},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	if err := exp.DropExpired()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	} else if !testutils.IsOpsEqual(ctx.Batch[0], op2) {
+		t.Fatal("incorrect batch: Dropped legit op")
+	} else if len(ctx.PendingRemoval) != 1 || !testutils.IsOpsEqual(ctx.PendingRemoval[0], op1) {
+		t.Fatal("incorrect pending removal: Didn't drop expired op")
+	}
+}
+
+// TestDropExpiredIntents calls (*ExpireHandler).DropExpiredIntents and verifies that it marks Intents past
+// their expirationAt deadline or max TTL for pending removal and records the Expired status.
+func TestDropExpiredIntents(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	history := intentstatus.New(db)
+	admitted := testutils.MockValidIntentUserOp()
+	admitted.Sender = testutils.ValidAddress2
+	mem := newMempoolWithEntries(t, &mempool.Entry{
+		EntryPoint: testutils.ValidAddress1,
+		UserOp:     admitted,
+		Info:       mempool.OpInfo{AdmittedAt: time.Now().Add(-2 * time.Minute)},
+	})
+	exp := New(mem, time.Second*30)
+	exp.SetIntentMaxTTL(time.Minute)
+	exp.SetStatusHistory(history)
+
+	deadline := testutils.MockValidIntentUserOp()
+	deadline.CallData = []byte(strings.Replace(
+		testutils.MockIntentJSON,
+		`"sender"`,
+		fmt.Sprintf(`"expirationAt":%d,"sender"`, time.Now().Add(-time.Second).Unix()),
+		1,
+	))
+	ttl := testutils.MockValidIntentUserOp()
+	ttl.CallData = []byte(strings.Replace(
+		testutils.MockIntentJSON,
+		`"sender"`,
+		fmt.Sprintf(`"createdAt":%d,"sender"`, time.Now().Add(-2*time.Minute).Unix()),
+		1,
+	))
+	fresh := testutils.MockValidIntentUserOp()
+
+	ctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{deadline, ttl, admitted, fresh},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	if err := exp.DropExpiredIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	} else if len(ctx.PendingRemoval) != 3 {
+		t.Fatalf("got pending removal length %d, want 3", len(ctx.PendingRemoval))
+	} else if !testutils.IsOpsEqual(ctx.Batch[0], fresh) {
+		t.Fatal("incorrect batch: Dropped legit op")
+	}
+
+	for _, op := range ctx.PendingRemoval {
+		status, err := history.GetStatus(op.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID).String())
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		} else if status == nil || status.Status != model.Expired {
+			t.Fatalf("got %v, want status %s", status, model.Expired)
+		}
+	}
+}
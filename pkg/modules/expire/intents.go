@@ -0,0 +1,75 @@
+package expire
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blndgs/model"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// SetIntentMaxTTL defines the max duration an Intent userOp can stay in the mempool if the Intent payload
+// does not set its own expirationAt deadline. The TTL is measured from the Intent's createdAt timestamp if
+// set. Otherwise it is measured from when the Intent was first seen. A value of 0 disables the TTL.
+func (e *ExpireHandler) SetIntentMaxTTL(ttl time.Duration) {
+	e.intentTTL = ttl
+}
+
+// SetStatusHistory defines the History used to record the Expired status for dropped Intents.
+func (e *ExpireHandler) SetStatusHistory(h *intentstatus.History) {
+	e.history = h
+}
+
+// getIntentDeadline returns the time at which an Intent userOp expires. A zero time is returned if the Intent
+// has no deadline.
+func (e *ExpireHandler) getIntentDeadline(
+	ctx *modules.BatchHandlerCtx,
+	op *userop.UserOperation,
+) (deadline time.Time, reason string) {
+	intent, intentErr := (*model.UserOperation)(op).GetIntent()
+	if intentErr == nil && intent.ExpirationAt > 0 {
+		reason = fmt.Sprintf("intent expirationAt %d reached", intent.ExpirationAt)
+		return time.Unix(intent.ExpirationAt, 0), reason
+	}
+	if e.intentTTL == 0 {
+		return time.Time{}, ""
+	}
+
+	reason = fmt.Sprintf("exceeded intent max TTL of %s", e.intentTTL)
+	if intentErr == nil && intent.CreatedAt > 0 {
+		return time.Unix(intent.CreatedAt, 0).Add(e.intentTTL), reason
+	}
+
+	// Solving replaces the pending userOp but keeps its admission time, so the TTL does not restart.
+	return e.getOpInfo(ctx, op).AdmittedAt.Add(e.intentTTL), reason
+}
+
+// DropExpiredIntents returns a BatchHandlerFunc that will drop Intent userOps from the mempool once their
+// deadline has passed and record the Expired status for each. Non-Intent userOps are skipped.
+func (e *ExpireHandler) DropExpiredIntents() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		end := len(ctx.Batch) - 1
+		for i := end; i >= 0; i-- {
+			op := ctx.Batch[i]
+			if !op.HasIntent() {
+				continue
+			}
+
+			deadline, reason := e.getIntentDeadline(ctx, op)
+			if deadline.IsZero() || e.now().Before(deadline) {
+				continue
+			}
+
+			ctx.MarkOpIndexForRemoval(i)
+			if e.history != nil {
+				hash := op.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+				if err := e.history.Record(hash, model.Expired, reason); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package intentstatus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blndgs/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/goccy/go-json"
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+)
+
+var (
+	keyPrefix      = dbutils.JoinValues("intentstatus")
+	aliasPrefix    = dbutils.JoinValues(keyPrefix, "alias")
+	originalPrefix = dbutils.JoinValues(keyPrefix, "original")
+)
+
+// historyTTL bounds how long the transitions and hash alias of an Intent are kept around after the last
+// update.
+const historyTTL = 7 * 24 * time.Hour
+
+func normalizeHash(hash string) string {
+	return common.HexToHash(hash).String()
+}
+
+func getHistoryPrefix(hash string) []byte {
+	return []byte(dbutils.JoinValues(keyPrefix, normalizeHash(hash), ""))
+}
+
+func getTransitionKey(hash string, at time.Time) []byte {
+	return []byte(dbutils.JoinValues(keyPrefix, normalizeHash(hash), fmt.Sprintf("%020d", at.UnixNano())))
+}
+
+// Append adds a status transition for the Intent with the given original userOpHash within an existing DB
+// transaction. This allows other modules sharing the same DB to record transitions atomically with their own
+// state.
+func Append(txn storage.Txn, hash string, status model.ProcessingStatus, reason string) error {
+	now := time.Now()
+	value, err := json.Marshal(&Transition{Status: status, Timestamp: now.Unix(), Reason: reason})
+	if err != nil {
+		return err
+	}
+
+	return txn.SetWithTTL(getTransitionKey(hash, now), value, historyTTL)
+}
+
+func getAliasKey(hash string) []byte {
+	return []byte(dbutils.JoinValues(aliasPrefix, normalizeHash(hash)))
+}
+
+func getOriginalKey(hash string) []byte {
+	return []byte(dbutils.JoinValues(originalPrefix, normalizeHash(hash)))
+}
+
+// SetAlias maps the original userOpHash of an Intent to the userOpHash of its solved userOp and vice versa
+// within an existing DB transaction. Solving rewrites the fields used to compute the hash and the alias
+// allows lookups by the hash returned to the sender to resolve to the operation that is sent on-chain.
+func SetAlias(txn storage.Txn, original string, solved string) error {
+	if err := txn.SetWithTTL(getAliasKey(original), []byte(normalizeHash(solved)), historyTTL); err != nil {
+		return err
+	}
+
+	return txn.SetWithTTL(getOriginalKey(solved), []byte(normalizeHash(original)), historyTTL)
+}
+
+func getAlias(txn storage.Txn, hash string) (string, error) {
+	return getValue(txn, getAliasKey(hash))
+}
+
+func getOriginal(txn storage.Txn, hash string) (string, error) {
+	return getValue(txn, getOriginalKey(hash))
+}
+
+func getValue(txn storage.Txn, key []byte) (string, error) {
+	value, err := txn.Get(key)
+	if err == storage.ErrKeyNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+func getTransitions(txn storage.Txn, hash string) ([]Transition, error) {
+	transitions := []Transition{}
+	err := txn.Iterate(getHistoryPrefix(hash), func(key []byte, value []byte) error {
+		var t Transition
+		if err := json.Unmarshal(value, &t); err != nil {
+			return err
+		}
+		transitions = append(transitions, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
@@ -0,0 +1,183 @@
+// Package intentstatus implements modules for recording every ProcessingStatus transition of an Intent
+// userOp from the time it is received by the Client until it is dropped or included on-chain. The history is
+// persisted in the embedded DB under the original userOpHash returned by eth_sendUserOperation along with an
+// alias to the userOpHash of the solved userOp.
+package intentstatus
+
+import (
+	"math/big"
+
+	"github.com/blndgs/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// Transition is a single change in the ProcessingStatus of an Intent.
+type Transition struct {
+	Status    model.ProcessingStatus `json:"status"`
+	Timestamp int64                  `json:"timestamp"`
+	Reason    string                 `json:"reason,omitempty"`
+}
+
+// Status is the current ProcessingStatus of an Intent along with every transition it went through.
+type Status struct {
+	UserOpHash  string                 `json:"userOpHash"`
+	Status      model.ProcessingStatus `json:"status"`
+	Transitions []Transition           `json:"transitions"`
+}
+
+// History provides Client and Bundler modules to track the ProcessingStatus of every Intent userOp.
+type History struct {
+	db storage.Store
+}
+
+// New returns an instance of a History object to record and query the status transitions of Intents.
+func New(db storage.Store) *History {
+	return &History{db}
+}
+
+// GetStatus returns the current status and transition history of an Intent given its original userOpHash. A
+// nil Status is returned if the hash is unknown.
+func (h *History) GetStatus(hash string) (*Status, error) {
+	var transitions []Transition
+	err := h.db.View(func(txn storage.Txn) error {
+		var err error
+		transitions, err = getTransitions(txn, hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(transitions) == 0 {
+		return nil, nil
+	}
+
+	return &Status{
+		UserOpHash:  normalizeHash(hash),
+		Status:      transitions[len(transitions)-1].Status,
+		Transitions: transitions,
+	}, nil
+}
+
+// ResolveHash returns the userOpHash of the solved userOp if the given hash is the original userOpHash of a
+// solved Intent. Otherwise the given hash is returned as is.
+func (h *History) ResolveHash(hash string) (string, error) {
+	var alias string
+	err := h.db.View(func(txn storage.Txn) error {
+		var err error
+		alias, err = getAlias(txn, hash)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if alias == "" {
+		return hash, nil
+	}
+
+	return alias, nil
+}
+
+// ResolveOriginalHash returns the original userOpHash of an Intent if the given hash is the userOpHash of its
+// solved userOp. Otherwise the given hash is returned as is.
+func (h *History) ResolveOriginalHash(hash common.Hash) (common.Hash, error) {
+	var original string
+	err := h.db.View(func(txn storage.Txn) error {
+		var err error
+		original, err = getOriginal(txn, hash.String())
+		return err
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if original == "" {
+		return hash, nil
+	}
+
+	return common.HexToHash(original), nil
+}
+
+// Record adds a status transition for an Intent given the userOpHash of either the original or the solved
+// userOp.
+func (h *History) Record(hash common.Hash, status model.ProcessingStatus, reason string) error {
+	return h.db.Update(func(txn storage.Txn) error {
+		original, err := getOriginal(txn, hash.String())
+		if err != nil {
+			return err
+		}
+		if original == "" {
+			original = hash.String()
+		}
+
+		return Append(txn, original, status, reason)
+	})
+}
+
+// RecordReceived returns a UserOpHandler that is used by the Client to record the Received status for
+// incoming Intent userOps. This should be the last module executed by the Client.
+func (h *History) RecordReceived() modules.UserOpHandlerFunc {
+	return func(ctx *modules.UserOpHandlerCtx) error {
+		if !ctx.UserOp.HasIntent() {
+			return nil
+		}
+
+		return h.db.Update(func(txn storage.Txn) error {
+			hash := ctx.UserOp.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+			return Append(txn, hash.String(), model.Received, "")
+		})
+	}
+}
+
+// RecordBatch returns a BatchHandler that is used by the Bundler to record the solved Intents in the batch that
+// failed re-simulation as Invalid. Solved Intents that are sent are only recorded as OnChain by RecordOnChain
+// once the bundle transaction is confirmed. This should be executed after the Relayer module.
+func (h *History) RecordBatch() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		return h.db.Update(func(txn storage.Txn) error {
+			failures, _ := ctx.Data[checks.SolvedIntentsSimulationFailuresKey].([]checks.SimulationFailure)
+			for _, f := range failures {
+				if err := appendByAlias(txn, f.UserOpHash, model.Invalid, f.Reason); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// RecordOnChain records the solved Intents included in a confirmed bundle transaction as OnChain. It can be
+// used as a reconcile.ConfirmedFunc.
+func (h *History) RecordOnChain(
+	ep common.Address,
+	chainID *big.Int,
+	txHash common.Hash,
+	included []*userop.UserOperation,
+) error {
+	return h.db.Update(func(txn storage.Txn) error {
+		for _, op := range included {
+			if !op.HasIntent() || !op.IsSolvedIntent() {
+				continue
+			}
+
+			hash := op.GetUserOpHash(ep, chainID)
+			if err := appendByAlias(txn, hash, model.OnChain, "included in transaction "+txHash.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// appendByAlias adds a status transition for a solved Intent given the userOpHash of the solved userOp. The
+// transition is skipped if the original userOpHash is unknown.
+func appendByAlias(txn storage.Txn, solved common.Hash, status model.ProcessingStatus, reason string) error {
+	original, err := getOriginal(txn, solved.String())
+	if err != nil || original == "" {
+		return err
+	}
+
+	return Append(txn, original, status, reason)
+}
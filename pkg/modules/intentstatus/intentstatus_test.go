@@ -0,0 +1,95 @@
+package intentstatus
+
+import (
+	"testing"
+
+	"github.com/blndgs/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// TestGetStatusReturnsTransitionsInOrder verifies that every transition recorded for an Intent is returned in
+// the order it happened and that the last one is reported as the current status.
+func TestGetStatusReturnsTransitionsInOrder(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	h := New(db)
+
+	op := testutils.MockValidIntentUserOp()
+	hash := op.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID).String()
+	ctx := modules.NewUserOpHandlerContext(op, []*userop.UserOperation{}, testutils.ValidAddress1, testutils.ChainID)
+	if err := h.RecordReceived()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := db.Update(func(txn storage.Txn) error {
+		if err := Append(txn, hash, model.SentToSolver, "attempt 1 of 5"); err != nil {
+			return err
+		}
+		return Append(txn, hash, model.Solved, "solved by solver")
+	}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	status, err := h.GetStatus(hash)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if status == nil {
+		t.Fatal("got nil, want status")
+	} else if status.Status != model.Solved {
+		t.Fatalf("got status %s, want %s", status.Status, model.Solved)
+	}
+
+	want := []model.ProcessingStatus{model.Received, model.SentToSolver, model.Solved}
+	if len(status.Transitions) != len(want) {
+		t.Fatalf("got %d transitions, want %d", len(status.Transitions), len(want))
+	}
+	for i, s := range want {
+		if status.Transitions[i].Status != s {
+			t.Fatalf("transition %d: got %s, want %s", i, status.Transitions[i].Status, s)
+		}
+	}
+}
+
+// TestGetStatusUnknownHash verifies that a nil status is returned for an unknown userOpHash.
+func TestGetStatusUnknownHash(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+
+	status, err := New(db).GetStatus(testutils.MockHash)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if status != nil {
+		t.Fatalf("got %v, want nil", status)
+	}
+}
+
+// TestRecordOnChain verifies that a solved Intent in a confirmed bundle is recorded as OnChain under the
+// original userOpHash it is aliased to.
+func TestRecordOnChain(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	h := New(db)
+
+	op := testutils.MockValidInitUserOp()
+	op.Signature = append(op.Signature, []byte(testutils.MockIntentJSON)...)
+	solved := op.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID).String()
+	if err := db.Update(func(txn storage.Txn) error {
+		return SetAlias(txn, testutils.MockHash, solved)
+	}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	included := []*userop.UserOperation{op}
+	if err := h.RecordOnChain(testutils.ValidAddress1, testutils.ChainID, common.Hash{}, included); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	status, err := h.GetStatus(testutils.MockHash)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if status == nil || status.Status != model.OnChain {
+		t.Fatalf("got %v, want status %s", status, model.OnChain)
+	}
+}
@@ -0,0 +1,66 @@
+package profit
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/reverts"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// EstimateGasFunc returns the gas a bundle transaction sending the batch to the EntryPoint is expected to use.
+type EstimateGasFunc = func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error)
+
+// EstimateGasByMaxLimits returns an EstimateGasFunc that assumes the bundle transaction uses the sum of the
+// gas limits of every userOp in the batch.
+func EstimateGasByMaxLimits() EstimateGasFunc {
+	return func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error) {
+		gas := big.NewInt(0)
+		for _, op := range batch {
+			gas.Add(gas, getMaxGas(op))
+		}
+		return gas, nil
+	}
+}
+
+// EstimateGasWithEthClient returns an EstimateGasFunc that calls eth_estimateGas with the same calldata the
+// bundle transaction will be sent with. Fees are omitted from the call so that the estimate does not depend on
+// the balance of the given sender. If a userOp in the batch causes a revert, the sum of the gas limits is
+// used instead since the relayer will drop that userOp before sending.
+func EstimateGasWithEthClient(eth *ethclient.Client, from common.Address, beneficiary common.Address) EstimateGasFunc {
+	byMaxLimits := EstimateGasByMaxLimits()
+	return func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error) {
+		data, err := transaction.PackHandleOps(&transaction.Opts{
+			Eth:                  eth,
+			ChainID:              ctx.ChainID,
+			EntryPoint:           ctx.EntryPoint,
+			Batch:                batch,
+			Beneficiary:          beneficiary,
+			Aggregators:          checks.GetAggregators(ctx),
+			AggregatedSignatures: checks.GetAggregatedSignatures(ctx),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		est, err := eth.EstimateGas(context.Background(), ethereum.CallMsg{
+			From: from,
+			To:   &ctx.EntryPoint,
+			Data: data,
+		})
+		if err != nil {
+			if _, foErr := reverts.NewFailedOp(err); foErr == nil {
+				return byMaxLimits(ctx, batch)
+			}
+			return nil, err
+		}
+		return big.NewInt(0).SetUint64(est), nil
+	}
+}
@@ -0,0 +1,76 @@
+package profit
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
+	"github.com/stackup-wallet/stackup-bundler/pkg/optimism/gaspriceoracle"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// GetL1FeeFunc returns the L1 data fee for a bundle transaction sending the batch to the EntryPoint.
+type GetL1FeeFunc = func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error)
+
+// NoopGetL1FeeFunc returns a L1 data fee of 0 for chains that do not charge it separately.
+func NoopGetL1FeeFunc() GetL1FeeFunc {
+	return func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error) {
+		return big.NewInt(0), nil
+	}
+}
+
+// GetL1FeeWithOptimismOracle uses Optimism's Gas Price Oracle precompile to get the L1 data fee of a raw
+// handleOps or handleAggregatedOps transaction for the batch. The oracle expects an unsigned transaction and only the size of the
+// calldata varies between batches, so the nonce and fees are left empty instead of being fetched from the
+// node.
+func GetL1FeeWithOptimismOracle(rpc *rpc.Client, chainID *big.Int) GetL1FeeFunc {
+	pk, _ := crypto.GenerateKey()
+	dummy := crypto.PubkeyToAddress(pk.PublicKey)
+	eth := ethclient.NewClient(rpc)
+	return func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error) {
+		data, err := transaction.PackHandleOps(&transaction.Opts{
+			Eth:                  eth,
+			ChainID:              chainID,
+			EntryPoint:           ctx.EntryPoint,
+			Batch:                batch,
+			Beneficiary:          dummy,
+			Aggregators:          checks.GetAggregators(ctx),
+			AggregatedSignatures: checks.GetAggregatedSignatures(ctx),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := types.NewTx(&types.DynamicFeeTx{
+			ChainID: chainID,
+			To:      &ctx.EntryPoint,
+			Data:    data,
+		}).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		ge, err := gaspriceoracle.GetL1FeeMethod.Inputs.Pack(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		req := map[string]any{
+			"from": common.HexToAddress("0x"),
+			"to":   gaspriceoracle.PrecompileAddress,
+			"data": hexutil.Encode(append(gaspriceoracle.GetL1FeeMethod.ID, ge...)),
+		}
+		var out any
+		if err := rpc.Call(&out, "eth_call", &req, "latest"); err != nil {
+			return nil, err
+		}
+		return gaspriceoracle.DecodeGetL1FeeMethodOutput(out)
+	}
+}
@@ -0,0 +1,165 @@
+// Package profit implements a module for checking that a bundle pays for its own transaction cost.
+package profit
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+const (
+	// MarginKey is the key in the BatchHandlerCtx Data field for the estimated profit margin of the bundle.
+	MarginKey = "profit_margin"
+
+	// PostponedKey is the key in the BatchHandlerCtx Data field for the userOpHashes that were left in the
+	// mempool to make the bundle profitable.
+	PostponedKey = "profit_postponed_userop_hashes"
+)
+
+// Guard estimates the profit of each bundle and postpones userOps that make it unprofitable.
+type Guard struct {
+	minMargin   float64
+	getL1Fee    GetL1FeeFunc
+	estimateGas EstimateGasFunc
+}
+
+// New returns a Guard that requires every bundle to have at least the given profit margin. The margin is the
+// expected revenue of the beneficiary over the transaction cost minus one (e.g. 0.1 requires a revenue 10%
+// higher than the cost).
+func New(minMargin float64) *Guard {
+	return &Guard{
+		minMargin:   minMargin,
+		getL1Fee:    NoopGetL1FeeFunc(),
+		estimateGas: EstimateGasByMaxLimits(),
+	}
+}
+
+// SetGetL1FeeFunc defines the function used to get the L1 data fee of a bundle transaction on rollups that
+// charge it separately from the L2 gas.
+func (g *Guard) SetGetL1FeeFunc(fn GetL1FeeFunc) {
+	g.getL1Fee = fn
+}
+
+// SetEstimateGasFunc defines the function used to estimate the gas used by a bundle transaction. The default
+// is EstimateGasByMaxLimits.
+func (g *Guard) SetEstimateGasFunc(fn EstimateGasFunc) {
+	g.estimateGas = fn
+}
+
+// getMaxGas returns the maximum gas a userOp can be charged for.
+func getMaxGas(op *userop.UserOperation) *big.Int {
+	gas := big.NewInt(0).Add(op.PreVerificationGas, op.VerificationGasLimit)
+	return gas.Add(gas, op.CallGasLimit)
+}
+
+// getOpGasPrice returns the gas price a userOp pays to the beneficiary.
+func getOpGasPrice(ctx *modules.BatchHandlerCtx, op *userop.UserOperation) *big.Int {
+	if ctx.BaseFee != nil && ctx.BaseFee.Cmp(common.Big0) != 0 && ctx.Tip != nil {
+		return op.GetDynamicGasPrice(ctx.BaseFee)
+	}
+	return op.MaxFeePerGas
+}
+
+// getTxGasPrice returns the effective gas price of the bundle transaction using the same fees the
+// transaction will be sent with.
+func getTxGasPrice(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) *big.Int {
+	if ctx.BaseFee != nil && ctx.BaseFee.Cmp(common.Big0) != 0 && ctx.Tip != nil {
+		tip := transaction.SuggestMeanGasTipCap(ctx.Tip, batch)
+		feeCap := transaction.SuggestMeanGasFeeCap(ctx.BaseFee, ctx.Tip, batch)
+		gp := big.NewInt(0).Add(ctx.BaseFee, tip)
+		if gp.Cmp(feeCap) > 0 {
+			return feeCap
+		}
+		return gp
+	}
+	if ctx.GasPrice != nil {
+		return transaction.SuggestMeanGasPrice(ctx.GasPrice, batch)
+	}
+	return big.NewInt(0)
+}
+
+// calcMargin returns the expected revenue and cost of sending the batch and the resulting margin. The cost is
+// the estimated gas of the bundle transaction at its effective gas price plus the L1 data fee. Since userOps
+// are charged for the gas they actually use, the revenue at the gas limits of each userOp is scaled down to
+// the estimate. It is never scaled up since a userOp cannot pay for more gas than its limits.
+func (g *Guard) calcMargin(
+	ctx *modules.BatchHandlerCtx,
+	batch []*userop.UserOperation,
+) (revenue *big.Int, cost *big.Int, margin float64, err error) {
+	revenue = big.NewInt(0)
+	maxGas := big.NewInt(0)
+	for _, op := range batch {
+		opGas := getMaxGas(op)
+		revenue.Add(revenue, big.NewInt(0).Mul(getOpGasPrice(ctx, op), opGas))
+		maxGas.Add(maxGas, opGas)
+	}
+
+	est, err := g.estimateGas(ctx, batch)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if est.Cmp(maxGas) < 0 {
+		revenue.Mul(revenue, est)
+		revenue.Div(revenue, maxGas)
+	}
+
+	l1Fee, err := g.getL1Fee(ctx, batch)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	cost = big.NewInt(0).Mul(getTxGasPrice(ctx, batch), est)
+	cost.Add(cost, l1Fee)
+
+	if cost.Sign() == 0 {
+		return revenue, cost, 0, nil
+	}
+	r, _ := new(big.Float).SetInt(revenue).Float64()
+	c, _ := new(big.Float).SetInt(cost).Float64()
+	return revenue, cost, r/c - 1, nil
+}
+
+// getLowestPaying returns the index of the userOp with the lowest gas price in the batch.
+func getLowestPaying(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) int {
+	idx := 0
+	for i, op := range batch {
+		if getOpGasPrice(ctx, op).Cmp(getOpGasPrice(ctx, batch[idx])) < 0 {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// CheckMargin returns a BatchHandlerFunc that estimates the profit margin of the batch. While the margin is
+// below the minimum, the userOp paying the lowest gas price is postponed by leaving it in the mempool for a
+// later batch. The final margin is reported under MarginKey and the postponed userOps under PostponedKey.
+func (g *Guard) CheckMargin() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		batch := append([]*userop.UserOperation{}, ctx.Batch...)
+		postponed := []string{}
+		for len(batch) > 0 {
+			_, _, margin, err := g.calcMargin(ctx, batch)
+			if err != nil {
+				return err
+			}
+
+			ctx.Data[MarginKey] = margin
+			if margin >= g.minMargin {
+				break
+			}
+
+			i := getLowestPaying(ctx, batch)
+			postponed = append(postponed, batch[i].GetUserOpHash(ctx.EntryPoint, ctx.ChainID).String())
+			batch = append(batch[:i], batch[i+1:]...)
+		}
+
+		ctx.Batch = batch
+		if len(postponed) > 0 {
+			ctx.Data[PostponedKey] = postponed
+		}
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+package profit
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+func newPricedOp(nonce int64, tip int64) *userop.UserOperation {
+	op := testutils.MockValidInitUserOp()
+	op.Nonce = big.NewInt(nonce)
+	op.PreVerificationGas = big.NewInt(10000)
+	op.VerificationGasLimit = big.NewInt(10000)
+	op.CallGasLimit = big.NewInt(80000)
+	op.MaxPriorityFeePerGas = big.NewInt(tip)
+	op.MaxFeePerGas = big.NewInt(100 + tip)
+	return op
+}
+
+func newPricedBatchCtx(tip int64, ops ...*userop.UserOperation) *modules.BatchHandlerCtx {
+	return modules.NewBatchHandlerContext(
+		ops,
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		big.NewInt(100),
+		big.NewInt(tip),
+		big.NewInt(100+tip),
+	)
+}
+
+func fixedL1Fee(fee int64) GetL1FeeFunc {
+	return func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error) {
+		return big.NewInt(fee), nil
+	}
+}
+
+// TestCheckMarginKeepsProfitableBatch verifies that a batch that pays for its own transaction is unchanged
+// and the margin is reported.
+func TestCheckMarginKeepsProfitableBatch(t *testing.T) {
+	ctx := newPricedBatchCtx(1, newPricedOp(0, 1), newPricedOp(1, 1))
+
+	if err := New(0).CheckMargin()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 2 {
+		t.Fatalf("got batch length %d, want 2", len(ctx.Batch))
+	} else if margin, ok := ctx.Data[MarginKey].(float64); !ok || margin != 0 {
+		t.Fatalf("got margin %v, want 0", ctx.Data[MarginKey])
+	} else if _, ok := ctx.Data[PostponedKey]; ok {
+		t.Fatal("got postponed userOps, want none")
+	}
+}
+
+// TestCheckMarginPostponesLowestPaying verifies that the userOp paying the lowest gas price is postponed
+// when the suggested tip of the node is above the mean tip of the batch, without marking it for removal from
+// the mempool.
+func TestCheckMarginPostponesLowestPaying(t *testing.T) {
+	low := newPricedOp(0, 1)
+	high := newPricedOp(1, 50)
+	ctx := newPricedBatchCtx(30, low, high)
+
+	if err := New(0).CheckMargin()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 || ctx.Batch[0] != high {
+		t.Fatalf("got batch length %d, want only the highest paying op", len(ctx.Batch))
+	} else if len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("got pending removal length %d, want 0", len(ctx.PendingRemoval))
+	} else if margin, ok := ctx.Data[MarginKey].(float64); !ok || margin < 0 {
+		t.Fatalf("got margin %v, want >= 0", ctx.Data[MarginKey])
+	}
+}
+
+// TestCheckMarginPostponesUnprofitableBatch verifies that all userOps are postponed if the batch can never
+// cover the L1 data fee.
+func TestCheckMarginPostponesUnprofitableBatch(t *testing.T) {
+	ctx := newPricedBatchCtx(1, newPricedOp(0, 1))
+
+	g := New(0)
+	g.SetGetL1FeeFunc(fixedL1Fee(1000))
+	if err := g.CheckMargin()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 0 {
+		t.Fatalf("got batch length %d, want 0", len(ctx.Batch))
+	} else if postponed, _ := ctx.Data[PostponedKey].([]string); len(postponed) != 1 {
+		t.Fatalf("got %d postponed userOps, want 1", len(postponed))
+	}
+}
+
+// TestCheckMarginChargesEstimatedGas verifies that the cost of the batch uses the estimated gas of the bundle
+// transaction, so that a batch with gas limits too low to cover the transaction is postponed.
+func TestCheckMarginChargesEstimatedGas(t *testing.T) {
+	ctx := newPricedBatchCtx(1, newPricedOp(0, 1))
+
+	g := New(0)
+	g.SetEstimateGasFunc(func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error) {
+		return big.NewInt(200000), nil
+	})
+	if err := g.CheckMargin()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 0 {
+		t.Fatalf("got batch length %d, want 0", len(ctx.Batch))
+	}
+
+	ctx = newPricedBatchCtx(1, newPricedOp(0, 1))
+	g.SetEstimateGasFunc(func(ctx *modules.BatchHandlerCtx, batch []*userop.UserOperation) (*big.Int, error) {
+		return big.NewInt(50000), nil
+	})
+	if err := g.CheckMargin()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	}
+}
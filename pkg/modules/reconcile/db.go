@@ -0,0 +1,161 @@
+package reconcile
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/goccy/go-json"
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+var (
+	keyPrefix = dbutils.JoinValues("reconcile")
+)
+
+// bundle is the persisted state of a submitted bundle transaction. TxHash is the first transaction sent for
+// the bundle and TxHashes includes it along with every replacement sent with the same nonce. Infos holds the
+// mempool OpInfo of each userOp in Ops at the same index.
+type bundle struct {
+	TxHash      common.Hash       `json:"txHash"`
+	TxHashes    []common.Hash     `json:"txHashes"`
+	EntryPoint  common.Address    `json:"entryPoint"`
+	Ops         []json.RawMessage `json:"ops"`
+	Infos       []opInfo          `json:"infos,omitempty"`
+	SubmittedAt int64             `json:"submittedAt"`
+	BlockNumber uint64            `json:"blockNumber"`
+	BlockHash   common.Hash       `json:"blockHash"`
+}
+
+// opInfo is the persisted form of a mempool OpInfo as unix timestamps. A zero value is not set.
+type opInfo struct {
+	AdmittedAt int64 `json:"admittedAt,omitempty"`
+	ValidUntil int64 `json:"validUntil,omitempty"`
+}
+
+func newOpInfo(info mempool.OpInfo) opInfo {
+	i := opInfo{}
+	if !info.AdmittedAt.IsZero() {
+		i.AdmittedAt = info.AdmittedAt.Unix()
+	}
+	if !info.ValidUntil.IsZero() {
+		i.ValidUntil = info.ValidUntil.Unix()
+	}
+	return i
+}
+
+func (i opInfo) toMempool() mempool.OpInfo {
+	info := mempool.OpInfo{}
+	if i.AdmittedAt != 0 {
+		info.AdmittedAt = time.Unix(i.AdmittedAt, 0)
+	}
+	if i.ValidUntil != 0 {
+		info.ValidUntil = time.Unix(i.ValidUntil, 0)
+	}
+	return info
+}
+
+func getBundleKey(txHash common.Hash) []byte {
+	return []byte(dbutils.JoinValues(keyPrefix, txHash.String()))
+}
+
+func newBundle(
+	txHashes []common.Hash,
+	ep common.Address,
+	ops []*userop.UserOperation,
+	infos []mempool.OpInfo,
+	now int64,
+) (*bundle, error) {
+	b := &bundle{
+		TxHash:      txHashes[0],
+		TxHashes:    txHashes,
+		EntryPoint:  ep,
+		Ops:         []json.RawMessage{},
+		Infos:       []opInfo{},
+		SubmittedAt: now,
+	}
+	for i, op := range ops {
+		data, err := op.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		b.Ops = append(b.Ops, data)
+		b.Infos = append(b.Infos, newOpInfo(infos[i]))
+	}
+	return b, nil
+}
+
+// getTxHashes returns the hashes of all transactions sent for the bundle. Bundles persisted before
+// replacements were tracked only have TxHash.
+func (b *bundle) getTxHashes() []common.Hash {
+	if len(b.TxHashes) == 0 {
+		return []common.Hash{b.TxHash}
+	}
+	return b.TxHashes
+}
+
+// hasTx returns true if the transaction was sent for the bundle.
+func (b *bundle) hasTx(txHash common.Hash) bool {
+	for _, h := range b.getTxHashes() {
+		if h == txHash {
+			return true
+		}
+	}
+	return false
+}
+
+// getOpInfo returns the mempool OpInfo of the userOp at index i. Bundles persisted before OpInfo was tracked
+// return a zero value.
+func (b *bundle) getOpInfo(i int) mempool.OpInfo {
+	if i >= len(b.Infos) {
+		return mempool.OpInfo{}
+	}
+	return b.Infos[i].toMempool()
+}
+
+// getOps decodes the userOps that were sent in the bundle.
+func (b *bundle) getOps() ([]*userop.UserOperation, error) {
+	ops := []*userop.UserOperation{}
+	for _, data := range b.Ops {
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		op, err := userop.New(m)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func putBundle(txn storage.Txn, b *bundle) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return txn.Set(getBundleKey(b.TxHash), data)
+}
+
+func deleteBundle(txn storage.Txn, txHash common.Hash) error {
+	return txn.Delete(getBundleKey(txHash))
+}
+
+func getAllBundles(txn storage.Txn) ([]*bundle, error) {
+	bundles := []*bundle{}
+	err := txn.Iterate([]byte(dbutils.JoinValues(keyPrefix, "")), func(key []byte, value []byte) error {
+		var b bundle
+		if err := json.Unmarshal(value, &b); err != nil {
+			return err
+		}
+		bundles = append(bundles, &b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
@@ -0,0 +1,436 @@
+// Package reconcile implements a module that tracks submitted bundle transactions across blocks and puts the
+// userOps of a bundle back into the mempool if it is dropped, fails, or is reorged away.
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+var (
+	DefaultConfirmations uint64 = 12
+	DefaultDropTimeout          = 5 * time.Minute
+	DefaultInterval             = 12 * time.Second
+)
+
+// ReAddFunc puts a userOp from a failed bundle back into the mempool after validating it again. The OpInfo
+// the userOp had in the mempool when the bundle was sent is given so that its age is kept.
+type ReAddFunc = func(ep common.Address, op *userop.UserOperation, info mempool.OpInfo) error
+
+func noopReAdd(ep common.Address, op *userop.UserOperation, info mempool.OpInfo) error {
+	return nil
+}
+
+// GetOpInfoFunc returns the OpInfo of a pending userOp in the mempool and false if it is not found.
+type GetOpInfoFunc = func(ep common.Address, op *userop.UserOperation) (mempool.OpInfo, bool)
+
+func noopGetOpInfo(ep common.Address, op *userop.UserOperation) (mempool.OpInfo, bool) {
+	return mempool.OpInfo{}, false
+}
+
+// ConfirmedFunc is called with the userOps that emitted a UserOperationEvent once the transaction of a bundle
+// has enough confirmations.
+type ConfirmedFunc = func(
+	ep common.Address,
+	chainID *big.Int,
+	txHash common.Hash,
+	included []*userop.UserOperation,
+) error
+
+func noopConfirmed(ep common.Address, chainID *big.Int, txHash common.Hash, included []*userop.UserOperation) error {
+	return nil
+}
+
+// Reconciler confirms that the userOps of every submitted bundle transaction have been included on-chain.
+// A bundle is only forgotten once its receipt has enough confirmations and every userOp has emitted a
+// UserOperationEvent. Otherwise, the missing userOps are passed to a ReAddFunc.
+type Reconciler struct {
+	db            storage.Store
+	eth           *ethclient.Client
+	chainID       *big.Int
+	confirmations uint64
+	dropTimeout   time.Duration
+	interval      time.Duration
+	reAdd         ReAddFunc
+	getOpInfo     GetOpInfoFunc
+	confirmed     ConfirmedFunc
+	logger        logr.Logger
+	isRunning     bool
+	done          chan bool
+	stop          func()
+}
+
+// New returns a Reconciler that persists the submitted bundles in the given Store.
+func New(db storage.Store, eth *ethclient.Client, chainID *big.Int) *Reconciler {
+	return &Reconciler{
+		db:            db,
+		eth:           eth,
+		chainID:       chainID,
+		confirmations: DefaultConfirmations,
+		dropTimeout:   DefaultDropTimeout,
+		interval:      DefaultInterval,
+		reAdd:         noopReAdd,
+		getOpInfo:     noopGetOpInfo,
+		confirmed:     noopConfirmed,
+		logger:        logger.NewZeroLogr().WithName("reconciler"),
+		isRunning:     false,
+		done:          make(chan bool),
+		stop:          func() {},
+	}
+}
+
+// SetConfirmations defines the number of blocks a bundle transaction must be included for before it is
+// considered final. The default value is 12.
+func (r *Reconciler) SetConfirmations(confirmations uint64) {
+	r.confirmations = confirmations
+}
+
+// SetDropTimeout defines the time after which a bundle transaction that is neither pending nor included is
+// considered dropped. The default value is 5 minutes.
+func (r *Reconciler) SetDropTimeout(timeout time.Duration) {
+	r.dropTimeout = timeout
+}
+
+// SetInterval defines the wait between each check of the submitted bundles. The default value is 12 seconds.
+func (r *Reconciler) SetInterval(interval time.Duration) {
+	r.interval = interval
+}
+
+// SetReAddFunc defines the function used to validate userOps again and put them back into the mempool.
+func (r *Reconciler) SetReAddFunc(fn ReAddFunc) {
+	r.reAdd = fn
+}
+
+// SetGetOpInfoFunc defines the function used to read the mempool OpInfo of each userOp when a bundle is
+// tracked. The OpInfo is passed back to the ReAddFunc if the userOp is re-added.
+func (r *Reconciler) SetGetOpInfoFunc(fn GetOpInfoFunc) {
+	r.getOpInfo = fn
+}
+
+// SetConfirmedFunc defines the functions called in order with the included userOps of every confirmed bundle.
+// The bundle is checked again in the next run if any of them returns an error.
+func (r *Reconciler) SetConfirmedFunc(fns ...ConfirmedFunc) {
+	r.confirmed = func(
+		ep common.Address,
+		chainID *big.Int,
+		txHash common.Hash,
+		included []*userop.UserOperation,
+	) error {
+		for _, fn := range fns {
+			if err := fn(ep, chainID, txHash, included); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// UseLogger defines the logger object used by the Reconciler instance based on the go-logr/logr interface.
+func (r *Reconciler) UseLogger(logger logr.Logger) {
+	r.logger = logger.WithName("reconciler")
+}
+
+// TrackBatch returns a BatchHandler that records the bundle transaction sent by a previous module for
+// reconciliation. All transactions sent for the bundle are read from "txn_hashes" if set, otherwise only
+// "txn_hash" is tracked. It must be placed after the module that sends the batch and before the userOps are
+// removed from the mempool.
+func (r *Reconciler) TrackBatch() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		txHashes := []common.Hash{}
+		if hashes, ok := ctx.Data["txn_hashes"].([]string); ok {
+			for _, h := range hashes {
+				txHashes = append(txHashes, common.HexToHash(h))
+			}
+		} else if h, ok := ctx.Data["txn_hash"].(string); ok {
+			txHashes = append(txHashes, common.HexToHash(h))
+		}
+		if len(txHashes) == 0 || len(ctx.Batch) == 0 {
+			return nil
+		}
+
+		infos := []mempool.OpInfo{}
+		for _, op := range ctx.Batch {
+			info, _ := r.getOpInfo(ctx.EntryPoint, op)
+			infos = append(infos, info)
+		}
+		b, err := newBundle(txHashes, ctx.EntryPoint, ctx.Batch, infos, time.Now().Unix())
+		if err != nil {
+			return err
+		}
+		return r.db.Update(func(txn storage.Txn) error {
+			return putBundle(txn, b)
+		})
+	}
+}
+
+// TrackReplacement adds a transaction sent with the same nonce as txHash to the bundle that txHash was sent
+// for, so that the bundle is reconciled against whichever of them is included. It is a no-op if txHash is not
+// tracked.
+func (r *Reconciler) TrackReplacement(txHash common.Hash, replacement common.Hash) error {
+	return r.db.Update(func(txn storage.Txn) error {
+		all, err := getAllBundles(txn)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range all {
+			if !b.hasTx(txHash) {
+				continue
+			} else if b.hasTx(replacement) {
+				return nil
+			}
+
+			b.TxHashes = append(b.getTxHashes(), replacement)
+			return putBundle(txn, b)
+		}
+		return nil
+	})
+}
+
+// Process checks all submitted bundles once against the current chain head.
+func (r *Reconciler) Process() error {
+	var bundles []*bundle
+	if err := r.db.View(func(txn storage.Txn) error {
+		all, err := getAllBundles(txn)
+		bundles = all
+		return err
+	}); err != nil {
+		return err
+	}
+	if len(bundles) == 0 {
+		return nil
+	}
+
+	head, err := r.eth.BlockNumber(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, b := range bundles {
+		if err := r.reconcile(b, head); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+func (r *Reconciler) reconcile(b *bundle, head uint64) error {
+	l := r.logger.
+		WithName("run").
+		WithValues("entrypoint", b.EntryPoint.String()).
+		WithValues("chain_id", r.chainID.String()).
+		WithValues("txn_hash", b.TxHash.String())
+
+	receipt, err := r.findReceipt(b)
+	if errors.Is(err, ethereum.NotFound) {
+		pending, err := r.isPending(b)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case pending && b.BlockHash != (common.Hash{}):
+			// The block was reorged away but the transaction is back in the node's mempool.
+			l.Info("bundle reorged back to pending")
+			b.BlockNumber = 0
+			b.BlockHash = common.Hash{}
+			return r.db.Update(func(txn storage.Txn) error {
+				return putBundle(txn, b)
+			})
+		case pending:
+			return nil
+		case b.BlockHash != (common.Hash{}):
+			l.Info("bundle reorged away")
+			return r.reAddAll(b, nil)
+		case time.Since(time.Unix(b.SubmittedAt, 0)) > r.dropTimeout:
+			l.Info("bundle dropped")
+			return r.reAddAll(b, nil)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if receipt.Status == types.ReceiptStatusFailed {
+		l.Info("bundle failed")
+		return r.reAddAll(b, nil)
+	}
+
+	// The bundle is included but not yet final. Keep track of the block so that a reorg can be detected.
+	if head+1 < receipt.BlockNumber.Uint64()+r.confirmations {
+		if b.BlockHash == receipt.BlockHash {
+			return nil
+		}
+
+		b.BlockNumber = receipt.BlockNumber.Uint64()
+		b.BlockHash = receipt.BlockHash
+		return r.db.Update(func(txn storage.Txn) error {
+			return putBundle(txn, b)
+		})
+	}
+
+	included, err := r.getIncludedOps(b.EntryPoint, receipt)
+	if err != nil {
+		return err
+	}
+	l.Info("bundle confirmed", "block_number", receipt.BlockNumber.Uint64(), "mined_txn_hash", receipt.TxHash)
+	if err := r.confirmIncluded(b, receipt.TxHash, included); err != nil {
+		return err
+	}
+	return r.reAddAll(b, included)
+}
+
+// findReceipt returns the receipt of whichever transaction sent for the bundle has been included.
+func (r *Reconciler) findReceipt(b *bundle) (*types.Receipt, error) {
+	for _, txHash := range b.getTxHashes() {
+		receipt, err := r.eth.TransactionReceipt(context.Background(), txHash)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return receipt, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// isPending returns true if the node still knows about any transaction sent for the bundle.
+func (r *Reconciler) isPending(b *bundle) (bool, error) {
+	for _, txHash := range b.getTxHashes() {
+		_, _, err := r.eth.TransactionByHash(context.Background(), txHash)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// confirmIncluded passes the userOps of the bundle that emitted a UserOperationEvent to the ConfirmedFunc.
+func (r *Reconciler) confirmIncluded(b *bundle, txHash common.Hash, included map[common.Hash]bool) error {
+	ops, err := b.getOps()
+	if err != nil {
+		return err
+	}
+
+	confirmed := []*userop.UserOperation{}
+	for _, op := range ops {
+		if included[op.GetUserOpHash(b.EntryPoint, r.chainID)] {
+			confirmed = append(confirmed, op)
+		}
+	}
+	if len(confirmed) == 0 {
+		return nil
+	}
+	return r.confirmed(b.EntryPoint, r.chainID, txHash, confirmed)
+}
+
+// getIncludedOps returns the userOpHashes of all UserOperationEvents emitted by the EntryPoint in the
+// receipt.
+func (r *Reconciler) getIncludedOps(ep common.Address, receipt *types.Receipt) (map[common.Hash]bool, error) {
+	filterer, err := entrypoint.NewEntrypointFilterer(ep, r.eth)
+	if err != nil {
+		return nil, err
+	}
+
+	included := make(map[common.Hash]bool)
+	for _, log := range receipt.Logs {
+		if log.Address != ep {
+			continue
+		}
+
+		ev, err := filterer.ParseUserOperationEvent(*log)
+		if err != nil {
+			// Not a UserOperationEvent.
+			continue
+		}
+		included[ev.UserOpHash] = true
+	}
+	return included, nil
+}
+
+// reAddAll puts every userOp of the bundle that is not included back into the mempool and stops tracking
+// the bundle. UserOps that fail validation again are only logged.
+func (r *Reconciler) reAddAll(b *bundle, included map[common.Hash]bool) error {
+	ops, err := b.getOps()
+	if err != nil {
+		return err
+	}
+
+	readded := []string{}
+	for i, op := range ops {
+		hash := op.GetUserOpHash(b.EntryPoint, r.chainID)
+		if included[hash] {
+			continue
+		}
+
+		if err := r.reAdd(b.EntryPoint, op, b.getOpInfo(i)); err != nil {
+			r.logger.Error(err, "reconciler re-add error", "userop_hash", hash.String())
+			continue
+		}
+		readded = append(readded, hash.String())
+	}
+	if len(readded) > 0 {
+		r.logger.Info("reconciler re-added userOps", "txn_hash", b.TxHash.String(), "userop_hashes", readded)
+	}
+
+	return r.db.Update(func(txn storage.Txn) error {
+		return deleteBundle(txn, b.TxHash)
+	})
+}
+
+// Run starts a goroutine that will continuously reconcile the submitted bundles.
+func (r *Reconciler) Run() error {
+	if r.isRunning {
+		return nil
+	}
+
+	done := make(chan bool)
+	r.done = done
+	ticker := time.NewTicker(r.interval)
+	go func(r *Reconciler) {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := r.Process(); err != nil {
+					r.logger.Error(err, "reconciler run error")
+				}
+			}
+		}
+	}(r)
+
+	r.isRunning = true
+	r.stop = ticker.Stop
+	return nil
+}
+
+// Stop signals the Reconciler to stop continuously reconciling the submitted bundles.
+func (r *Reconciler) Stop() {
+	if !r.isRunning {
+		return
+	}
+
+	r.isRunning = false
+	r.stop()
+	close(r.done)
+}
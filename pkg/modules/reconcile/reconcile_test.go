@@ -0,0 +1,160 @@
+package reconcile
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+func trackMockBatch(t *testing.T, r *Reconciler, op *userop.UserOperation) {
+	ctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{op},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		big.NewInt(1),
+		big.NewInt(1),
+		big.NewInt(1),
+	)
+	ctx.Data["txn_hash"] = testutils.MockHash
+	if err := r.TrackBatch()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+func newReconcilerMock(t *testing.T, head string, receipt any) (*Reconciler, *[]*userop.UserOperation) {
+	db := testutils.StoreMock()
+	t.Cleanup(func() { db.Close() })
+	s := testutils.RpcMock(testutils.MethodMocks{
+		"eth_blockNumber":           head,
+		"eth_getTransactionReceipt": receipt,
+	})
+	t.Cleanup(s.Close)
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	readded := []*userop.UserOperation{}
+	r := New(db, eth, testutils.ChainID)
+	r.SetReAddFunc(func(ep common.Address, op *userop.UserOperation, info mempool.OpInfo) error {
+		readded = append(readded, op)
+		return nil
+	})
+	return r, &readded
+}
+
+// TestProcessReAddsOpsFromFailedBundle verifies that all userOps of a bundle with a failed receipt are put
+// back into the mempool and the bundle is no longer tracked.
+func TestProcessReAddsOpsFromFailedBundle(t *testing.T) {
+	receipt := testutils.NewTransactionReceiptMock()
+	receipt["status"] = "0x0"
+	r, readded := newReconcilerMock(t, "0x1", receipt)
+	op := testutils.MockValidInitUserOp()
+	trackMockBatch(t, r, op)
+
+	for i := 0; i < 2; i++ {
+		if err := r.Process(); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+	if len(*readded) != 1 {
+		t.Fatalf("got %d re-added ops, want 1", len(*readded))
+	} else if !testutils.IsOpsEqual((*readded)[0], op) {
+		t.Fatal("got different re-added op, want tracked op")
+	}
+}
+
+// TestProcessReAddsOpsWithOpInfo verifies that the OpInfo a userOp had in the mempool when the bundle was
+// tracked is passed back when the userOp is re-added.
+func TestProcessReAddsOpsWithOpInfo(t *testing.T) {
+	receipt := testutils.NewTransactionReceiptMock()
+	receipt["status"] = "0x0"
+	r, _ := newReconcilerMock(t, "0x1", receipt)
+	want := mempool.OpInfo{
+		AdmittedAt: time.Unix(1000, 0),
+		ValidUntil: time.Unix(2000, 0),
+	}
+	r.SetGetOpInfoFunc(func(ep common.Address, op *userop.UserOperation) (mempool.OpInfo, bool) {
+		return want, true
+	})
+	got := []mempool.OpInfo{}
+	r.SetReAddFunc(func(ep common.Address, op *userop.UserOperation, info mempool.OpInfo) error {
+		got = append(got, info)
+		return nil
+	})
+	trackMockBatch(t, r, testutils.MockValidInitUserOp())
+
+	if err := r.Process(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d re-added ops, want 1", len(got))
+	} else if !got[0].AdmittedAt.Equal(want.AdmittedAt) || !got[0].ValidUntil.Equal(want.ValidUntil) {
+		t.Fatalf("got info %v, want %v", got[0], want)
+	}
+}
+
+// TestProcessReAddsOpsWithoutEvent verifies that userOps without a UserOperationEvent in a confirmed bundle
+// are put back into the mempool.
+func TestProcessReAddsOpsWithoutEvent(t *testing.T) {
+	r, readded := newReconcilerMock(t, "0x20", testutils.NewTransactionReceiptMock())
+	trackMockBatch(t, r, testutils.MockValidInitUserOp())
+
+	if err := r.Process(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(*readded) != 1 {
+		t.Fatalf("got %d re-added ops, want 1", len(*readded))
+	}
+}
+
+// TestProcessWaitsForConfirmations verifies that a bundle is still tracked until it has enough
+// confirmations.
+func TestProcessWaitsForConfirmations(t *testing.T) {
+	r, readded := newReconcilerMock(t, "0x2", testutils.NewTransactionReceiptMock())
+	trackMockBatch(t, r, testutils.MockValidInitUserOp())
+
+	if err := r.Process(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(*readded) != 0 {
+		t.Fatalf("got %d re-added ops, want 0", len(*readded))
+	}
+
+	r.SetConfirmations(2)
+	if err := r.Process(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(*readded) != 1 {
+		t.Fatalf("got %d re-added ops, want 1", len(*readded))
+	}
+}
+
+// TestProcessFindsReplacementReceipt verifies that a bundle is reconciled against the receipt of a
+// replacement transaction when the first transaction sent for it was never included.
+func TestProcessFindsReplacementReceipt(t *testing.T) {
+	replacement := common.HexToHash("0x1")
+	failed := testutils.NewTransactionReceiptMock()
+	failed["status"] = "0x0"
+	r, readded := newReconcilerMock(t, "0x1", testutils.MethodMockFunc(func(params []any) any {
+		if len(params) > 0 && params[0] == replacement.String() {
+			return failed
+		}
+		return nil
+	}))
+	trackMockBatch(t, r, testutils.MockValidInitUserOp())
+	if err := r.TrackReplacement(common.HexToHash(testutils.MockHash), replacement); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if err := r.Process(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(*readded) != 1 {
+		t.Fatalf("got %d re-added ops, want 1", len(*readded))
+	}
+}
@@ -7,18 +7,28 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/blndgs/model"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/go-logr/logr"
 
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/reverts"
 	"github.com/stackup-wallet/stackup-bundler/pkg/entrypoint/transaction"
+	"github.com/stackup-wallet/stackup-bundler/pkg/errors"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
 	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
+// ReplacedFunc is called with the hash of a bundle transaction and the hash of a replacement or cancellation
+// sent for it with the same nonce.
+type ReplacedFunc = func(txHash common.Hash, replacement common.Hash) error
+
+func noopReplaced(txHash common.Hash, replacement common.Hash) error {
+	return nil
+}
+
 // Relayer provides a module that can relay batches with a regular EOA. Relaying batches to the EntryPoint
 // through a regular transaction comes with several important notes:
 //
@@ -29,151 +39,201 @@ import (
 // propagated through the network and it is impossible to prevent collisions from multiple bundlers trying to
 // relay the same ops.
 type Relayer struct {
-	eoa         *signer.EOA
+	eoas        *signer.Pool
 	eth         *ethclient.Client
 	chainID     *big.Int
 	beneficiary common.Address
 	logger      logr.Logger
 	waitTimeout time.Duration
+	nonces      map[common.Address]*transaction.NonceManager
+	rebroadcast time.Duration
+	replaced    ReplacedFunc
 }
 
-// New initializes a new EOA relayer for sending batches to the EntryPoint.
+// New initializes a new EOA relayer for sending batches to the EntryPoint. Each batch is sent by an idle EOA
+// leased from the pool so that several batches can be pending at once. The EOA stays leased until its
+// transaction has been included or cancelled.
 func New(
-	eoa *signer.EOA,
+	eoas *signer.Pool,
 	eth *ethclient.Client,
 	chainID *big.Int,
 	beneficiary common.Address,
 	l logr.Logger,
 ) *Relayer {
+	nonces := make(map[common.Address]*transaction.NonceManager)
+	for _, eoa := range eoas.EOAs() {
+		nonces[eoa.Account().Address] = transaction.NewNonceManager(eth, eoa.Account().Address)
+	}
+
 	return &Relayer{
-		eoa:         eoa,
+		eoas:        eoas,
 		eth:         eth,
 		chainID:     chainID,
 		beneficiary: beneficiary,
 		logger:      l.WithName("relayer"),
 		waitTimeout: DefaultWaitTimeout,
+		nonces:      nonces,
+		rebroadcast: DefaultRebroadcastInterval,
+		replaced:    noopReplaced,
 	}
 }
 
-// SetWaitTimeout sets the total time to wait for a transaction to be included. When a timeout is reached, the
-// BatchHandler will throw an error if the transaction has not been included or has been included but with a
-// failed status.
+// SetWaitTimeout sets the total time to wait for a transaction to be included. The wait happens in the
+// background after the BatchHandler returns so that the next batch can be sent by another EOA. When a timeout
+// is reached, the transaction is cancelled and the EOA is released once the nonce has been consumed.
 //
-// The default value is 30 seconds. Setting the value to 0 will skip waiting for a transaction to be included.
+// The default value is 72 seconds. Setting the value to 0 will skip waiting for a transaction to be included
+// and release the EOA as soon as the transaction is sent.
 func (r *Relayer) SetWaitTimeout(timeout time.Duration) {
 	r.waitTimeout = timeout
 }
 
+// SetRebroadcastInterval sets the time to wait for a transaction to be included before it is re-broadcasted
+// with bumped fees. A transaction that is still not included by the wait timeout is cancelled with an empty
+// transaction using the same nonce.
+//
+// The default value is 12 seconds. Setting the value to 0 will skip re-broadcasting.
+func (r *Relayer) SetRebroadcastInterval(interval time.Duration) {
+	r.rebroadcast = interval
+}
+
+// SetReplacedFunc defines the function called with every replacement and cancellation sent while waiting for a
+// transaction to be included. This is needed to track replacements that are sent after the batch has been
+// handed to the following modules.
+func (r *Relayer) SetReplacedFunc(fn ReplacedFunc) {
+	r.replaced = fn
+}
+
+// GetNonceManager returns the NonceManager used for bundle transactions sent by the given EOA or nil if the EOA
+// is not in the Pool. Any other transaction sent by the same EOA must use it to avoid nonce collisions.
+func (r *Relayer) GetNonceManager(eoa common.Address) *transaction.NonceManager {
+	return r.nonces[eoa]
+}
+
 // SendUserOperation returns a BatchHandler that is used by the Bundler to send batches in a regular EOA
 // transaction.
 func (r *Relayer) SendUserOperation() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
-		// Filter out UserOperations on HasIntent() result
-		nonIntentsBatch := make([]*userop.UserOperation, 0, len(ctx.Batch))
-		intentsBatch := make([]*userop.UserOperation, 0, len(ctx.Batch))
-		for _, userOp := range ctx.Batch {
-			if userOp.IsSolvedIntent() {
-				// Solved Intent UserOperations
-				intentsBatch = append(intentsBatch, userOp)
-
-			} else if !userOp.HasIntent() {
-				// conventional UserOperation
-				nonIntentsBatch = append(nonIntentsBatch, userOp)
-
-			} else {
-				// Do not send unsolved Intents to the EntryPoint
-				r.logger.WithValues("userOp Hash", userOp.GetUserOpHash(ctx.EntryPoint, ctx.ChainID)).
-					Info("unsolved intent not sent to entrypoint")
-			}
+		eoa, release, err := r.eoas.Lease()
+		if err == signer.ErrNoIdleEOA {
+			// Every EOA has a bundle in flight or is below the minimum balance. The batch is postponed to a
+			// later run without being removed from the mempool.
+			ctx.Batch = []*userop.UserOperation{}
+			ctx.Data["relayer_postponed"] = err.Error()
+			return nil
+		} else if err != nil {
+			return err
 		}
-
-		// Only proceed if there are conventional UserOperations to process
-		if len(nonIntentsBatch) > 0 {
-			opts := r.getCallOptions(ctx, nonIntentsBatch)
-
-			// Estimate gas for handleOps() and drop all userOps that cause unexpected reverts.
-			estRev := []string{}
-			for len(nonIntentsBatch) > 0 {
-				est, revert, err := transaction.EstimateHandleOpsGas(&opts)
-
-				if err != nil {
-					return err
-				} else if revert != nil {
-					ctx.MarkOpIndexForRemoval(revert.OpIndex)
-					estRev = append(estRev, revert.Reason)
-				} else {
-					opts.GasLimit = est
-					break
-				}
+		pending := false
+		defer func() {
+			if !pending {
+				release()
 			}
-			ctx.Data["relayer_est_revert_reasons"] = estRev
+		}()
+		ctx.Data["relayer_eoa"] = eoa.Account().Address.String()
 
-			// Call handleOps() with gas estimate. Any userOps that cause a revert at this stage will be
-			// caught and dropped in the next iteration.
-			if err := handleOps(ctx, opts); err != nil {
+		// Conventional userOps and solved Intents are sent together in a single handleOps() call. Unsolved
+		// Intents are never included in the batch by the Bundler.
+		opts := r.getCallOptions(ctx, eoa, ctx.Batch)
+
+		// Estimate gas for handleOps() and drop all userOps that cause unexpected reverts.
+		estRev := []string{}
+		for len(ctx.Batch) > 0 {
+			opts.Batch = ctx.Batch
+			est, revert, err := transaction.EstimateHandleOpsGas(&opts)
+
+			if err != nil {
 				return err
+			} else if revert != nil {
+				if op := ctx.Batch[revert.OpIndex]; op.HasIntent() {
+					checks.AddSimulationFailure(
+						ctx,
+						op,
+						errors.NewRPCError(errors.REJECTED_BY_EP_OR_ACCOUNT, revert.Reason, revert),
+					)
+				}
+				ctx.MarkOpIndexForRemoval(revert.OpIndex)
+				estRev = append(estRev, revert.Reason)
+			} else {
+				opts.GasLimit = est
+				break
 			}
+		}
+		ctx.Data["relayer_est_revert_reasons"] = estRev
 
+		// No need to continue if the batch size is 0. Otherwise we would just be sending empty batches.
+		if len(ctx.Batch) == 0 {
 			return nil
-		} // end of sending conventional userOps
-
-		if len(intentsBatch) > 0 {
-			opts := r.getCallOptions(ctx, intentsBatch)
-			fmt.Println()
-			for _, op := range intentsBatch {
-				// cast to print it
-				operation := model.UserOperation(*op)
-				fmt.Println(operation.String())
-			}
-			fmt.Println()
-			fmt.Println("--> handleOps")
+		}
 
-			err := handleOps(ctx, opts)
-			if err != nil {
-				fmt.Println("error:", err.Error())
-				fo, foErr := reverts.NewFailedOp(err)
-				if foErr != nil {
-					fmt.Printf("foErr:%+v\n", foErr)
-				}
-				if fo != nil {
-					fmt.Println("EVM Reason:", fo.Reason)
-				}
-				fmt.Printf("\n")
-				// Not sure if it's effective to return an error
-				// And keep recycling attempts to submit a likely
-				// invalid userOp.
-				// return apperrors.NewRPCError(apperrors.REJECTED_BY_EP_OR_ACCOUNT, fo.Reason, fo)
+		// Call handleOps() with gas estimate. Any userOps that cause a revert at this stage will be caught and
+		// dropped in the next iteration. The transaction is only sent here and waited on in the background.
+		sent := []string{}
+		opts.OnSend = func(txn *types.Transaction) {
+			sent = append(sent, txn.Hash().String())
+			ctx.Data["txn_hashes"] = sent
+		}
+		opts.WaitTimeout = 0
+		txn, err := transaction.HandleOps(&opts)
+		if err != nil {
+			if fo, foErr := reverts.NewFailedOp(err); foErr == nil {
+				return fmt.Errorf("relayer: handleOps reverted on op %d: %s: %w", fo.OpIndex, fo.Reason, err)
 			}
+			return err
 		}
+		ctx.Data["txn_hash"] = txn.Hash().String()
 
+		if r.waitTimeout > 0 {
+			pending = true
+			go r.waitForReceipt(txn, opts, release)
+		}
 		return nil
 	}
 }
 
-func handleOps(ctx *modules.BatchHandlerCtx, opts transaction.Opts) error {
-	if txn, err := transaction.HandleOps(&opts); err != nil {
-		return err
-	} else {
-		ctx.Data["txn_hash"] = txn.Hash().String()
-		fmt.Println("txn_hash:", txn.Hash().String())
+// waitForReceipt blocks until txn or one of its replacements has been included or cancelled and then releases
+// the EOA. Every replacement is passed to the ReplacedFunc so that the bundle can be reconciled against
+// whichever transaction is included.
+func (r *Relayer) waitForReceipt(txn *types.Transaction, opts transaction.Opts, release func()) {
+	defer release()
+	l := r.logger.WithValues("relayer_eoa", opts.EOA.Account().Address.String(), "txn_hash", txn.Hash().String())
+
+	opts.WaitTimeout = r.waitTimeout
+	opts.OnSend = func(rep *types.Transaction) {
+		if err := r.replaced(txn.Hash(), rep.Hash()); err != nil {
+			l.Error(err, "relayer failed to track replacement", "replacement_txn_hash", rep.Hash().String())
+		}
 	}
-
-	return nil
+	mined, err := transaction.WaitOrReplace(txn, &opts)
+	if err != nil {
+		l.Error(err, "relayer bundle not included")
+		return
+	}
+	l.Info("relayer bundle included", "mined_txn_hash", mined.Hash().String())
 }
 
-func (r *Relayer) getCallOptions(ctx *modules.BatchHandlerCtx, intentsBatch []*userop.UserOperation) transaction.Opts {
+func (r *Relayer) getCallOptions(
+	ctx *modules.BatchHandlerCtx,
+	eoa signer.Signer,
+	batch []*userop.UserOperation,
+) transaction.Opts {
 	opts := transaction.Opts{
-		EOA:         r.eoa,
-		Eth:         r.eth,
-		ChainID:     ctx.ChainID,
-		EntryPoint:  ctx.EntryPoint,
-		Batch:       intentsBatch,
-		Beneficiary: r.beneficiary,
-		BaseFee:     ctx.BaseFee,
-		Tip:         ctx.Tip,
-		GasPrice:    ctx.GasPrice,
-		GasLimit:    0,
-		WaitTimeout: r.waitTimeout,
+		EOA:                  eoa,
+		Eth:                  r.eth,
+		ChainID:              ctx.ChainID,
+		EntryPoint:           ctx.EntryPoint,
+		Batch:                batch,
+		Beneficiary:          r.beneficiary,
+		Aggregators:          checks.GetAggregators(ctx),
+		AggregatedSignatures: checks.GetAggregatedSignatures(ctx),
+		BaseFee:              ctx.BaseFee,
+		Tip:                  ctx.Tip,
+		GasPrice:             ctx.GasPrice,
+		GasLimit:             0,
+		WaitTimeout:          r.waitTimeout,
+
+		Nonces:              r.nonces[eoa.Account().Address],
+		RebroadcastInterval: r.rebroadcast,
 	}
 	return opts
 }
@@ -0,0 +1,167 @@
+package relay
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/checks"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// failedOpData returns the hex encoded revert data of FailedOp(opIndex, reason).
+func failedOpData(t *testing.T, opIndex int64, reason string) string {
+	uint256, _ := abi.NewType("uint256", "", nil)
+	str, _ := abi.NewType("string", "", nil)
+	args, err := abi.Arguments{{Type: uint256}, {Type: str}}.Pack(big.NewInt(opIndex), reason)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	return hexutil.Encode(append(crypto.Keccak256([]byte("FailedOp(uint256,string)"))[:4], args...))
+}
+
+// estimateRevertMock returns a node that reverts the first estimate of handleOps with the given revert data
+// and accepts the transaction afterwards. The number of sent transactions is counted in sent.
+func estimateRevertMock(data string, sent *int) *httptest.Server {
+	estimates := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			panic(err)
+		}
+
+		res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_estimateGas":
+			estimates++
+			if estimates == 1 {
+				res["error"] = map[string]any{"code": 3, "message": "execution reverted", "data": data}
+			} else {
+				res["result"] = "0x5208"
+			}
+		case "eth_getBlockByNumber":
+			res["result"] = testutils.NewBlockMock()
+		case "eth_gasPrice", "eth_getBalance":
+			res["result"] = "0x1"
+		case "eth_getTransactionCount":
+			res["result"] = "0x0"
+		case "eth_sendRawTransaction":
+			*sent++
+			res["result"] = testutils.MockHash
+		default:
+			res["error"] = map[string]any{"code": -32601, "message": "method not in mocks: " + req.Method}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(err)
+		}
+	}))
+}
+
+// TestSendUserOperationWithMixedBatch verifies that conventional userOps and solved Intents are sent in a
+// single transaction and that a solved Intent reverting during estimation is dropped and recorded.
+func TestSendUserOperationWithMixedBatch(t *testing.T) {
+	sent := 0
+	s := estimateRevertMock(failedOpData(t, 1, "AA23 reverted"), &sent)
+	defer s.Close()
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	conventional := testutils.MockValidInitUserOp()
+	intent := testutils.MockValidIntentUserOp()
+	intent.Signature = append(intent.Signature, []byte(testutils.MockIntentJSON)...)
+	ctx := modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{conventional, intent},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		big.NewInt(1),
+		big.NewInt(1),
+		big.NewInt(1),
+	)
+
+	r := New(testutils.DummyEOAPool(eth), eth, testutils.ChainID, testutils.DummyEOA.Address, logger.NewZeroLogr())
+	r.SetWaitTimeout(0)
+	if err := r.SendUserOperation()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	failures, _ := ctx.Data[checks.SolvedIntentsSimulationFailuresKey].([]checks.SimulationFailure)
+	if sent != 1 {
+		t.Fatalf("got %d transactions sent, want 1", sent)
+	} else if len(ctx.Batch) != 1 || ctx.Batch[0] != conventional {
+		t.Fatalf("got batch length %d, want conventional op only", len(ctx.Batch))
+	} else if len(ctx.PendingRemoval) != 1 {
+		t.Fatalf("got pending removal length %d, want 1", len(ctx.PendingRemoval))
+	} else if len(failures) != 1 || failures[0].Reason != "AA23 reverted" {
+		t.Fatalf("got failures %v, want AA23 reverted", failures)
+	}
+}
+
+// TestSendUserOperationHoldsLeaseUntilResolved verifies that the handler returns without waiting for the
+// transaction to be mined and that the EOA is not leased for another batch until the wait has resolved.
+func TestSendUserOperationHoldsLeaseUntilResolved(t *testing.T) {
+	var sent atomic.Int32
+	s := testutils.RpcMock(testutils.MethodMocks{
+		"eth_estimateGas":         "0x5208",
+		"eth_getBlockByNumber":    testutils.NewBlockMock(),
+		"eth_gasPrice":            "0x1",
+		"eth_getBalance":          "0x1",
+		"eth_getTransactionCount": "0x0",
+		"eth_sendRawTransaction": testutils.MethodMockFunc(func(params []any) any {
+			sent.Add(1)
+			return testutils.MockHash
+		}),
+	})
+	defer s.Close()
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	newCtx := func() *modules.BatchHandlerCtx {
+		return modules.NewBatchHandlerContext(
+			[]*userop.UserOperation{testutils.MockValidInitUserOp()},
+			testutils.ValidAddress1,
+			testutils.ChainID,
+			big.NewInt(1),
+			big.NewInt(1),
+			big.NewInt(1),
+		)
+	}
+
+	r := New(testutils.DummyEOAPool(eth), eth, testutils.ChainID, testutils.DummyEOA.Address, logger.NewZeroLogr())
+	r.SetWaitTimeout(time.Second)
+	r.SetRebroadcastInterval(0)
+	start := time.Now()
+	if err := r.SendUserOperation()(newCtx()); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if time.Since(start) >= time.Second {
+		t.Fatal("got handler blocked on mining, want immediate return")
+	}
+
+	ctx := newCtx()
+	if err := r.SendUserOperation()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 0 {
+		t.Fatalf("got batch length %d, want 0", len(ctx.Batch))
+	} else if sent.Load() != 1 {
+		t.Fatalf("got %d transactions sent, want 1", sent.Load())
+	}
+}
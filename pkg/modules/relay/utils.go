@@ -5,5 +5,6 @@ import (
 )
 
 var (
-	DefaultWaitTimeout = 72 * time.Second
+	DefaultWaitTimeout         = 72 * time.Second
+	DefaultRebroadcastInterval = 12 * time.Second
 )
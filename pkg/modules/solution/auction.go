@@ -0,0 +1,205 @@
+package solution
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/blndgs/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-logr/logr"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// solverResponse is the outcome of sending a batch to a single Solver.
+type solverResponse struct {
+	solverURL string
+	body      model.BodyOfUserOps
+	err       error
+}
+
+// statusRank orders the processing statuses that can be returned for the same Intent by competing Solvers.
+// Solved always wins. Otherwise a retryable status is preferred over a terminal one since another attempt may
+// still succeed with a different Solver.
+var statusRank = map[model.ProcessingStatus]int{
+	model.Solved:   0,
+	model.Unsolved: 1,
+	model.Received: 2,
+	model.Invalid:  3,
+	model.Expired:  4,
+}
+
+// runAuction sends the same batch to the given Solvers in parallel and merges the responses into a single body with
+// the same sequence as the request. For each Intent, the Solved result with the highest score that passes
+// validation is selected. The URL of the winning Solver for each Solved Intent is also returned. An error is only
+// returned if every Solver failed to respond. The circuit breaker of each Solver is updated with the outcome of
+// its request.
+func (ei *IntentsHandler) runAuction(
+	entryPoint common.Address,
+	chainID *big.Int,
+	solverURLs []string,
+	body model.BodyOfUserOps,
+) (model.BodyOfUserOps, map[opHashID]string, error) {
+	responses := make([]solverResponse, len(solverURLs))
+	var wg sync.WaitGroup
+	for i, solverURL := range solverURLs {
+		wg.Add(1)
+		go func(i int, solverURL string) {
+			defer wg.Done()
+			res, err := ei.sendToSolver(solverURL, body)
+			responses[i] = solverResponse{solverURL: solverURL, body: res, err: err}
+		}(i, solverURL)
+	}
+	wg.Wait()
+
+	var errs error
+	ok := []solverResponse{}
+	for _, res := range responses {
+		if res.err != nil {
+			ei.breakers[res.solverURL].recordFailure()
+			errs = errors.Join(errs, res.err)
+			continue
+		}
+		ei.breakers[res.solverURL].recordSuccess()
+		ok = append(ok, res)
+	}
+	if len(ok) == 0 {
+		return body, nil, errs
+	}
+
+	var validate func(op *model.UserOperation) error
+	if ei.validateFn != nil {
+		validate = func(op *model.UserOperation) error {
+			return ei.validateFn(entryPoint, chainID, (*userop.UserOperation)(op))
+		}
+	}
+	best := pickCandidates(rankCandidates(ok, ei.scoreFn), validate, ei.logger)
+	merged, winners := mergeCandidates(body, best)
+	return merged, winners, nil
+}
+
+type candidate struct {
+	solverURL string
+	op        *model.UserOperation
+	status    model.ProcessingStatus
+	score     *big.Int
+}
+
+// rankCandidates returns the candidates for each Intent hash across all Solver responses ordered from best to
+// worst. Solved candidates are ordered by descending score and ties are won by the Solver listed first.
+func rankCandidates(responses []solverResponse, scoreFn ScoreFunc) map[opHashID][]*candidate {
+	ranked := make(map[opHashID][]*candidate)
+	for _, res := range responses {
+		for idx, opExt := range res.body.UserOpsExt {
+			if idx >= len(res.body.UserOps) {
+				break
+			}
+
+			hashID := opHashID(opExt.OriginalHashValue)
+			if _, known := statusRank[opExt.ProcessingStatus]; !known {
+				continue
+			}
+
+			c := &candidate{
+				solverURL: res.solverURL,
+				op:        res.body.UserOps[idx],
+				status:    opExt.ProcessingStatus,
+			}
+			if c.status == model.Solved {
+				c.score = scoreFn(c.op)
+				if c.score == nil {
+					continue
+				}
+			}
+			ranked[hashID] = append(ranked[hashID], c)
+		}
+	}
+
+	for _, candidates := range ranked {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.status == model.Solved && b.status == model.Solved {
+				return a.score.Cmp(b.score) > 0
+			}
+			return statusRank[a.status] < statusRank[b.status]
+		})
+	}
+
+	return ranked
+}
+
+// pickCandidates returns the first candidate for each Intent hash that passes validation. Only Solved candidates
+// are validated and a failure falls back to the next-best candidate, so that a Solver returning an invalid
+// solution with a high score cannot discard the valid solutions of other Solvers. The validations for
+// different Intents run in parallel. A nil validate func accepts every candidate.
+func pickCandidates(
+	ranked map[opHashID][]*candidate,
+	validate func(op *model.UserOperation) error,
+	l logr.Logger,
+) map[opHashID]*candidate {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	best := make(map[opHashID]*candidate)
+	for hashID, candidates := range ranked {
+		wg.Add(1)
+		go func(hashID opHashID, candidates []*candidate) {
+			defer wg.Done()
+			for _, c := range candidates {
+				if c.status == model.Solved && validate != nil {
+					if err := validate(c.op); err != nil {
+						l.Error(
+							err,
+							"solution failed validation",
+							"solver_url", c.solverURL,
+							"userop_hash", string(hashID),
+						)
+						continue
+					}
+				}
+
+				mu.Lock()
+				best[hashID] = c
+				mu.Unlock()
+				return
+			}
+		}(hashID, candidates)
+	}
+	wg.Wait()
+
+	return best
+}
+
+// mergeCandidates builds a response body with the same sequence as the request from the selected candidates.
+// Intents without a candidate keep their request values for another attempt.
+func mergeCandidates(
+	req model.BodyOfUserOps,
+	best map[opHashID]*candidate,
+) (model.BodyOfUserOps, map[opHashID]string) {
+	winners := make(map[opHashID]string)
+	merged := model.BodyOfUserOps{
+		UserOps:    make([]*model.UserOperation, 0, len(req.UserOps)),
+		UserOpsExt: make([]model.UserOperationExt, 0, len(req.UserOpsExt)),
+	}
+	for idx, opExt := range req.UserOpsExt {
+		hashID := opHashID(opExt.OriginalHashValue)
+		c, ok := best[hashID]
+		if !ok {
+			merged.UserOps = append(merged.UserOps, req.UserOps[idx])
+			merged.UserOpsExt = append(merged.UserOpsExt, opExt)
+			continue
+		}
+
+		merged.UserOps = append(merged.UserOps, c.op)
+		merged.UserOpsExt = append(merged.UserOpsExt, model.UserOperationExt{
+			OriginalHashValue: opExt.OriginalHashValue,
+			ProcessingStatus:  c.status,
+		})
+		if c.status == model.Solved {
+			winners[hashID] = c.solverURL
+		}
+	}
+
+	return merged, winners
+}
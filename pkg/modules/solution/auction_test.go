@@ -0,0 +1,134 @@
+package solution
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blndgs/model"
+	"github.com/go-logr/logr"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+func mockSolvedOp(maxFeePerGas int64) *model.UserOperation {
+	op := model.UserOperation(*testutils.MockValidInitUserOp())
+	op.MaxFeePerGas = big.NewInt(maxFeePerGas)
+	return &op
+}
+
+// TestPickCandidatesByScore verifies that the Solved result with the highest score is selected across all
+// Solver responses and that Solved always beats any other status.
+func TestPickCandidatesByScore(t *testing.T) {
+	hash := testutils.MockHash
+	responses := []solverResponse{
+		{
+			solverURL: "unsolved",
+			body: model.BodyOfUserOps{
+				UserOps:    []*model.UserOperation{mockSolvedOp(1)},
+				UserOpsExt: []model.UserOperationExt{{OriginalHashValue: hash, ProcessingStatus: model.Unsolved}},
+			},
+		},
+		{
+			solverURL: "expensive",
+			body: model.BodyOfUserOps{
+				UserOps:    []*model.UserOperation{mockSolvedOp(10)},
+				UserOpsExt: []model.UserOperationExt{{OriginalHashValue: hash, ProcessingStatus: model.Solved}},
+			},
+		},
+		{
+			solverURL: "cheap",
+			body: model.BodyOfUserOps{
+				UserOps:    []*model.UserOperation{mockSolvedOp(5)},
+				UserOpsExt: []model.UserOperationExt{{OriginalHashValue: hash, ProcessingStatus: model.Solved}},
+			},
+		},
+	}
+
+	best := pickCandidates(rankCandidates(responses, ScoreByLowestGasCost()), nil, logr.Discard())
+	if c, ok := best[opHashID(hash)]; !ok {
+		t.Fatal("no candidate selected")
+	} else if c.solverURL != "cheap" {
+		t.Fatalf("got winner %s, want cheap", c.solverURL)
+	} else if c.status != model.Solved {
+		t.Fatalf("got status %s, want %s", c.status, model.Solved)
+	}
+}
+
+// TestPickCandidatesFallsBackOnInvalidSolution verifies that a Solved result failing validation is skipped in
+// favour of the next-best Solved result instead of discarding all solutions for the Intent.
+func TestPickCandidatesFallsBackOnInvalidSolution(t *testing.T) {
+	hash := testutils.MockHash
+	responses := []solverResponse{
+		{
+			solverURL: "valid",
+			body: model.BodyOfUserOps{
+				UserOps:    []*model.UserOperation{mockSolvedOp(10)},
+				UserOpsExt: []model.UserOperationExt{{OriginalHashValue: hash, ProcessingStatus: model.Solved}},
+			},
+		},
+		{
+			solverURL: "invalid",
+			body: model.BodyOfUserOps{
+				UserOps:    []*model.UserOperation{mockSolvedOp(1)},
+				UserOpsExt: []model.UserOperationExt{{OriginalHashValue: hash, ProcessingStatus: model.Solved}},
+			},
+		},
+	}
+	validate := func(op *model.UserOperation) error {
+		if op.MaxFeePerGas.Cmp(big.NewInt(1)) == 0 {
+			return errors.New("simulation failed")
+		}
+		return nil
+	}
+
+	best := pickCandidates(rankCandidates(responses, ScoreByLowestGasCost()), validate, logr.Discard())
+	if c, ok := best[opHashID(hash)]; !ok {
+		t.Fatal("no candidate selected")
+	} else if c.solverURL != "valid" {
+		t.Fatalf("got winner %s, want valid", c.solverURL)
+	}
+
+	best = pickCandidates(rankCandidates(responses[1:], ScoreByLowestGasCost()), validate, logr.Discard())
+	if _, ok := best[opHashID(hash)]; ok {
+		t.Fatal("got candidate, want none")
+	}
+}
+
+// TestRunAuctionWithFailingSolver verifies that a batch is still processed when only some of the Solvers
+// respond and returns an error when none of them do.
+func TestRunAuctionWithFailingSolver(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	calls := 0
+	good := unsolvedSolverMock(&calls)
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	op := model.UserOperation(*testutils.MockValidIntentUserOp())
+	body := model.BodyOfUserOps{
+		UserOps:    []*model.UserOperation{&op},
+		UserOpsExt: []model.UserOperationExt{{OriginalHashValue: testutils.MockHash, ProcessingStatus: model.Received}},
+	}
+
+	ep := testutils.ValidAddress1
+	urls := []string{bad.URL, good.URL}
+	res, _, err := New(db, urls).runAuction(ep, testutils.ChainID, urls, body)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(res.UserOpsExt) != 1 {
+		t.Fatalf("got length %d, want 1", len(res.UserOpsExt))
+	} else if res.UserOpsExt[0].ProcessingStatus != model.Unsolved {
+		t.Fatalf("got status %s, want %s", res.UserOpsExt[0].ProcessingStatus, model.Unsolved)
+	}
+
+	urls = []string{bad.URL}
+	if _, _, err := New(db, urls).runAuction(ep, testutils.ChainID, urls, body); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
@@ -0,0 +1,139 @@
+package solution
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// TimestampHeader carries the unix time at which a request to the Solver was signed.
+	TimestampHeader = "X-Bundler-Timestamp"
+
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of "<timestamp>.<body>" for a request to the
+	// Solver.
+	SignatureHeader = "X-Bundler-Signature"
+
+	// NonceHeader carries a random hex encoded value that is unique to each request to the Solver.
+	NonceHeader = "X-Bundler-Nonce"
+
+	// SolverSignatureHeader carries the hex encoded 65 byte secp256k1 signature of the keccak256 hash of
+	// "<nonce>.<body>" where nonce is the X-Bundler-Nonce of the request and body is the Solver's response
+	// body. Binding the signature to the request prevents a captured response from being replayed.
+	SolverSignatureHeader = "X-Solver-Signature"
+)
+
+var (
+	ErrMissingSolverSignature = errors.New("solver: missing response signature")
+	ErrInvalidSolverSignature = errors.New("solver: invalid response signature")
+)
+
+// SetHMACSecret defines a shared secret used to sign the body of every request to the Solvers. The signature
+// and the time it was created are sent in the X-Bundler-Signature and X-Bundler-Timestamp headers.
+func (ei *IntentsHandler) SetHMACSecret(secret []byte) {
+	ei.hmacSecret = secret
+}
+
+// SetBearerToken defines a token that is sent in the Authorization header of every request to the Solvers.
+func (ei *IntentsHandler) SetBearerToken(token string) {
+	ei.bearerToken = token
+}
+
+// SetSolverPublicKey defines the hex encoded secp256k1 public key used to verify the signature of every
+// response from the Solver at the given URL. Responses without a valid signature are rejected before any
+// solution is applied to the batch.
+func (ei *IntentsHandler) SetSolverPublicKey(solverURL string, publicKey string) error {
+	pub, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	ei.solverKeys[solverURL] = pub
+	return nil
+}
+
+func parsePublicKey(publicKey string) (*ecdsa.PublicKey, error) {
+	b, err := hexutil.Decode(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 33 {
+		return crypto.DecompressPubkey(b)
+	}
+	return crypto.UnmarshalPubkey(b)
+}
+
+// authenticate adds the configured credentials and a unique nonce to a request for the Solver. The nonce is
+// returned to verify the signature of the response.
+func (ei *IntentsHandler) authenticate(req *http.Request, body []byte) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(b)
+	req.Header.Set(NonceHeader, nonce)
+
+	if ei.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+ei.bearerToken)
+	}
+	if len(ei.hmacSecret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, signHMAC(ei.hmacSecret, ts, body))
+	}
+	return nonce, nil
+}
+
+func signHMAC(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func getResponseHash(nonce string, body []byte) []byte {
+	return crypto.Keccak256([]byte(nonce+"."), body)
+}
+
+// verifyResponse checks the signature of a response body from the Solver at the given URL if a public key is
+// configured for it. The signature must be for the nonce of the request that the response answers.
+func (ei *IntentsHandler) verifyResponse(solverURL string, nonce string, header http.Header, body []byte) error {
+	pub, ok := ei.solverKeys[solverURL]
+	if !ok {
+		return nil
+	}
+
+	sigHex := header.Get(SolverSignatureHeader)
+	if sigHex == "" {
+		return ErrMissingSolverSignature
+	}
+	sig, err := hexutil.Decode(sigHex)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return ErrInvalidSolverSignature
+	}
+
+	// Accept both 0/1 and 27/28 recovery ids.
+	sig = append([]byte{}, sig...)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	rec, err := crypto.SigToPub(getResponseHash(nonce, body), sig)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSolverSignature, err)
+	}
+	if crypto.PubkeyToAddress(*rec) != crypto.PubkeyToAddress(*pub) {
+		return ErrInvalidSolverSignature
+	}
+	return nil
+}
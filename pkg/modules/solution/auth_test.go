@@ -0,0 +1,169 @@
+package solution
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blndgs/model"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/goccy/go-json"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/fakesolver"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// signedSolverMock returns a Solver that only accepts requests with the given credentials and signs every
+// Solved response with the given key.
+func signedSolverMock(secret []byte, token string, key *ecdsa.PrivateKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		if (token != "" && r.Header.Get("Authorization") != "Bearer "+token) ||
+			(secret != nil && r.Header.Get(SignatureHeader) != signHMAC(secret, r.Header.Get(TimestampHeader), data)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var body model.BodyOfUserOps
+		if err := json.Unmarshal(data, &body); err != nil {
+			panic(err)
+		}
+		for i := range body.UserOpsExt {
+			body.UserOps[i].CallData = []byte{0xb6, 0x1d, 0x27, 0xf6}
+			body.UserOpsExt[i].ProcessingStatus = model.Solved
+		}
+		res, err := json.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+		sig, err := crypto.Sign(getResponseHash(r.Header.Get(NonceHeader), res), key)
+		if err != nil {
+			panic(err)
+		}
+		w.Header().Set(SolverSignatureHeader, hexutil.Encode(sig))
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(res); err != nil {
+			panic(err)
+		}
+	}))
+}
+
+// TestSolveIntentsWithAuthenticatedSolver verifies that requests carry the configured credentials and a
+// response signed by the configured Solver key is applied to the batch.
+func TestSolveIntentsWithAuthenticatedSolver(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	key, _ := crypto.GenerateKey()
+	s := signedSolverMock([]byte("secret"), "token", key)
+	defer s.Close()
+
+	ei := New(db, []string{s.URL})
+	ei.SetHMACSecret([]byte("secret"))
+	ei.SetBearerToken("token")
+	if err := ei.SetSolverPublicKey(s.URL, hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey))); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	ctx := newIntentBatchCtx(testutils.MockValidIntentUserOp())
+	if err := ei.SolveIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if ctx.Batch[0].IsUnsolvedIntent() {
+		t.Fatal("got unsolved op, want solved op")
+	}
+}
+
+// TestSolveIntentsRejectsUnknownSolverSignature verifies that a response signed by a key other than the
+// configured Solver key is rejected before any solution is applied to the batch.
+func TestSolveIntentsRejectsUnknownSolverSignature(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	s := signedSolverMock(nil, "", other)
+	defer s.Close()
+
+	ei := New(db, []string{s.URL})
+	if err := ei.SetSolverPublicKey(s.URL, hexutil.Encode(crypto.CompressPubkey(&key.PublicKey))); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	ctx := newIntentBatchCtx(testutils.MockValidIntentUserOp())
+	if err := ei.SolveIntents()(ctx); !errors.Is(err, ErrInvalidSolverSignature) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidSolverSignature)
+	} else if !ctx.Batch[0].IsUnsolvedIntent() {
+		t.Fatal("got solved op, want unsolved op")
+	}
+}
+
+// TestSolveIntentsRejectsReplayedSolverSignature verifies that a response signed by the configured Solver key
+// for a different request is rejected.
+func TestSolveIntentsRejectsReplayedSolverSignature(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	key, _ := crypto.GenerateKey()
+	s := signedSolverMock(nil, "", key)
+	defer s.Close()
+
+	// Forward each request to the signing Solver with a different nonce to simulate a replayed response.
+	replay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequest(r.Method, s.URL, r.Body)
+		if err != nil {
+			panic(err)
+		}
+		req.Header.Set(NonceHeader, "stale")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			panic(err)
+		}
+		defer resp.Body.Close()
+
+		w.Header().Set(SolverSignatureHeader, resp.Header.Get(SolverSignatureHeader))
+		w.WriteHeader(resp.StatusCode)
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			panic(err)
+		}
+	}))
+	defer replay.Close()
+
+	ei := New(db, []string{replay.URL})
+	if err := ei.SetSolverPublicKey(replay.URL, hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey))); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	ctx := newIntentBatchCtx(testutils.MockValidIntentUserOp())
+	if err := ei.SolveIntents()(ctx); !errors.Is(err, ErrInvalidSolverSignature) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidSolverSignature)
+	} else if !ctx.Batch[0].IsUnsolvedIntent() {
+		t.Fatal("got solved op, want unsolved op")
+	}
+}
+
+// TestSolveIntentsWithSignedFakeSolver verifies that a response signed by the fake Solver is accepted with the
+// matching public key.
+func TestSolveIntentsWithSignedFakeSolver(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	key, _ := crypto.GenerateKey()
+	s, fs := testutils.FakeSolverMock(&fakesolver.Script{})
+	defer s.Close()
+	fs.SetSigningKey(key)
+
+	ei := New(db, []string{s.URL})
+	if err := ei.SetSolverPublicKey(s.URL, hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey))); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	ctx := newIntentBatchCtx(testutils.MockValidIntentUserOp())
+	if err := ei.SolveIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if ctx.Batch[0].IsUnsolvedIntent() {
+		t.Fatal("got unsolved op, want solved op")
+	}
+}
@@ -0,0 +1,68 @@
+package solution
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/dbutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+)
+
+var (
+	keyPrefix      = dbutils.JoinValues("solution")
+	attemptsPrefix = dbutils.JoinValues(keyPrefix, "attempts")
+)
+
+// attemptsTTL bounds how long an attempt record is kept around. Records for intents that are dropped by
+// other modules (e.g. expiry) are never explicitly removed and are left for the Store to expire.
+const attemptsTTL = 24 * time.Hour
+
+func getAttemptsKey(hash opHashID) []byte {
+	return []byte(dbutils.JoinValues(attemptsPrefix, string(hash)))
+}
+
+func getAttemptsValue(attempts int, lastAttempt time.Time) []byte {
+	return []byte(dbutils.JoinValues(strconv.Itoa(attempts), fmt.Sprint(lastAttempt.Unix())))
+}
+
+func getAttempts(txn storage.Txn, hash opHashID) (attempts int, lastAttempt time.Time, err error) {
+	value, err := txn.Get(getAttemptsKey(hash))
+	if err == storage.ErrKeyNotFound {
+		return 0, time.Time{}, nil
+	} else if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	counts := dbutils.SplitValues(string(value))
+	attempts, err = strconv.Atoi(counts[0])
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	last, err := strconv.ParseInt(counts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return attempts, time.Unix(last, 0), nil
+}
+
+func incrementAttempts(txn storage.Txn, hash opHashID) (int, error) {
+	attempts, _, err := getAttempts(txn, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	value := getAttemptsValue(attempts+1, time.Now())
+	return attempts + 1, txn.SetWithTTL(getAttemptsKey(hash), value, attemptsTTL)
+}
+
+func removeAttempts(txn storage.Txn, hashes ...opHashID) error {
+	for _, hash := range hashes {
+		if err := txn.Delete(getAttemptsKey(hash)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
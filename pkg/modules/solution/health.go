@@ -0,0 +1,207 @@
+package solution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	DefaultFailureThreshold = 3
+	DefaultBreakerCooldown  = 30 * time.Second
+	DefaultHealthInterval   = 10 * time.Second
+)
+
+// breakerState is the state of the circuit breaker for a single Solver.
+type breakerState int64
+
+const (
+	// closed allows batches to be sent to the Solver.
+	closed breakerState = iota
+
+	// halfOpen allows a single batch to be sent to the Solver on trial after a cooldown or a successful health
+	// check. The next failure opens the breaker again.
+	halfOpen
+
+	// open stops batches from being sent to the Solver.
+	open
+)
+
+// circuitBreaker tracks consecutive failures for a Solver and stops sending it batches once a threshold is
+// reached.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	trial     bool
+	threshold int
+	cooldown  time.Duration
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns true if a batch can be sent to the Solver. An open breaker becomes half open once the
+// cooldown has elapsed. A half open breaker only allows one trial at a time and rejects every other caller
+// until the outcome of the trial is recorded or it is released.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == open && time.Since(cb.openedAt) >= cb.cooldown {
+		cb.state = halfOpen
+	}
+	switch cb.state {
+	case closed:
+		return true
+	case halfOpen:
+		if cb.trial {
+			return false
+		}
+		cb.trial = true
+		return true
+	default:
+		return false
+	}
+}
+
+// release ends a trial that was allowed but never sent to the Solver without changing the state.
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+}
+
+// recordSuccess closes the breaker after the Solver successfully responded to a batch.
+func (cb *circuitBreaker) recordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.state = closed
+	cb.failures = 0
+	cb.trial = false
+}
+
+// recordHealthy moves an open breaker to half open after the Solver passed a health check.
+func (cb *circuitBreaker) recordHealthy() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == open {
+		cb.state = halfOpen
+	}
+}
+
+// recordFailure opens the breaker if the threshold for consecutive failures is reached or a trial failed.
+func (cb *circuitBreaker) recordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+	cb.failures++
+	if cb.state == halfOpen || cb.failures >= cb.threshold {
+		cb.state = open
+		cb.openedAt = time.Now()
+	}
+}
+
+func (cb *circuitBreaker) getState() breakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+// availableSolvers returns the Solver URLs with a breaker that allows batches to be sent. Every returned URL
+// must either be sent a batch or released with releaseSolvers.
+func (ei *IntentsHandler) availableSolvers() []string {
+	urls := []string{}
+	for _, solverURL := range ei.SolverURLs {
+		if ei.breakers[solverURL].allow() {
+			urls = append(urls, solverURL)
+		}
+	}
+	return urls
+}
+
+// releaseSolvers ends any trial allowed by availableSolvers for Solvers that were not sent a batch.
+func (ei *IntentsHandler) releaseSolvers(solverURLs []string) {
+	for _, solverURL := range solverURLs {
+		ei.breakers[solverURL].release()
+	}
+}
+
+// CheckSolversHealth polls the /health endpoint of every Solver and updates its circuit breaker. A failed
+// health check counts as a failure and a passed one allows the Solver to be tried again.
+func (ei *IntentsHandler) CheckSolversHealth() error {
+	var errs error
+	for _, solverURL := range ei.SolverURLs {
+		if err := ei.checkSolverHealth(solverURL); err != nil {
+			ei.breakers[solverURL].recordFailure()
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", solverURL, err))
+			continue
+		}
+		ei.breakers[solverURL].recordHealthy()
+	}
+	return errs
+}
+
+func (ei *IntentsHandler) checkSolverHealth(solverURL string) error {
+	parsedURL, err := url.Parse(solverURL)
+	if err != nil {
+		return err
+	}
+
+	parsedURL.Path = "/health"
+	parsedURL.RawQuery = ""
+	parsedURL.Fragment = ""
+
+	reqCtx, cancel := context.WithTimeout(context.Background(), ei.solverTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, parsedURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if _, err := ei.authenticate(req, []byte{}); err != nil {
+		return err
+	}
+
+	resp, err := ei.SolverClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("solver health responded with status: %s", resp.Status)
+	}
+	return nil
+}
+
+// UserMeter defines an opentelemetry meter object used by the IntentsHandler to report the circuit breaker
+// state of each Solver (0: closed, 1: half open, 2: open).
+func (ei *IntentsHandler) UserMeter(meter metric.Meter) error {
+	_, err := meter.Int64ObservableGauge(
+		"solver_circuit_state",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			for _, solverURL := range ei.SolverURLs {
+				io.Observe(
+					int64(ei.breakers[solverURL].getState()),
+					metric.WithAttributes(attribute.String("solver_url", solverURL)),
+				)
+			}
+			return nil
+		}),
+	)
+	return err
+}
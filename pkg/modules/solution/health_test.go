@@ -0,0 +1,101 @@
+package solution
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+)
+
+// TestCircuitBreakerOpensAfterThreshold verifies that a breaker opens after the threshold of consecutive
+// failures and allows a trial once the cooldown has elapsed.
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	cb := newCircuitBreaker(2, time.Hour)
+	cb.recordFailure()
+	if !cb.allow() {
+		t.Fatal("got open breaker, want closed")
+	}
+	cb.recordFailure()
+	if cb.allow() {
+		t.Fatal("got closed breaker, want open")
+	}
+
+	cb.cooldown = 0
+	if !cb.allow() || cb.getState() != halfOpen {
+		t.Fatalf("got state %d, want %d", cb.getState(), halfOpen)
+	}
+	cb.recordFailure()
+	if cb.getState() != open {
+		t.Fatalf("got state %d, want %d", cb.getState(), open)
+	}
+	cb.recordSuccess()
+	if cb.getState() != closed {
+		t.Fatalf("got state %d, want %d", cb.getState(), closed)
+	}
+}
+
+// TestCircuitBreakerAllowsSingleTrial verifies that a half open breaker only allows one trial at a time until
+// its outcome is recorded.
+func TestCircuitBreakerAllowsSingleTrial(t *testing.T) {
+	cb := newCircuitBreaker(1, 0)
+	cb.recordFailure()
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cb.allow() {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Fatalf("got %d trials, want 1", allowed)
+	}
+
+	cb.release()
+	if !cb.allow() {
+		t.Fatal("got rejected trial, want allowed")
+	}
+	cb.recordSuccess()
+	if !cb.allow() || !cb.allow() {
+		t.Fatal("got rejected batch, want closed breaker")
+	}
+}
+
+// TestSolveIntentsSkipsUnhealthySolver verifies that no batch is sent to a Solver that failed its health
+// checks and that the Intents remain in the batch.
+func TestSolveIntentsSkipsUnhealthySolver(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	solveCalls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			solveCalls++
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	ei := New(db, []string{s.URL + "/solve"})
+	ei.SetFailureThreshold(1)
+	if err := ei.CheckSolversHealth(); err == nil {
+		t.Fatal("got nil, want err")
+	}
+
+	ctx := newIntentBatchCtx(testutils.MockValidIntentUserOp())
+	if err := ei.SolveIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if solveCalls != 0 {
+		t.Fatalf("got %d solver calls, want 0", solveCalls)
+	} else if len(ctx.Batch) != 1 || len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	}
+}
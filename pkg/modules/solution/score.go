@@ -0,0 +1,45 @@
+package solution
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/blndgs/model"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// ScoreFunc is a general interface for ranking the solutions returned by competing Solvers for the same
+// Intent userOp. The solution with the highest score wins. A nil score disqualifies the solution.
+type ScoreFunc = func(solved *model.UserOperation) *big.Int
+
+// ValidateFunc is a general interface for checking a solution returned by a Solver before it is ranked as the
+// winner for an Intent userOp. A non-nil error disqualifies the solution.
+type ValidateFunc = func(entryPoint common.Address, chainID *big.Int, solved *userop.UserOperation) error
+
+// GetScoreFunc returns the ScoreFunc for the given name. The only valid name is "gas_cost" for
+// ScoreByLowestGasCost.
+func GetScoreFunc(name string) (ScoreFunc, error) {
+	switch name {
+	case "gas_cost":
+		return ScoreByLowestGasCost(), nil
+	default:
+		return nil, fmt.Errorf("solution: unknown score function: %s", name)
+	}
+}
+
+// ScoreByLowestGasCost returns a ScoreFunc that favours the solution with the lowest max gas cost for the
+// sender (i.e. the sum of all gas limits multiplied by maxFeePerGas).
+func ScoreByLowestGasCost() ScoreFunc {
+	return func(solved *model.UserOperation) *big.Int {
+		if solved.MaxFeePerGas == nil ||
+			solved.CallGasLimit == nil ||
+			solved.VerificationGasLimit == nil ||
+			solved.PreVerificationGas == nil {
+			return nil
+		}
+
+		return big.NewInt(0).Neg(solved.GetMaxPrefund())
+	}
+}
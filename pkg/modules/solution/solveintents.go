@@ -1,36 +1,47 @@
 // Package solution sends the received bundler batch of Intent UserOperations
 // to the Solver to solve the Intent and fill-in the EVM instructions.
 //
-// This implementation makes 1 attempt for each Intent userOp to be solved.
+// Each Intent userOp may be sent to the Solver over several bundler runs. The
+// number of attempts per original userOpHash is persisted in the embedded DB
+// and an exponential backoff is applied between attempts.
 //
-// Solved userOps update the received bundle
-// All other returned statuses result in dropping those userOps
-// from the batch.
-// Received are treated as expired because they may have been compressed to
-// Solved Intents.
+// Solved userOps update the received bundle.
+// Unsolved and Received userOps remain in the mempool for another attempt
+// until the maximum number of attempts is reached. Received userOps may have
+// been compressed to other Solved Intents by the Solver.
+// Expired and Invalid userOps are dropped from the batch.
 //
 // The Solver may return a subset and in different sequence the UserOperations
 // and a matching occurs by the hash value of each UserOperation to the bundle
 // UserOperation.
+//
+// Solving runs in a background Stage that is decoupled from the Bundler. The
+// Stage writes solved userOps back to the mempool and the Bundler only batches
+// userOps that are ready to be sent to the EntryPoint.
 package solution
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"io"
 	"math/big"
 	"net/http"
-	"net/url"
-	"os"
+	"sort"
 	"time"
 	"unsafe"
 
 	"github.com/blndgs/model"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-logr/logr"
 	"github.com/goccy/go-json"
 	"github.com/pkg/errors"
 
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
 	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
 	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
 )
 
@@ -44,25 +55,103 @@ type batchOpIndex int
 type batchIntentIndices map[opHashID]batchOpIndex
 
 type IntentsHandler struct {
-	SolverURL    string
-	SolverClient *http.Client
+	db            storage.Store
+	SolverURLs    []string
+	SolverClient  *http.Client
+	solverTimeout time.Duration
+	scoreFn       ScoreFunc
+	validateFn    ValidateFunc
+	maxAttempts   int
+	retryBackoff  time.Duration
+	breakers      map[string]*circuitBreaker
+	hmacSecret    []byte
+	bearerToken   string
+	solverKeys    map[string]*ecdsa.PublicKey
+	logger        logr.Logger
 }
 
 // Verify structural congruence
 var _ = model.UserOperation(userop.UserOperation{})
 
-func New(solverURL string) *IntentsHandler {
-	const httpClientTimeout = 100 * time.Second
+// New returns an IntentsHandler that runs an auction between all the given Solvers for every batch of Intent
+// userOps.
+func New(db storage.Store, solverURLs []string) *IntentsHandler {
+	breakers := make(map[string]*circuitBreaker)
+	for _, solverURL := range solverURLs {
+		breakers[solverURL] = newCircuitBreaker(DefaultFailureThreshold, DefaultBreakerCooldown)
+	}
 
 	return &IntentsHandler{
-		SolverURL:    solverURL,
-		SolverClient: &http.Client{Timeout: httpClientTimeout},
+		db:            db,
+		SolverURLs:    solverURLs,
+		SolverClient:  &http.Client{},
+		solverTimeout: DefaultSolverTimeout,
+		scoreFn:       ScoreByLowestGasCost(),
+		maxAttempts:   DefaultMaxAttempts,
+		retryBackoff:  DefaultRetryBackoff,
+		breakers:      breakers,
+		solverKeys:    make(map[string]*ecdsa.PublicKey),
+		logger:        logger.NewZeroLogr().WithName("solver"),
+	}
+}
+
+// UseLogger defines the logger object used by the IntentsHandler instance based on the go-logr/logr interface.
+func (ei *IntentsHandler) UseLogger(logger logr.Logger) {
+	ei.logger = logger.WithName("solver")
+}
+
+// SetSolverTimeout defines the deadline for each Solver to respond to a batch. Solvers that miss the deadline
+// are excluded from the auction for that batch. The default value is 100 seconds.
+func (ei *IntentsHandler) SetSolverTimeout(timeout time.Duration) {
+	ei.solverTimeout = timeout
+}
+
+// SetScoreFunc defines the function used to pick the winning solution for an Intent when more than one
+// Solver returns it as Solved. The default is ScoreByLowestGasCost.
+func (ei *IntentsHandler) SetScoreFunc(fn ScoreFunc) {
+	ei.scoreFn = fn
+}
+
+// SetValidateFunc defines the function used to check a solution before it can win the auction for an Intent.
+// A solution that fails is discarded in favour of the next-best solution from another Solver. By default,
+// solutions are not validated.
+func (ei *IntentsHandler) SetValidateFunc(fn ValidateFunc) {
+	ei.validateFn = fn
+}
+
+// SetMaxAttempts defines the max number of times an Intent userOp is sent to the Solver before it is dropped
+// from the mempool. The default value is 5.
+func (ei *IntentsHandler) SetMaxAttempts(max int) {
+	ei.maxAttempts = max
+}
+
+// SetRetryBackoff defines the wait after the first attempt before an Intent userOp can be sent to the Solver
+// again. The wait doubles with each following attempt. The default value is 2 seconds.
+func (ei *IntentsHandler) SetRetryBackoff(backoff time.Duration) {
+	ei.retryBackoff = backoff
+}
+
+// SetFailureThreshold defines the number of consecutive failed requests or health checks after which a Solver
+// stops receiving batches. The default value is 3.
+func (ei *IntentsHandler) SetFailureThreshold(threshold int) {
+	for _, cb := range ei.breakers {
+		cb.threshold = threshold
+	}
+}
+
+// SetBreakerCooldown defines the wait before a Solver that stopped receiving batches is tried again. A
+// passing health check also allows the Solver to be tried again. The default value is 30 seconds.
+func (ei *IntentsHandler) SetBreakerCooldown(cooldown time.Duration) {
+	for _, cb := range ei.breakers {
+		cb.cooldown = cooldown
 	}
 }
 
 // bufferIntentOps caches the index of the userOp in the received batch and creates the UserOperationExt slice for the
-// Solver with cached Hashes and ProcessingStatus set to `Received`.
-func (ei *IntentsHandler) bufferIntentOps(entrypoint common.Address, chainID *big.Int, batchIndices batchIntentIndices, userOpBatch []*model.UserOperation) model.BodyOfUserOps {
+// Solver with cached Hashes and ProcessingStatus set to `Received`. Intents still within their retry backoff
+// are skipped. A SentToSolver transition is recorded for each Intent in the body. The attempt counter is only
+// incremented once a Solver has responded.
+func (ei *IntentsHandler) bufferIntentOps(txn storage.Txn, entrypoint common.Address, chainID *big.Int, batchIndices batchIntentIndices, userOpBatch []*model.UserOperation) (model.BodyOfUserOps, error) {
 	body := model.BodyOfUserOps{
 		UserOps:    make([]*model.UserOperation, 0, len(userOpBatch)),
 		UserOpsExt: make([]model.UserOperationExt, 0, len(userOpBatch)),
@@ -71,6 +160,18 @@ func (ei *IntentsHandler) bufferIntentOps(entrypoint common.Address, chainID *bi
 		if op.HasIntent() {
 			hashID := op.GetUserOpHash(entrypoint, chainID).String()
 
+			attempts, lastAttempt, err := getAttempts(txn, opHashID(hashID))
+			if err != nil {
+				return body, err
+			}
+			if !isDueForAttempt(ei.retryBackoff, attempts, lastAttempt) {
+				continue
+			}
+			reason := fmt.Sprintf("attempt %d of %d", attempts+1, ei.maxAttempts)
+			if err := intentstatus.Append(txn, hashID, model.SentToSolver, reason); err != nil {
+				return body, err
+			}
+
 			// Don't mutate the original op
 			clonedOp := *op
 			body.UserOps = append(body.UserOps, &clonedOp)
@@ -86,7 +187,7 @@ func (ei *IntentsHandler) bufferIntentOps(entrypoint common.Address, chainID *bi
 		}
 	}
 
-	return body
+	return body, nil
 }
 
 // SolveIntents returns a BatchHandlerFunc that will send the batch of UserOperations to the Solver
@@ -99,118 +200,167 @@ func (ei *IntentsHandler) SolveIntents() modules.BatchHandlerFunc {
 		// to be sent to the Solver
 		modelUserOps := *(*[]*model.UserOperation)(unsafe.Pointer(&ctx.Batch))
 
-		println("Received batch of UserOperations for solution: ", len(modelUserOps))
-		for idx, op := range modelUserOps {
-			println("Received UserOperation: [", idx, "], isIntent", op.HasIntent(), "op:", op.String())
+		l := ei.logger.WithValues("entrypoint", ctx.EntryPoint.String(), "batch_length", len(modelUserOps))
+
+		// Leave all Intents in the mempool if no Solver is currently available.
+		solverURLs := ei.availableSolvers()
+		if len(solverURLs) == 0 {
+			l.Info("no solver available, skipping batch")
+			return nil
 		}
 
 		// Prepare the body to send to the Solver
-		body := ei.bufferIntentOps(ctx.EntryPoint, ctx.ChainID, batchIntentIndices, modelUserOps)
+		var body model.BodyOfUserOps
+		err := ei.db.Update(func(txn storage.Txn) error {
+			var err error
+			body, err = ei.bufferIntentOps(txn, ctx.EntryPoint, ctx.ChainID, batchIntentIndices, modelUserOps)
+			return err
+		})
+		if err != nil {
+			ei.releaseSolvers(solverURLs)
+			return err
+		}
 
 		// Intents to process
 		if len(body.UserOps) == 0 {
+			ei.releaseSolvers(solverURLs)
 			return nil
 		}
 
-		if err := ei.sendToSolver(body); err != nil {
+		body, winners, err := ei.runAuction(ctx.EntryPoint, ctx.ChainID, solverURLs, body)
+		if err != nil {
 			return err
 		}
+		ctx.Data["solver_winners"] = winners
+
+		return ei.db.Update(func(txn storage.Txn) error {
+			rmIndices := []int{}
+			for idx, opExt := range body.UserOpsExt {
+				hashID := opHashID(opExt.OriginalHashValue)
+				batchIndex, ok := batchIntentIndices[hashID]
+				if !ok {
+					return errors.Errorf("unknown userOp hash in solver response: %s", hashID)
+				}
+
+				// A Solver responded so the request counts towards the attempts of every Intent in the body.
+				attempts, err := incrementAttempts(txn, hashID)
+				if err != nil {
+					return err
+				}
+				ol := l.WithValues("userop_hash", string(hashID), "status", opExt.ProcessingStatus)
+				switch opExt.ProcessingStatus {
+				case model.Unsolved, model.Received:
+					if attempts < ei.maxAttempts {
+						reason := fmt.Sprintf("attempt %d of %d, retrying", attempts, ei.maxAttempts)
+						if err := intentstatus.Append(txn, string(hashID), opExt.ProcessingStatus, reason); err != nil {
+							return err
+						}
+						// keep the userOp in the mempool for another attempt
+						ol.Info("intent retrying", "attempts", attempts, "max_attempts", ei.maxAttempts)
+						continue
+					}
+
+					reason := fmt.Sprintf("dropped after %d attempts", attempts)
+					if err := intentstatus.Append(txn, string(hashID), opExt.ProcessingStatus, reason); err != nil {
+						return err
+					}
+					if err := removeAttempts(txn, hashID); err != nil {
+						return err
+					}
+					rmIndices = append(rmIndices, int(batchIndex))
+					ol.Info("intent dropped after max attempts", "attempts", attempts)
+				case model.Expired, model.Invalid:
+					// dropping further processing
+					if err := intentstatus.Append(txn, string(hashID), opExt.ProcessingStatus, "reported by solver"); err != nil {
+						return err
+					}
+					if err := removeAttempts(txn, hashID); err != nil {
+						return err
+					}
+					rmIndices = append(rmIndices, int(batchIndex))
+					ol.Info("intent dropped by solver")
+				case model.Solved:
+					// set the solved userOp values to the received batch's userOp values
+					ctx.Batch[batchIndex].CallData = make([]byte, len(body.UserOps[idx].CallData))
+					copy(ctx.Batch[batchIndex].CallData, body.UserOps[idx].CallData)
+					ctx.Batch[batchIndex].Signature = make([]byte, len(body.UserOps[idx].Signature))
+					copy(ctx.Batch[batchIndex].Signature, body.UserOps[idx].Signature)
+					ctx.Batch[batchIndex].CallGasLimit = body.UserOps[idx].CallGasLimit
+					ctx.Batch[batchIndex].VerificationGasLimit = body.UserOps[idx].VerificationGasLimit
+					ctx.Batch[batchIndex].PreVerificationGas = body.UserOps[idx].PreVerificationGas
+					ctx.Batch[batchIndex].MaxFeePerGas = body.UserOps[idx].MaxFeePerGas
+					ctx.Batch[batchIndex].MaxPriorityFeePerGas = body.UserOps[idx].MaxPriorityFeePerGas
+					solvedHash := ctx.Batch[batchIndex].GetUserOpHash(ctx.EntryPoint, ctx.ChainID)
+					if err := intentstatus.SetAlias(txn, string(hashID), solvedHash.String()); err != nil {
+						return err
+					}
+
+					reason := "solved by " + winners[hashID]
+					if err := intentstatus.Append(txn, string(hashID), model.Solved, reason); err != nil {
+						return err
+					}
+					if err := removeAttempts(txn, hashID); err != nil {
+						return err
+					}
+
+				default:
+					return errors.Errorf("unknown processing status: %s", opExt.ProcessingStatus)
+				}
+			}
 
-		for idx, opExt := range body.UserOpsExt {
-			batchIndex := batchIntentIndices[opHashID(body.UserOpsExt[idx].OriginalHashValue)]
-			// print to stdout the userOp and Intent JSON
-			fmt.Println("Solver response, status:", opExt.ProcessingStatus, ", batchIndex:", batchIndex, ", hash:", body.UserOpsExt[idx].OriginalHashValue)
-			switch opExt.ProcessingStatus {
-			case model.Unsolved, model.Expired, model.Invalid, model.Received:
-				// dropping further processing
-				ctx.MarkOpIndexForRemoval(int(batchIndex))
-				println()
-				println("****************************************************")
-				println("Solver dropping userOp: ", body.UserOps[idx].String(), " with status: ", opExt.ProcessingStatus)
-				println("****************************************************")
-				println()
-			case model.Solved:
-				// set the solved userOp values to the received batch's userOp values
-				ctx.Batch[batchIndex].CallData = make([]byte, len(body.UserOps[idx].CallData))
-				copy(ctx.Batch[batchIndex].CallData, body.UserOps[idx].CallData)
-				ctx.Batch[batchIndex].Signature = make([]byte, len(body.UserOps[idx].Signature))
-				copy(ctx.Batch[batchIndex].Signature, body.UserOps[idx].Signature)
-				ctx.Batch[batchIndex].CallGasLimit = body.UserOps[idx].CallGasLimit
-				ctx.Batch[batchIndex].VerificationGasLimit = body.UserOps[idx].VerificationGasLimit
-				ctx.Batch[batchIndex].PreVerificationGas = body.UserOps[idx].PreVerificationGas
-				ctx.Batch[batchIndex].MaxFeePerGas = body.UserOps[idx].MaxFeePerGas
-				ctx.Batch[batchIndex].MaxPriorityFeePerGas = body.UserOps[idx].MaxPriorityFeePerGas
-
-			default:
-				return errors.Errorf("unknown processing status: %s", opExt.ProcessingStatus)
+			// Remove from the highest index down so that the remaining indices stay valid.
+			sort.Sort(sort.Reverse(sort.IntSlice(rmIndices)))
+			for _, i := range rmIndices {
+				ctx.MarkOpIndexForRemoval(i)
 			}
-		}
 
-		return nil
+			return nil
+		})
 	}
 }
 
-func ReportSolverHealth(solverURL string) error {
-	parsedURL, err := url.Parse(solverURL)
+// sendToSolver sends the batch of UserOperations to a Solver and returns its response.
+func (ei *IntentsHandler) sendToSolver(solverURL string, body model.BodyOfUserOps) (model.BodyOfUserOps, error) {
+	var res model.BodyOfUserOps
+	jsonBody, err := json.Marshal(body)
 	if err != nil {
-		println("Error parsing Solver URL: ", solverURL, ", ", err)
-		return err
+		return res, err
 	}
 
-	parsedURL.Path = "/health"
-	parsedURL.RawQuery = ""
-	parsedURL.Fragment = ""
-
-	solverURL = parsedURL.String()
-	fmt.Println("Requesting solver health at ", solverURL)
-
-	handler := New(solverURL)
-
-	req, err := http.NewRequest(http.MethodGet, solverURL, nil)
+	reqCtx, cancel := context.WithTimeout(context.Background(), ei.solverTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, solverURL, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return err
+		return res, err
 	}
 
-	resp, err := handler.SolverClient.Do(req)
+	req.Header.Set("Content-Type", "application/json")
+	nonce, err := ei.authenticate(req, jsonBody)
 	if err != nil {
-		return err
+		return res, err
 	}
-	defer resp.Body.Close()
 
-	fmt.Println("Solver health response: ", resp.Status)
-	_, err = io.Copy(os.Stdout, resp.Body)
+	resp, err := ei.SolverClient.Do(req)
 	if err != nil {
-		return err
+		ei.logger.Error(err, "solver request failed", "solver_url", solverURL)
+		return res, err
 	}
+	defer resp.Body.Close()
 
-	return nil
-}
-
-// sendToSolver sends the batch of UserOperations to the Solver.
-func (ei *IntentsHandler) sendToSolver(body model.BodyOfUserOps) error {
-	jsonBody, err := json.Marshal(body)
-	if err != nil {
-		return err
+	if resp.StatusCode != http.StatusOK {
+		return res, errors.Errorf("solver %s responded with status: %s", solverURL, resp.Status)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, ei.SolverURL, bytes.NewBuffer(jsonBody))
+	resBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return res, err
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := ei.SolverClient.Do(req)
-	if err != nil {
-		println("Solver request failed at URL: ", ei.SolverURL)
-		println("Solver error: ", err)
-		return err
+	if err := ei.verifyResponse(solverURL, nonce, resp.Header, resBody); err != nil {
+		return res, errors.Wrapf(err, "solver %s", solverURL)
 	}
-	defer resp.Body.Close()
-
-	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		return err
+	if err := json.Unmarshal(resBody, &res); err != nil {
+		return res, err
 	}
 
-	return nil
+	return res, nil
 }
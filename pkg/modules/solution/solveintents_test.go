@@ -0,0 +1,197 @@
+package solution
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blndgs/model"
+	"github.com/goccy/go-json"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/intentstatus"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// unsolvedSolverMock returns a Solver that responds with an Unsolved status for every userOp and counts the
+// number of requests received.
+func unsolvedSolverMock(calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		var body model.BodyOfUserOps
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			panic(err)
+		}
+		for i := range body.UserOpsExt {
+			body.UserOpsExt[i].ProcessingStatus = model.Unsolved
+		}
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			panic(err)
+		}
+	}))
+}
+
+// solvedSolverMock returns a Solver that responds with a Solved status for every userOp and replaces the
+// CallData with EVM instructions.
+func solvedSolverMock() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body model.BodyOfUserOps
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			panic(err)
+		}
+		for i := range body.UserOpsExt {
+			body.UserOps[i].CallData = []byte{0xb6, 0x1d, 0x27, 0xf6}
+			body.UserOpsExt[i].ProcessingStatus = model.Solved
+		}
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			panic(err)
+		}
+	}))
+}
+
+func newIntentBatchCtx(op *userop.UserOperation) *modules.BatchHandlerCtx {
+	return modules.NewBatchHandlerContext(
+		[]*userop.UserOperation{op},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+}
+
+// TestSolveIntentsRetriesUnsolved verifies that an Unsolved intent remains in the batch for another attempt
+// and is not sent to the Solver again until the backoff has elapsed.
+func TestSolveIntentsRetriesUnsolved(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	calls := 0
+	s := unsolvedSolverMock(&calls)
+	defer s.Close()
+
+	ei := New(db, []string{s.URL})
+	ei.SetRetryBackoff(time.Hour)
+	op := testutils.MockValidIntentUserOp()
+
+	ctx := newIntentBatchCtx(op)
+	if err := ei.SolveIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ctx.Batch) != 1 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	} else if len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("got pending removal length %d, want 0", len(ctx.PendingRemoval))
+	}
+
+	ctx = newIntentBatchCtx(op)
+	if err := ei.SolveIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if calls != 1 {
+		t.Fatalf("got %d solver calls, want 1", calls)
+	} else if len(ctx.Batch) != 1 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	}
+}
+
+// TestSolveIntentsDropsAfterMaxAttempts verifies that an Unsolved intent is marked for removal once the max
+// number of attempts has been reached.
+func TestSolveIntentsDropsAfterMaxAttempts(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	calls := 0
+	s := unsolvedSolverMock(&calls)
+	defer s.Close()
+
+	ei := New(db, []string{s.URL})
+	ei.SetMaxAttempts(2)
+	ei.SetRetryBackoff(0)
+	op := testutils.MockValidIntentUserOp()
+
+	for i := 0; i < 2; i++ {
+		ctx := newIntentBatchCtx(op)
+		if err := ei.SolveIntents()(ctx); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+
+		if i == 0 && len(ctx.PendingRemoval) != 0 {
+			t.Fatalf("got pending removal length %d, want 0", len(ctx.PendingRemoval))
+		} else if i == 1 && len(ctx.PendingRemoval) != 1 {
+			t.Fatalf("got pending removal length %d, want 1", len(ctx.PendingRemoval))
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("got %d solver calls, want 2", calls)
+	}
+}
+
+// TestSolveIntentsKeepsAttemptsOnSolverError verifies that a request that no Solver responded to does not
+// count towards the attempts of an intent.
+func TestSolveIntentsKeepsAttemptsOnSolverError(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	ei := New(db, []string{s.URL})
+	op := testutils.MockValidIntentUserOp()
+	if err := ei.SolveIntents()(newIntentBatchCtx(op)); err == nil {
+		t.Fatal("got nil, want err")
+	}
+
+	err := db.View(func(txn storage.Txn) error {
+		hash := opHashID(op.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID).String())
+		attempts, _, err := getAttempts(txn, hash)
+		if err != nil {
+			return err
+		} else if attempts != 0 {
+			t.Fatalf("got %d attempts, want 0", attempts)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestCalcBackoff verifies that the backoff doubles with each attempt.
+func TestCalcBackoff(t *testing.T) {
+	base := time.Second
+	for attempts, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := calcBackoff(base, attempts); got != want {
+			t.Fatalf("attempts %d: got %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+// TestSolveIntentsWritesHashAlias verifies that the original userOpHash of a solved Intent resolves to the
+// userOpHash of the solved userOp.
+func TestSolveIntentsWritesHashAlias(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	s := solvedSolverMock()
+	defer s.Close()
+
+	op := testutils.MockValidIntentUserOp()
+	original := op.GetUserOpHash(testutils.ValidAddress1, testutils.ChainID)
+	ctx := newIntentBatchCtx(op)
+	if err := New(db, []string{s.URL}).SolveIntents()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	solved := ctx.Batch[0].GetUserOpHash(testutils.ValidAddress1, testutils.ChainID)
+	if solved == original {
+		t.Fatal("got unchanged userOpHash, want solved userOpHash")
+	}
+
+	resolved, err := intentstatus.New(db).ResolveHash(original.String())
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if resolved != solved.String() {
+		t.Fatalf("got %s, want %s", resolved, solved)
+	}
+}
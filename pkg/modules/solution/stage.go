@@ -0,0 +1,241 @@
+package solution
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-logr/logr"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/logger"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules"
+	"github.com/stackup-wallet/stackup-bundler/pkg/modules/noop"
+	"github.com/stackup-wallet/stackup-bundler/pkg/userop"
+)
+
+// DefaultStageInterval is the default wait between each run of the background solving Stage.
+var DefaultStageInterval = 1 * time.Second
+
+// Stage continuously sends the unsolved Intent userOps in the mempool to the Solvers in the background and
+// writes the solved userOps back to the mempool. This decouples solving from the Bundler so that a slow
+// Solver does not stall the bundling of conventional userOps. Each EntryPoint is solved concurrently. The
+// health of each Solver is also polled in the background to update its circuit breaker.
+type Stage struct {
+	handler              *IntentsHandler
+	mempool              *mempool.Mempool
+	chainID              *big.Int
+	supportedEntryPoints []common.Address
+	batchHandler         modules.BatchHandlerFunc
+	logger               logr.Logger
+	interval             time.Duration
+	healthInterval       time.Duration
+	isRunning            bool
+	done                 chan bool
+	stop                 func()
+}
+
+// NewStage returns a Stage that uses the given IntentsHandler to solve Intent userOps in the mempool.
+func NewStage(
+	handler *IntentsHandler,
+	mempool *mempool.Mempool,
+	chainID *big.Int,
+	supportedEntryPoints []common.Address,
+) *Stage {
+	return &Stage{
+		handler:              handler,
+		mempool:              mempool,
+		chainID:              chainID,
+		supportedEntryPoints: supportedEntryPoints,
+		batchHandler:         noop.BatchHandler,
+		logger:               logger.NewZeroLogr().WithName("solver"),
+		interval:             DefaultStageInterval,
+		healthInterval:       DefaultHealthInterval,
+		isRunning:            false,
+		done:                 make(chan bool),
+		stop:                 func() {},
+	}
+}
+
+// SetInterval defines the wait between each run of the Stage. The default value is 1 second.
+func (s *Stage) SetInterval(interval time.Duration) {
+	s.interval = interval
+}
+
+// SetHealthInterval defines the wait between each poll of the Solvers' health endpoint. The default value is
+// 10 seconds.
+func (s *Stage) SetHealthInterval(interval time.Duration) {
+	s.healthInterval = interval
+}
+
+// UseModules defines the BatchHandlers to process the batch of unsolved Intents before it is sent to the
+// Solvers (e.g. to drop expired Intents).
+func (s *Stage) UseModules(handlers ...modules.BatchHandlerFunc) {
+	s.batchHandler = modules.ComposeBatchHandlerFunc(handlers...)
+}
+
+// UseLogger defines the logger object used by the Stage instance based on the go-logr/logr interface.
+func (s *Stage) UseLogger(logger logr.Logger) {
+	s.logger = logger.WithName("solver")
+}
+
+// Process sends all unsolved Intent userOps in the mempool for an EntryPoint to the Solvers. Solved userOps
+// replace the pending Intent in the mempool and dropped Intents are removed from it. Intents that are
+// retried remain unchanged.
+func (s *Stage) Process(ep common.Address) (*modules.BatchHandlerCtx, error) {
+	// Init logger
+	start := time.Now()
+	l := s.logger.
+		WithName("run").
+		WithValues("entrypoint", ep.String()).
+		WithValues("chain_id", s.chainID.String())
+
+	all, err := s.mempool.Dump(ep)
+	if err != nil {
+		l.Error(err, "solver run error")
+		return nil, err
+	}
+
+	// Solve copies of the pooled userOps so that the Bundler never reads an op that is being written to.
+	batch := []*userop.UserOperation{}
+	hashes := make(map[string]common.Hash)
+	for _, op := range all {
+		if !op.IsUnsolvedIntent() {
+			continue
+		}
+
+		cp := *op
+		batch = append(batch, &cp)
+		hashes[getSenderNonceKey(op)] = op.GetUserOpHash(ep, s.chainID)
+	}
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	ctx := modules.NewBatchHandlerContext(batch, ep, s.chainID, nil, nil, nil)
+	if err := modules.ComposeBatchHandlerFunc(s.batchHandler, s.handler.SolveIntents())(ctx); err != nil {
+		l.Error(err, "solver run error")
+		return nil, err
+	}
+
+	// The Intent may have been dropped or replaced in the mempool while the Solvers were working on it. Only
+	// write back the results for, or drop, the Intents that are still pending and unchanged.
+	solved := []string{}
+	for _, op := range ctx.Batch {
+		hash, ok := hashes[getSenderNonceKey(op)]
+		if op.IsUnsolvedIntent() || !ok {
+			continue
+		}
+
+		replaced, err := s.mempool.ReplaceIfEqual(ep, s.chainID, hash, op)
+		if err != nil {
+			l.Error(err, "solver run error")
+			return nil, err
+		} else if !replaced {
+			continue
+		}
+		solved = append(solved, op.GetUserOpHash(ep, s.chainID).String())
+	}
+	drp := []string{}
+	for _, op := range ctx.PendingRemoval {
+		hash, ok := hashes[getSenderNonceKey(op)]
+		if !ok {
+			continue
+		}
+
+		removed, err := s.mempool.RemoveIfEqual(ep, s.chainID, hash, op, mempool.ReasonDropped)
+		if err != nil {
+			l.Error(err, "solver run error")
+			return nil, err
+		} else if !removed {
+			continue
+		}
+		drp = append(drp, hash.String())
+	}
+
+	// Update logs for the current run.
+	l = l.
+		WithValues("solved_userop_hashes", solved).
+		WithValues("dropped_userop_hashes", drp)
+
+	for k, v := range ctx.Data {
+		l = l.WithValues(k, v)
+	}
+	l = l.WithValues("duration", time.Since(start))
+	l.Info("solver run ok")
+	return ctx, nil
+}
+
+func getSenderNonceKey(op *userop.UserOperation) string {
+	return op.Sender.String() + ":" + op.Nonce.String()
+}
+
+// checkHealth polls the health of all Solvers and logs any that failed. An unhealthy Solver never stops the
+// Stage from running.
+func (s *Stage) checkHealth() {
+	if err := s.handler.CheckSolversHealth(); err != nil {
+		s.logger.Error(err, "solver health check error")
+	}
+}
+
+// Run starts goroutines that will continuously solve Intent userOps from the mempool and poll the health of
+// all Solvers.
+func (s *Stage) Run() error {
+	if s.isRunning {
+		return nil
+	}
+
+	done := make(chan bool)
+	s.done = done
+	ticker := time.NewTicker(s.interval)
+	healthTicker := time.NewTicker(s.healthInterval)
+	go func(s *Stage) {
+		s.checkHealth()
+		for {
+			select {
+			case <-done:
+				return
+			case <-healthTicker.C:
+				s.checkHealth()
+			}
+		}
+	}(s)
+	go func(s *Stage) {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var wg sync.WaitGroup
+				for _, ep := range s.supportedEntryPoints {
+					wg.Add(1)
+					go func(ep common.Address) {
+						defer wg.Done()
+						// Errors are already logged.
+						_, _ = s.Process(ep)
+					}(ep)
+				}
+				wg.Wait()
+			}
+		}
+	}(s)
+
+	s.isRunning = true
+	s.stop = func() {
+		ticker.Stop()
+		healthTicker.Stop()
+	}
+	return nil
+}
+
+// Stop signals the Stage to stop continuously solving Intent userOps from the mempool.
+func (s *Stage) Stop() {
+	if !s.isRunning {
+		return
+	}
+
+	s.isRunning = false
+	s.stop()
+	close(s.done)
+}
@@ -0,0 +1,127 @@
+package solution
+
+import (
+	"testing"
+
+	"github.com/blndgs/model"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/fakesolver"
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/mempool"
+)
+
+// TestStageWritesSolvedOpsToMempool verifies that a solved Intent replaces the pending Intent in the mempool
+// without mutating the userOp that was read from it.
+func TestStageWritesSolvedOpsToMempool(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	s := solvedSolverMock()
+	defer s.Close()
+
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	op := testutils.MockValidIntentUserOp()
+	ep := testutils.ValidAddress1
+	if err := mem.AddOp(ep, op); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	st := NewStage(New(db, []string{s.URL}), mem, testutils.ChainID, nil)
+	if _, err := st.Process(ep); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if !op.IsUnsolvedIntent() {
+		t.Fatal("got mutated op, want original op unchanged")
+	}
+
+	ops, err := mem.Dump(ep)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ops) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(ops))
+	} else if ops[0].IsUnsolvedIntent() {
+		t.Fatal("got unsolved op in mempool, want solved op")
+	}
+}
+
+// TestStageKeepsUnsolvedOpsInMempool verifies that an Unsolved Intent remains pending in the mempool for
+// another attempt.
+func TestStageKeepsUnsolvedOpsInMempool(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+	calls := 0
+	s := unsolvedSolverMock(&calls)
+	defer s.Close()
+
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	op := testutils.MockValidIntentUserOp()
+	ep := testutils.ValidAddress1
+	if err := mem.AddOp(ep, op); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	st := NewStage(New(db, []string{s.URL}), mem, testutils.ChainID, nil)
+	if _, err := st.Process(ep); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	ops, err := mem.Dump(ep)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if len(ops) != 1 || ops[0] != op {
+		t.Fatalf("got mempool %v, want original op only", ops)
+	}
+}
+
+// TestStageSolvesAfterScriptedRetry verifies that an Intent the Solver returns as Unsolved stays pending and
+// is written back as a solved userOp once the Solver returns it as Solved on a later run.
+func TestStageSolvesAfterScriptedRetry(t *testing.T) {
+	db := testutils.StoreMock()
+	defer db.Close()
+
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	op := testutils.MockValidIntentUserOp()
+	ep := testutils.ValidAddress1
+	if err := mem.AddOp(ep, op); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	hash := op.GetUserOpHash(ep, testutils.ChainID).String()
+	s, fs := testutils.FakeSolverMock(&fakesolver.Script{
+		Intents: map[string][]fakesolver.Step{
+			hash: {
+				{Status: model.Unsolved},
+				{Status: model.Solved, CallData: []byte{0xb6, 0x1d, 0x27, 0xf6}},
+			},
+		},
+	})
+	defer s.Close()
+
+	ei := New(db, []string{s.URL})
+	ei.SetRetryBackoff(0)
+	st := NewStage(ei, mem, testutils.ChainID, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := st.Process(ep); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	ops, err := mem.Dump(ep)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if fs.Calls(hash) != 2 {
+		t.Fatalf("got %d solver calls, want 2", fs.Calls(hash))
+	} else if len(ops) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(ops))
+	} else if ops[0].IsUnsolvedIntent() {
+		t.Fatal("got unsolved op in mempool, want solved op")
+	}
+}
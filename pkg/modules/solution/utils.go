@@ -0,0 +1,30 @@
+package solution
+
+import (
+	"time"
+)
+
+var (
+	DefaultMaxAttempts   = 5
+	DefaultRetryBackoff  = 2 * time.Second
+	DefaultSolverTimeout = 100 * time.Second
+)
+
+// calcBackoff returns the minimum wait before an intent that has already been sent to the Solver a given
+// number of times is eligible for another attempt. The wait doubles with every attempt.
+func calcBackoff(base time.Duration, attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// isDueForAttempt returns true if the backoff since the last attempt has elapsed.
+func isDueForAttempt(base time.Duration, attempts int, lastAttempt time.Time) bool {
+	return !time.Now().Before(lastAttempt.Add(calcBackoff(base, attempts)))
+}
@@ -0,0 +1,27 @@
+package signer
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// NewFromKeystore returns an EOA from an encrypted go-ethereum keystore file. The passphrase is read from a
+// separate file so that neither the key nor the passphrase need to be set as an environment variable.
+func NewFromKeystore(keyFile string, passwordFile string) (*EOA, error) {
+	keyJSON, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	password, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, strings.TrimRight(string(password), "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	return fromPrivateKey(key.PrivateKey)
+}
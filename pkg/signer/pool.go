@@ -0,0 +1,159 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	ErrNoIdleEOA = errors.New("signer: no idle EOA with sufficient balance")
+)
+
+// Pool holds several Signers that can be leased to send transactions. Each Signer can only be leased once at
+// a time so that multiple transactions can be pending without using the same EOA.
+type Pool struct {
+	mu         sync.Mutex
+	eth        *ethclient.Client
+	eoas       []Signer
+	leased     map[common.Address]bool
+	minBalance *big.Int
+}
+
+// NewPool returns a Pool of the given Signers. Signers for the same EOA are only added once.
+func NewPool(eth *ethclient.Client, signers ...Signer) (*Pool, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("signer: pool requires at least one signer")
+	}
+
+	eoas := []Signer{}
+	seen := make(map[common.Address]bool)
+	for _, s := range signers {
+		if seen[s.Account().Address] {
+			continue
+		}
+
+		seen[s.Account().Address] = true
+		eoas = append(eoas, s)
+	}
+
+	return &Pool{
+		eth:        eth,
+		eoas:       eoas,
+		leased:     make(map[common.Address]bool),
+		minBalance: big.NewInt(0),
+	}, nil
+}
+
+// SetMinBalance defines the balance below which an EOA will not be leased. The default value is 0.
+func (p *Pool) SetMinBalance(min *big.Int) {
+	p.minBalance = min
+}
+
+// EOAs returns all Signers in the Pool in the order they were added.
+func (p *Pool) EOAs() []Signer {
+	return p.eoas
+}
+
+// Lease returns the idle EOA with the highest balance and marks it as leased. The returned function must be
+// called to release the EOA once its transaction is no longer pending. ErrNoIdleEOA is returned if all EOAs
+// are leased or below the minimum balance.
+func (p *Pool) Lease() (Signer, func(), error) {
+	// Balances are fetched without holding the lock so that a slow node does not block other leases and
+	// releases. An EOA that was leased in the meantime is skipped below.
+	balances := make(map[common.Address]*big.Int)
+	for _, eoa := range p.idle() {
+		bal, err := p.eth.BalanceAt(context.Background(), eoa.Account().Address, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		balances[eoa.Account().Address] = bal
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best Signer
+	var bestBal *big.Int
+	for _, eoa := range p.eoas {
+		bal, ok := balances[eoa.Account().Address]
+		if !ok || p.leased[eoa.Account().Address] || bal.Cmp(p.minBalance) < 0 {
+			continue
+		}
+		if best == nil || bal.Cmp(bestBal) > 0 {
+			best = eoa
+			bestBal = bal
+		}
+	}
+	if best == nil {
+		return nil, nil, ErrNoIdleEOA
+	}
+
+	p.leased[best.Account().Address] = true
+	return best, func() { p.release(best) }, nil
+}
+
+func (p *Pool) idle() []Signer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idle := []Signer{}
+	for _, eoa := range p.eoas {
+		if !p.leased[eoa.Account().Address] {
+			idle = append(idle, eoa)
+		}
+	}
+	return idle
+}
+
+func (p *Pool) release(eoa Signer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.leased, eoa.Account().Address)
+}
+
+// UserMeter defines an opentelemetry meter object used by the Pool to report the latest nonce and balance of
+// each EOA.
+func (p *Pool) UserMeter(meter metric.Meter) error {
+	nonce, err := meter.Int64ObservableGauge("signer_eoa_nonce")
+	if err != nil {
+		return err
+	}
+	balance, err := meter.Float64ObservableGauge("signer_eoa_balance", metric.WithUnit("wei"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(
+		func(ctx context.Context, o metric.Observer) error {
+			for _, eoa := range p.eoas {
+				addr := eoa.Account().Address
+				attrs := metric.WithAttributes(attribute.String("eoa_address", addr.String()))
+
+				n, err := p.eth.NonceAt(ctx, addr, nil)
+				if err != nil {
+					return err
+				}
+				bal, err := p.eth.BalanceAt(ctx, addr, nil)
+				if err != nil {
+					return err
+				}
+				f, _ := new(big.Float).SetInt(bal).Float64()
+
+				o.ObserveInt64(nonce, int64(n), attrs)
+				o.ObserveFloat64(balance, f, attrs)
+			}
+			return nil
+		},
+		nonce,
+		balance,
+	)
+	return err
+}
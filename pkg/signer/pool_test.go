@@ -0,0 +1,77 @@
+package signer_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
+)
+
+func newPoolMock(t *testing.T, n int) *signer.Pool {
+	s := testutils.RpcMock(testutils.MethodMocks{"eth_getBalance": "0x64"})
+	t.Cleanup(s.Close)
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	signers := []signer.Signer{}
+	for i := 0; i < n; i++ {
+		pk, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		eoa, err := signer.New(hexutil.Encode(crypto.FromECDSA(pk))[2:])
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		signers = append(signers, eoa)
+	}
+	p, err := signer.NewPool(eth, signers...)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	return p
+}
+
+// TestPoolLeasesIdleEOAs verifies that a leased EOA is not leased again until it has been released.
+func TestPoolLeasesIdleEOAs(t *testing.T) {
+	p := newPoolMock(t, 2)
+
+	eoa1, release1, err := p.Lease()
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	eoa2, _, err := p.Lease()
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if eoa1.Account().Address == eoa2.Account().Address {
+		t.Fatal("got same EOA leased twice, want different EOAs")
+	}
+	if _, _, err := p.Lease(); !errors.Is(err, signer.ErrNoIdleEOA) {
+		t.Fatalf("got %v, want ErrNoIdleEOA", err)
+	}
+
+	release1()
+	if eoa, _, err := p.Lease(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if eoa.Account().Address != eoa1.Account().Address {
+		t.Fatalf("got %s, want %s", eoa.Account().Address, eoa1.Account().Address)
+	}
+}
+
+// TestPoolSkipsLowBalance verifies that an EOA below the minimum balance is not leased.
+func TestPoolSkipsLowBalance(t *testing.T) {
+	p := newPoolMock(t, 1)
+	p.SetMinBalance(big.NewInt(101))
+
+	if _, _, err := p.Lease(); !errors.Is(err, signer.ErrNoIdleEOA) {
+		t.Fatalf("got %v, want ErrNoIdleEOA", err)
+	}
+}
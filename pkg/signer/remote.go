@@ -0,0 +1,82 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Remote is a Signer for an EOA held by an external signer that implements the account_signTransaction
+// method of Clef's JSON-RPC API. The private key never leaves the external signer.
+type Remote struct {
+	rpc     *rpc.Client
+	address common.Address
+}
+
+type signTransactionResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// NewRemote returns a Signer for the given address using the external signer at url.
+func NewRemote(url string, address common.Address) (*Remote, error) {
+	c, err := rpc.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Remote{rpc: c, address: address}, nil
+}
+
+// Account implements the Signer interface.
+func (r *Remote) Account() accounts.Account {
+	return accounts.Account{Address: r.address, URL: accounts.URL{Scheme: "extapi"}}
+}
+
+// SignTx implements the Signer interface. The signed transaction is rejected if the external signer returns
+// a transaction that is not signed by the expected EOA.
+func (r *Remote) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	data := hexutil.Bytes(tx.Data())
+	args := apitypes.SendTxArgs{
+		From:    common.NewMixedcaseAddress(r.address),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   hexutil.Big(*tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    &data,
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	if tx.To() != nil {
+		to := common.NewMixedcaseAddress(*tx.To())
+		args.To = &to
+	}
+	if tx.Type() == types.LegacyTxType {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+		al := tx.AccessList()
+		args.AccessList = &al
+	}
+
+	var res signTransactionResult
+	if err := r.rpc.CallContext(context.Background(), &res, "account_signTransaction", &args); err != nil {
+		return nil, err
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(res.Raw); err != nil {
+		return nil, err
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		return nil, err
+	} else if sender != r.address {
+		return nil, fmt.Errorf("signer: remote signed for %s, want %s", sender, r.address)
+	}
+	return signed, nil
+}
@@ -1,14 +1,27 @@
-// Package signer holds EOA private keys to can sign regular Ethereum transactions.
+// Package signer holds the EOAs that sign regular Ethereum transactions for the bundler. An EOA can be backed
+// by a private key in memory, an encrypted keystore file, or an external signer.
 package signer
 
 import (
 	"crypto/ecdsa"
 	"errors"
+	"math/big"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// Signer signs transactions on behalf of a single EOA.
+type Signer interface {
+	// Account returns the EOA that transactions are signed for.
+	Account() accounts.Account
+
+	// SignTx returns a copy of the transaction signed by the EOA for the given chain.
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
 // EOA is an instance of a ECDSA private key, public key, and address.
 type EOA struct {
 	PrivateKey *ecdsa.PrivateKey
@@ -22,6 +35,10 @@ func New(pk string) (*EOA, error) {
 	if err != nil {
 		return nil, err
 	}
+	return fromPrivateKey(privateKey)
+}
+
+func fromPrivateKey(privateKey *ecdsa.PrivateKey) (*EOA, error) {
 	publicKey, ok := privateKey.Public().(*ecdsa.PublicKey)
 	if !ok {
 		return nil, errors.New("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
@@ -34,3 +51,13 @@ func New(pk string) (*EOA, error) {
 		Address:    address,
 	}, nil
 }
+
+// Account implements the Signer interface.
+func (e *EOA) Account() accounts.Account {
+	return accounts.Account{Address: e.Address}
+}
+
+// SignTx implements the Signer interface.
+func (e *EOA) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), e.PrivateKey)
+}
@@ -0,0 +1,123 @@
+package signer_test
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/signer"
+)
+
+type signTransactionResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// clefMock implements the account_signTransaction method of Clef with the DummyEOA.
+type clefMock struct{}
+
+func (c *clefMock) SignTransaction(args apitypes.SendTxArgs) (*signTransactionResult, error) {
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   args.ChainID.ToInt(),
+		Nonce:     uint64(args.Nonce),
+		GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+		GasFeeCap: args.MaxFeePerGas.ToInt(),
+		Gas:       uint64(args.Gas),
+		Value:     args.Value.ToInt(),
+		Data:      *args.Data,
+	})
+	signed, err := testutils.DummyEOA.SignTx(tx, args.ChainID.ToInt())
+	if err != nil {
+		return nil, err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &signTransactionResult{Raw: raw}, nil
+}
+
+func newClefMock(t *testing.T) string {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("account", &clefMock{}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	s := httptest.NewServer(srv)
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func newDynamicFeeTx() *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   testutils.ChainID,
+		Nonce:     1,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(2),
+		Gas:       21000,
+		Value:     big.NewInt(0),
+		Data:      []byte{},
+	})
+}
+
+// TestRemoteSignTx verifies that a transaction signed by a remote signer is accepted if it is signed by the
+// expected EOA.
+func TestRemoteSignTx(t *testing.T) {
+	s, err := signer.NewRemote(newClefMock(t), testutils.DummyEOA.Address)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	signed, err := s.SignTx(newDynamicFeeTx(), testutils.ChainID)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(testutils.ChainID), signed)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if sender != testutils.DummyEOA.Address {
+		t.Fatalf("got sender %s, want %s", sender, testutils.DummyEOA.Address)
+	}
+}
+
+// TestRemoteSignTxWithWrongSender verifies that a transaction signed by a remote signer is rejected if it is
+// not signed by the expected EOA.
+func TestRemoteSignTxWithWrongSender(t *testing.T) {
+	s, err := signer.NewRemote(newClefMock(t), testutils.ValidAddress1)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	if _, err := s.SignTx(newDynamicFeeTx(), testutils.ChainID); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
+
+// TestNewFromKeystore verifies that an EOA can be loaded from an encrypted keystore file.
+func TestNewFromKeystore(t *testing.T) {
+	dir := t.TempDir()
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	acc, err := ks.ImportECDSA(testutils.DummyEOA.PrivateKey, "password")
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	keyFile := acc.URL.Path
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("password\n"), 0600); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	eoa, err := signer.NewFromKeystore(keyFile, passwordFile)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	} else if eoa.Account().Address != testutils.DummyEOA.Address {
+		t.Fatalf("got %s, want %s", eoa.Account().Address, testutils.DummyEOA.Address)
+	}
+}
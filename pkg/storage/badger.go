@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"time"
+
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t *badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+func (t *badgerTxn) Set(key []byte, value []byte) error {
+	return t.txn.Set(key, value)
+}
+
+func (t *badgerTxn) SetWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	return t.txn.SetEntry(badger.NewEntry(key, value).WithTTL(ttl))
+}
+
+func (t *badgerTxn) Delete(key []byte) error {
+	return t.txn.Delete(key)
+}
+
+func (t *badgerTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 10
+	it := t.txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(item.KeyCopy(nil), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BadgerStore is a Store backed by a badger DB.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+// NewBadgerStore returns a Store that reads and writes to the given badger DB. The DB can still be used
+// directly by other packages.
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{db}
+}
+
+// View runs fn in a read-only badger transaction.
+func (s *BadgerStore) View(fn func(txn Txn) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn})
+	})
+}
+
+// Update runs fn in a read-write badger transaction.
+func (s *BadgerStore) Update(fn func(txn Txn) error) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn})
+	})
+}
+
+// DropAll removes every key in the badger DB.
+func (s *BadgerStore) DropAll() error {
+	return s.db.DropAll()
+}
+
+// Close closes the badger DB.
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
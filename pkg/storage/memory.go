@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errReadOnly = errors.New("storage: write in read-only transaction")
+)
+
+// memorySweepInterval is the minimum wait between each removal of expired keys from a MemoryStore.
+const memorySweepInterval = time.Minute
+
+// memoryEntry is a value held by a MemoryStore. A zero expiresAt means the entry does not expire.
+type memoryEntry struct {
+	value     []byte
+	expiresAt int64
+}
+
+// isExpired follows the same rule as badger where an entry expires at the start of its expiresAt second.
+func (e memoryEntry) isExpired(now time.Time) bool {
+	return e.expiresAt != 0 && e.expiresAt <= now.Unix()
+}
+
+type memoryWrite struct {
+	entry   memoryEntry
+	deleted bool
+}
+
+type memoryTxn struct {
+	data     map[string]memoryEntry
+	writes   map[string]memoryWrite
+	readOnly bool
+	now      time.Time
+}
+
+func (t *memoryTxn) Get(key []byte) ([]byte, error) {
+	e, ok := t.data[string(key)]
+	if w, written := t.writes[string(key)]; written {
+		e, ok = w.entry, !w.deleted
+	}
+	if !ok || e.isExpired(t.now) {
+		return nil, ErrKeyNotFound
+	}
+	return append([]byte{}, e.value...), nil
+}
+
+func (t *memoryTxn) Set(key []byte, value []byte) error {
+	return t.set(key, value, 0)
+}
+
+func (t *memoryTxn) SetWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	return t.set(key, value, t.now.Add(ttl).Unix())
+}
+
+func (t *memoryTxn) set(key []byte, value []byte, expiresAt int64) error {
+	if t.readOnly {
+		return errReadOnly
+	}
+
+	t.writes[string(key)] = memoryWrite{entry: memoryEntry{append([]byte{}, value...), expiresAt}}
+	return nil
+}
+
+func (t *memoryTxn) Delete(key []byte) error {
+	if t.readOnly {
+		return errReadOnly
+	}
+
+	t.writes[string(key)] = memoryWrite{deleted: true}
+	return nil
+}
+
+func (t *memoryTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	keys := []string{}
+	for k := range t.data {
+		if _, ok := t.writes[k]; !ok && strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	for k, w := range t.writes {
+		if !w.deleted && strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		value, err := t.Get([]byte(k))
+		if err == ErrKeyNotFound {
+			// Expired or deleted by fn during iteration.
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := fn([]byte(k), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemoryStore is a Store that only holds its data in memory. It is intended for tests and ephemeral
+// deployments where nothing needs to survive a restart. Transactions are serialized by a single lock. Expired
+// keys are never returned and are removed by the next Update once memorySweepInterval has elapsed.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	data      map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string]memoryEntry), lastSweep: time.Now()}
+}
+
+// View runs fn in a read-only transaction.
+func (s *MemoryStore) View(fn func(txn Txn) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&memoryTxn{data: s.data, writes: make(map[string]memoryWrite), readOnly: true, now: time.Now()})
+}
+
+// Update runs fn in a read-write transaction. Writes are only applied if fn returns nil.
+func (s *MemoryStore) Update(fn func(txn Txn) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	txn := &memoryTxn{data: s.data, writes: make(map[string]memoryWrite), now: now}
+	if err := fn(txn); err != nil {
+		return err
+	}
+	for k, w := range txn.writes {
+		if w.deleted {
+			delete(s.data, k)
+		} else {
+			s.data[k] = w.entry
+		}
+	}
+
+	if now.Sub(s.lastSweep) >= memorySweepInterval {
+		for k, e := range s.data {
+			if e.isExpired(now) {
+				delete(s.data, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
+
+// DropAll removes every key in the MemoryStore.
+func (s *MemoryStore) DropAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = make(map[string]memoryEntry)
+	return nil
+}
+
+// Close is a no-op for a MemoryStore.
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package storage defines the embedded key/value store used to persist the state of the mempool and its
+// modules. A Store can either be backed by badger on disk or held entirely in memory.
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrKeyNotFound is returned by Txn.Get when the key does not exist.
+	ErrKeyNotFound = errors.New("storage: key not found")
+)
+
+// Txn provides read and write access to a Store within a single transaction. Writes in a read-only
+// transaction return an error. Values returned by Get and passed to Iterate are copies and remain valid after
+// the transaction has ended.
+type Txn interface {
+	// Get returns the value of key or ErrKeyNotFound.
+	Get(key []byte) ([]byte, error)
+
+	// Set writes value at key.
+	Set(key []byte, value []byte) error
+
+	// SetWithTTL writes value at key and expires it once ttl has elapsed. An expired key is treated as if it
+	// was deleted.
+	SetWithTTL(key []byte, value []byte, ttl time.Duration) error
+
+	// Delete removes key. Deleting a key that does not exist is not an error.
+	Delete(key []byte) error
+
+	// Iterate calls fn with every key starting with prefix in ascending order. Iteration stops at the first
+	// error returned by fn.
+	Iterate(prefix []byte, fn func(key []byte, value []byte) error) error
+}
+
+// Store is a key/value store with serializable transactions. If the function passed to Update returns an
+// error, none of its writes are committed.
+type Store interface {
+	// View runs fn in a read-only transaction.
+	View(fn func(txn Txn) error) error
+
+	// Update runs fn in a read-write transaction.
+	Update(fn func(txn Txn) error) error
+
+	// DropAll removes every key in the Store.
+	DropAll() error
+
+	// Close releases the resources held by the Store.
+	Close() error
+}
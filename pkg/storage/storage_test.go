@@ -0,0 +1,135 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stackup-wallet/stackup-bundler/internal/testutils"
+	"github.com/stackup-wallet/stackup-bundler/pkg/storage"
+)
+
+func forEachStore(t *testing.T, fn func(t *testing.T, s storage.Store)) {
+	t.Run("badger", func(t *testing.T) {
+		s := storage.NewBadgerStore(testutils.DBMock())
+		defer s.Close()
+		fn(t, s)
+	})
+	t.Run("memory", func(t *testing.T) {
+		s := storage.NewMemoryStore()
+		defer s.Close()
+		fn(t, s)
+	})
+}
+
+// TestStoreIteratesPrefixInOrder verifies that only keys with the given prefix are iterated in ascending
+// order, including writes made earlier in the same transaction.
+func TestStoreIteratesPrefixInOrder(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storage.Store) {
+		if err := s.Update(func(txn storage.Txn) error {
+			for _, k := range []string{"a:2", "b:1", "a:1"} {
+				if err := txn.Set([]byte(k), []byte(k)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+
+		keys := []string{}
+		if err := s.Update(func(txn storage.Txn) error {
+			if err := txn.Set([]byte("a:3"), []byte("a:3")); err != nil {
+				return err
+			}
+			if err := txn.Delete([]byte("a:1")); err != nil {
+				return err
+			}
+			return txn.Iterate([]byte("a:"), func(key []byte, value []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			})
+		}); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+
+		if len(keys) != 2 || keys[0] != "a:2" || keys[1] != "a:3" {
+			t.Fatalf("got keys %v, want [a:2 a:3]", keys)
+		}
+	})
+}
+
+// TestStoreDiscardsFailedUpdate verifies that no writes are committed if the update returns an error.
+func TestStoreDiscardsFailedUpdate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storage.Store) {
+		errFailed := errors.New("failed")
+		if err := s.Update(func(txn storage.Txn) error {
+			if err := txn.Set([]byte("key"), []byte("value")); err != nil {
+				return err
+			}
+			return errFailed
+		}); err != errFailed {
+			t.Fatalf("got %v, want %v", err, errFailed)
+		}
+
+		if err := s.View(func(txn storage.Txn) error {
+			_, err := txn.Get([]byte("key"))
+			return err
+		}); err != storage.ErrKeyNotFound {
+			t.Fatalf("got %v, want ErrKeyNotFound", err)
+		}
+	})
+}
+
+// TestStoreDropAll verifies that every key is removed.
+func TestStoreDropAll(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storage.Store) {
+		if err := s.Update(func(txn storage.Txn) error {
+			return txn.Set([]byte("key"), []byte("value"))
+		}); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		if err := s.DropAll(); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+
+		if err := s.View(func(txn storage.Txn) error {
+			_, err := txn.Get([]byte("key"))
+			return err
+		}); err != storage.ErrKeyNotFound {
+			t.Fatalf("got %v, want ErrKeyNotFound", err)
+		}
+	})
+}
+
+// TestStoreExpiresKeys verifies that a key written with a TTL is no longer returned once the TTL has elapsed.
+func TestStoreExpiresKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storage.Store) {
+		if err := s.Update(func(txn storage.Txn) error {
+			if err := txn.SetWithTTL([]byte("a:1"), []byte("value"), time.Second); err != nil {
+				return err
+			}
+			return txn.SetWithTTL([]byte("a:2"), []byte("value"), time.Hour)
+		}); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		time.Sleep(2 * time.Second)
+
+		keys := []string{}
+		if err := s.View(func(txn storage.Txn) error {
+			if _, err := txn.Get([]byte("a:1")); err != storage.ErrKeyNotFound {
+				t.Fatalf("got %v, want ErrKeyNotFound", err)
+			}
+			return txn.Iterate([]byte("a:"), func(key []byte, value []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			})
+		}); err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+
+		if len(keys) != 1 || keys[0] != "a:2" {
+			t.Fatalf("got keys %v, want [a:2]", keys)
+		}
+	})
+}